@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+// resettableCache is an auth.Cache whose contents can be discarded atomically
+// while other requests are using it
+type resettableCache struct {
+	inner atomic.Pointer[auth.Cache]
+}
+
+// newResettableCache creates an empty resettable cache
+func newResettableCache() *resettableCache {
+	c := &resettableCache{}
+	c.Reset()
+	return c
+}
+
+// Reset drops every cached scheme and token
+func (c *resettableCache) Reset() {
+	cache := auth.NewCache()
+	c.inner.Store(&cache)
+}
+
+// GetScheme implements auth.Cache
+func (c *resettableCache) GetScheme(ctx context.Context, registry string) (auth.Scheme, error) {
+	return (*c.inner.Load()).GetScheme(ctx, registry)
+}
+
+// GetToken implements auth.Cache
+func (c *resettableCache) GetToken(ctx context.Context, registry string, scheme auth.Scheme, key string) (string, error) {
+	return (*c.inner.Load()).GetToken(ctx, registry, scheme, key)
+}
+
+// Set implements auth.Cache
+func (c *resettableCache) Set(ctx context.Context, registry string, scheme auth.Scheme, key string, fetch func(context.Context) (string, error)) (string, error) {
+	return (*c.inner.Load()).Set(ctx, registry, scheme, key, fetch)
+}
+
+// refreshingClient wraps an auth.Client and retries a request once with a cleared
+// token cache when the registry answers 401 after an earlier request got a 2xx
+// response, which is what an expired token looks like on a long-running server
+type refreshingClient struct {
+	client        *auth.Client
+	cache         *resettableCache
+	logger        Logger
+	authenticated atomic.Bool
+	// registry and anonymous describe the configured credentials for 401 errors
+	registry  string
+	anonymous bool
+}
+
+// Do implements remote.Client. A 401 that survives the token refresh is returned
+// as an error saying whether credentials are missing or were rejected, a 429 that
+// survives the retries as ErrRateLimited, and a registry that can't be reached as
+// ErrRegistryUnreachable.
+func (c *refreshingClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.do(req)
+	if err != nil {
+		// Basic auth challenges fail before any retry when no credentials are set, and
+		// token servers refuse credentials with their own 401
+		var errResp *errcode.ErrorResponse
+		if errors.Is(err, auth.ErrBasicCredentialNotFound) || (errors.As(err, &errResp) && errResp.StatusCode == http.StatusUnauthorized) {
+			return nil, c.unauthorizedError(err)
+		}
+		// Token servers can rate limit too
+		if errResp != nil && errResp.StatusCode == http.StatusTooManyRequests {
+			return nil, classify(ErrRateLimited, err)
+		}
+		return nil, wrapTransportError(err)
+	}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		defer resp.Body.Close()
+		return nil, c.unauthorizedError(parseErrorResponse(resp))
+	case http.StatusTooManyRequests:
+		// Still throttled after the retries
+		defer resp.Body.Close()
+		return nil, classify(ErrRateLimited, parseErrorResponse(resp))
+	}
+	return resp, nil
+}
+
+// unauthorizedError tells a registry that needs credentials none were configured
+// for apart from one that rejected the configured credentials
+func (c *refreshingClient) unauthorizedError(cause error) error {
+	if c.anonymous {
+		return classify(ErrUnauthorized, fmt.Errorf("%w; none are configured for registry %s: %w", ErrCredentialsRequired, c.registry, cause))
+	}
+	return classify(ErrUnauthorized, fmt.Errorf("%w for registry %s: %w", ErrCredentialsRejected, c.registry, cause))
+}
+
+// parseErrorResponse builds the error for a registry error response, including any
+// distribution API error codes in its body
+func parseErrorResponse(resp *http.Response) error {
+	errResp := &errcode.ErrorResponse{
+		Method:     resp.Request.Method,
+		URL:        resp.Request.URL,
+		StatusCode: resp.StatusCode,
+	}
+	var body struct {
+		Errors errcode.Errors `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 8<<10)).Decode(&body); err == nil {
+		errResp.Errors = body.Errors
+	}
+	return errResp
+}
+
+// do sends the request, retrying once with a fresh token on an unexpected 401
+func (c *refreshingClient) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		// Only a success proves the credentials were accepted; a 404 or 5xx may be
+		// answered before authentication is checked
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			c.authenticated.Store(true)
+		}
+		return resp, nil
+	}
+
+	// A 401 before any success means the credentials are wrong, not expired
+	if !c.authenticated.Load() {
+		return resp, nil
+	}
+
+	// Only retry requests whose body can be replayed
+	retry := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return resp, nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	resp.Body.Close()
+
+	c.logger.Debug("Registry %s returned 401 after previous successful auth, refreshing token and retrying %s %s", req.URL.Host, req.Method, req.URL.Path)
+	c.cache.Reset()
+	return c.client.Do(retry)
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+func TestRefreshingClient(t *testing.T) {
+	tests := []struct {
+		name string
+		// statuses are answered in order, one per request the registry receives
+		statuses []int
+		// requests is how many requests the caller sends
+		requests int
+		// want is the status the last request ends with
+		want     int
+		wantHits int
+	}{
+		{name: "expired token is refreshed", statuses: []int{200, 401, 200}, requests: 2, want: 200, wantHits: 3},
+		{name: "401 before any success", statuses: []int{401}, requests: 1, want: 401, wantHits: 1},
+		{name: "404 doesn't prove authentication", statuses: []int{404, 401}, requests: 2, want: 401, wantHits: 2},
+		{name: "server error doesn't prove authentication", statuses: []int{503, 401}, requests: 2, want: 401, wantHits: 2},
+		{name: "refresh fails too", statuses: []int{200, 401, 401}, requests: 2, want: 401, wantHits: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			hits := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				mu.Lock()
+				status := tt.statuses[min(hits, len(tt.statuses)-1)]
+				hits++
+				mu.Unlock()
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			cache := newResettableCache()
+			c := &refreshingClient{
+				client: &auth.Client{Client: server.Client(), Cache: cache},
+				cache:  cache,
+				logger: nopLogger{},
+			}
+			var status int
+			for range tt.requests {
+				req, err := http.NewRequest(http.MethodGet, server.URL+"/v2/", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp, err := c.do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				status = resp.StatusCode
+			}
+			if status != tt.want {
+				t.Errorf("got status %d, want %d", status, tt.want)
+			}
+			if hits != tt.wantHits {
+				t.Errorf("registry got %d requests, want %d", hits, tt.wantHits)
+			}
+		})
+	}
+}
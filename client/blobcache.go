@@ -0,0 +1,138 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/sync/singleflight"
+	"oras.land/oras-go/v2/content"
+)
+
+// BlobCache keeps fetched layer blobs on disk, keyed by digest. Concurrent requests
+// for a blob that isn't cached yet share a single registry fetch.
+type BlobCache struct {
+	dir   string
+	group singleflight.Group
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// BlobCacheStats describes the blob cache. Hits and misses count lookups since the
+// cache was created; Entries and Bytes describe the blobs currently on disk.
+type BlobCacheStats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Entries int    `json:"entries"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// NewBlobCache creates a blob cache in dir, creating the directory if needed
+func NewBlobCache(dir string) (*BlobCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob cache directory: %w", err)
+	}
+	return &BlobCache{dir: dir}, nil
+}
+
+// WithBlobCache makes the client read layer blobs through cache
+func WithBlobCache(cache *BlobCache) Option {
+	return func(c *Client) {
+		c.blobCache = cache
+	}
+}
+
+// path returns where the blob for layer is stored
+func (b *BlobCache) path(layer v1.Descriptor) string {
+	return filepath.Join(b.dir, layer.Digest.Algorithm().String(), layer.Digest.Encoded())
+}
+
+// open returns the cached blob for layer, calling fetch to populate the cache when
+// it's missing. Callers that arrive while a fetch is running wait for it instead of
+// starting their own. Nothing is cached when the fetch fails or the content doesn't
+// match the descriptor, so the next request tries again.
+func (b *BlobCache) open(layer v1.Descriptor, fetch func() (io.ReadCloser, error)) (io.ReadCloser, error) {
+	if err := layer.Digest.Validate(); err != nil {
+		return nil, err
+	}
+	path := b.path(layer)
+	if f, err := os.Open(path); err == nil {
+		b.hits.Add(1)
+		return f, nil
+	}
+	b.misses.Add(1)
+
+	_, err, _ := b.group.Do(layer.Digest.String(), func() (interface{}, error) {
+		// An earlier fetch may have finished between the check above and now
+		if _, err := os.Stat(path); err == nil {
+			return nil, nil
+		}
+		return nil, b.store(layer, path, fetch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Stats walks the cache directory and returns the lookup counters with the number
+// and total size of cached blobs. Partially ingested blobs are not counted.
+func (b *BlobCache) Stats() BlobCacheStats {
+	stats := BlobCacheStats{Hits: b.hits.Load(), Misses: b.misses.Load()}
+	filepath.WalkDir(b.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || strings.HasPrefix(entry.Name(), ".ingest-") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		stats.Entries++
+		stats.Bytes += info.Size()
+		return nil
+	})
+	return stats
+}
+
+// store fetches the blob into a temporary file, verifies it and moves it into place
+func (b *BlobCache) store(layer v1.Descriptor, path string, fetch func() (io.ReadCloser, error)) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".ingest-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	rc, err := fetch()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	defer rc.Close()
+
+	verifier := content.NewVerifyReader(rc, layer)
+	if _, err := io.Copy(tmp, verifier); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := verifier.Verify(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+	return nil
+}
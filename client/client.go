@@ -2,44 +2,85 @@
 package client
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/opencontainers/go-digest"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
 	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
 	"oras.land/oras-go/v2/registry/remote"
 	"oras.land/oras-go/v2/registry/remote/auth"
-	"oras.land/oras-go/v2/registry/remote/retry"
 )
 
 type Client struct {
-	AuthClient  *auth.Client
-	Registry    string
-	MemoryStore *memory.Store
-	Context     context.Context
+	AuthClient *auth.Client
+	Registry   string
+	Context    context.Context
+	Logger     Logger
+
+	// httpClient wraps AuthClient to refresh expired tokens
+	httpClient *refreshingClient
+	// timings, when set, collects the duration of each registry call
+	timings *Timings
+	// blobCache, when set, stores layer blobs on disk and shares concurrent fetches
+	blobCache *BlobCache
+	// resumeAttempts caps how often a layer stream is re-opened after a read error
+	resumeAttempts int
+	// copyLimits tunes whole-artifact copies
+	copyLimits CopyLimits
+}
+
+// ValidateRegistry returns an error when registry isn't a valid registry host, such
+// as one given as a URL. NewClient fails for exactly these registries.
+func ValidateRegistry(registry string) error {
+	_, err := remote.NewRegistry(registry)
+	return err
 }
 
-func NewClient(registry string, username string, password string) ClientInterface {
-	dst := memory.New()
+// NewClient creates a client for a registry. It returns an error when the registry
+// name isn't a valid registry host, such as one given as a URL.
+func NewClient(registry string, username string, password string, opts ...Option) (ClientInterface, error) {
+	if err := ValidateRegistry(registry); err != nil {
+		return nil, err
+	}
+
 	ctx := context.Background()
+	cache := newResettableCache()
 	authClient := &auth.Client{
-		Client: retry.DefaultClient,
-		Cache:  auth.NewCache(),
+		Client: newHTTPClient(nil),
+		Cache:  cache,
 		Credential: auth.StaticCredential(registry, auth.Credential{
 			Username: username,
 			Password: password,
 		}),
 	}
-	return &Client{
-		AuthClient:  authClient,
-		Registry:    registry,
-		MemoryStore: dst,
-		Context:     ctx,
+	c := &Client{
+		AuthClient: authClient,
+		Registry:   registry,
+		Context:    ctx,
+		Logger:     nopLogger{},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.httpClient = &refreshingClient{
+		client:    authClient,
+		cache:     cache,
+		logger:    c.Logger,
+		registry:  registry,
+		anonymous: username == "" && password == "",
+	}
+	return c, nil
 }
 
 func (c *Client) GetRepository(repository string) (*remote.Repository, error) {
@@ -47,70 +88,265 @@ func (c *Client) GetRepository(repository string) (*remote.Repository, error) {
 	if err != nil {
 		return nil, err // Handle error
 	}
-	repo.Client = c.AuthClient
+	repo.Client = c.httpClient
 	return repo, nil
 }
 
+// GetRemoteRegistry returns an authenticated client for registry-level APIs
+func (c *Client) GetRemoteRegistry() (*remote.Registry, error) {
+	reg, err := remote.NewRegistry(c.Registry)
+	if err != nil {
+		return nil, err
+	}
+	reg.Client = c.httpClient
+	return reg, nil
+}
+
 // GetRegistry returns the registry URL configured for this client
 func (c *Client) GetRegistry() string {
 	return c.Registry
 }
+
+// WithContext returns a copy of the client whose registry calls use ctx, e.g. to
+// bound them by a request's deadline. The copy shares the original's connections
+// and tokens.
+func (c *Client) WithContext(ctx context.Context) ClientInterface {
+	bound := *c
+	bound.Context = ctx
+	return &bound
+}
 func (c *Client) GetDescriptor(repository string, tagName string) (*v1.Descriptor, error) {
-	src, err := c.GetRepository(repository)
+	desc, _, err := c.copyToStore(repository, tagName)
 	if err != nil {
 		return nil, err // Handle error
 	}
+	return desc, nil
+}
 
-	desc, err := oras.Copy(c.Context, src, tagName, c.MemoryStore, tagName, oras.DefaultCopyOptions)
+// copyToStore copies the artifact into a new in-memory store owned by the caller.
+// Each call gets its own store so concurrent requests for the same tag name (in the
+// same or different repositories) can't clobber each other's tag resolution, and
+// the copied content is released once the caller is done with it.
+func (c *Client) copyToStore(repository string, tagName string) (*v1.Descriptor, *memory.Store, error) {
+	src, err := c.GetRepository(repository)
 	if err != nil {
-		return nil, err // Handle error
+		return nil, nil, err // Handle error
+	}
+
+	store := memory.New()
+	defer c.observe("copy", repository+":"+tagName, time.Now())
+	desc, err := oras.Copy(c.Context, unsizedRepository{Repository: src, client: c}, tagName, store, tagName, c.copyOptions())
+	if err != nil {
+		return nil, nil, err // Handle error
+	}
+	return &desc, store, nil
+}
+
+// CopyToOCILayout copies the artifact reference points at, including its config and
+// every layer, into an OCI image layout rooted at dir. The layout's index.json
+// references the artifact by tagName.
+func (c *Client) CopyToOCILayout(repository, reference, tagName, dir string) (*v1.Descriptor, error) {
+	src, err := c.GetRepository(repository)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := oci.New(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer c.observe("copy", repository+":"+tagName, time.Now())
+	desc, err := oras.Copy(c.Context, unsizedRepository{Repository: src, client: c}, reference, store, tagName, c.copyOptions())
+	if err != nil {
+		return nil, err
 	}
 	return &desc, nil
 }
+
 func (c *Client) GetManifest(repository string, tagName string) ([]byte, error) {
-	desc, err := c.GetDescriptor(repository, tagName)
+	_, content, err := c.GetManifestWithDescriptor(repository, tagName)
+	return content, err
+}
+
+// GetManifestWithDescriptor returns the manifest bytes together with the descriptor
+// they were resolved from, so callers needing both only copy the artifact once
+func (c *Client) GetManifestWithDescriptor(repository string, tagName string) (*v1.Descriptor, []byte, error) {
+	desc, store, err := c.copyToStore(repository, tagName)
 	if err != nil {
-		return nil, err // Handle error
+		return nil, nil, err // Handle error
 	}
-	content, err := c.MemoryStore.Fetch(c.Context, *desc)
+	content, err := store.Fetch(c.Context, *desc)
 	if err != nil {
-		return nil, err // Handle error
+		return nil, nil, err // Handle error
 	}
+	defer content.Close()
 	readContent, err := io.ReadAll(content)
 	if err != nil {
-		return nil, err // Handle error
+		return nil, nil, err // Handle error
 	}
-	return readContent, nil
+	return desc, readContent, nil
 }
-func (c *Client) GetFirstLayerReader(repository, tagName string) (LayerInfoInterface, error) {
-	manifestBytes, err := c.GetManifest(repository, tagName)
+
+// FetchManifest fetches just the manifest for a reference, without copying its
+// config or layers, for callers that only need to inspect manifest fields. The
+// content is verified against the returned descriptor.
+func (c *Client) FetchManifest(repository, reference string) (*v1.Descriptor, []byte, error) {
+	repo, err := c.GetRepository(repository)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start := time.Now()
+	desc, reader, err := repo.FetchReference(c.Context, reference)
+	c.observe("fetch", repository+":"+reference, start)
+	if err != nil {
+		return nil, nil, wrapManifestError(err)
+	}
+	defer reader.Close()
+
+	manifest, err := c.readManifest(reader, &desc, repository+":"+reference)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &desc, manifest, nil
+}
+
+// GetManifestReader fetches the manifest for a reference as a stream, for callers
+// that pass it on without parsing it. Manifests larger than maxUnsizedManifestBytes
+// are refused, and the content is verified against the returned descriptor as it
+// is read: the final byte is held back until the whole manifest has verified, so a
+// corrupted manifest is never read in full. A manifest the registry reports
+// without a size is read whole first, as FetchManifest does, since it can't be
+// verified as it streams.
+func (c *Client) GetManifestReader(repository, reference string) (*v1.Descriptor, io.ReadCloser, error) {
+	repo, err := c.GetRepository(repository)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target := repository + ":" + reference
+	start := time.Now()
+	desc, reader, err := repo.FetchReference(c.Context, reference)
+	c.observe("fetch", target, start)
+	if err != nil {
+		return nil, nil, wrapManifestError(err)
+	}
+
+	switch {
+	case desc.Size > maxUnsizedManifestBytes:
+		reader.Close()
+		return nil, nil, fmt.Errorf("manifest %s: %w: %d bytes is more than %d", target, errdef.ErrSizeExceedsLimit, desc.Size, maxUnsizedManifestBytes)
+	case desc.Size <= 0:
+		defer reader.Close()
+		manifest, err := c.readManifest(reader, &desc, target)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &desc, io.NopCloser(bytes.NewReader(manifest)), nil
+	}
+	return &desc, newManifestReader(reader, desc), nil
+}
+
+// manifestReader streams a manifest, verifying its size and digest before the
+// final byte is returned
+type manifestReader struct {
+	verifier *content.VerifyReader
+	io.Closer
+	// remaining counts the bytes still to be read before the manifest can be verified
+	remaining int64
+	err       error
+}
+
+// newManifestReader wraps rc, which must carry exactly the content desc describes
+func newManifestReader(rc io.ReadCloser, desc v1.Descriptor) *manifestReader {
+	return &manifestReader{verifier: content.NewVerifyReader(rc, desc), Closer: rc, remaining: desc.Size}
+}
+
+// Read implements io.Reader. A manifest that fails to verify ends with an error
+// one byte short, so a caller passing it on with its Content-Length can't send it
+// complete.
+func (r *manifestReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.verifier.Read(p)
+	r.remaining -= int64(n)
+	if n > 0 && r.remaining == 0 {
+		if verifyErr := r.verifier.Verify(); verifyErr != nil {
+			r.err = verifyErr
+			return n - 1, verifyErr
+		}
+	}
+	return n, err
+}
+
+// getImageManifest fetches and parses a manifest for operations that need its config
+// or layers. Only the manifest is fetched; callers fetch the blobs they need. Returns
+// ErrUnsupportedManifestType for other manifest types rather than failing to parse them.
+func (c *Client) getImageManifest(repository, tagName string) (*Manifest, error) {
+	desc, content, err := c.FetchManifest(repository, tagName)
 	if err != nil {
 		return nil, err
 	}
+	if err := CheckManifestMediaType(desc.MediaType); err != nil {
+		return nil, err
+	}
+	return ParseManifest(content)
+}
 
-	var manifest struct {
-		Layers []struct {
-			Digest      string            `json:"digest"`
-			Size        int64             `json:"size"`
-			MediaType   string            `json:"mediaType"`
-			Annotations map[string]string `json:"annotations"`
-		} `json:"layers"`
+func (c *Client) GetFirstLayerReader(repository, tagName string) (LayerInfoInterface, error) {
+	return c.GetLayerReader(repository, tagName, LayerSelector{})
+}
+
+// GetLayerReaderByTitle returns a reader for the layer whose title annotation
+// matches title. Returns ErrLayerNotFound when no layer has that title.
+func (c *Client) GetLayerReaderByTitle(repository, tagName, title string) (LayerInfoInterface, error) {
+	return c.GetLayerReader(repository, tagName, LayerSelector{Title: title})
+}
+
+// GetLayerReader returns a reader for the layer picked by selector. Returns
+// ErrLayerNotFound when no layer matches.
+func (c *Client) GetLayerReader(repository, tagName string, selector LayerSelector) (LayerInfoInterface, error) {
+	manifest, err := c.getImageManifest(repository, tagName)
+	if err != nil {
+		return nil, err
 	}
-	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+	layer, err := manifest.SelectLayer(selector)
+	if err != nil {
 		return nil, err
 	}
-	if len(manifest.Layers) == 0 {
-		return nil, fmt.Errorf("no layers found in manifest")
+
+	return c.fetchLayer(repository, layer)
+}
+
+// FetchBlob returns a reader for the blob with the given digest, whether it is a
+// layer, a config or a referrer's blob. Returns ErrBlobNotFound when the
+// repository has no such blob.
+func (c *Client) FetchBlob(repository string, dgst digest.Digest) (LayerInfoInterface, error) {
+	repo, err := c.GetRepository(repository)
+	if err != nil {
+		return nil, err
 	}
 
-	layerDigest := manifest.Layers[0].Digest
+	// Resolve the blob first so the fetch knows its size
+	start := time.Now()
+	desc, err := repo.Blobs().Resolve(c.Context, dgst.String())
+	c.observe("resolve", repository+"@"+dgst.String(), start)
+	if errors.Is(err, errdef.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrBlobNotFound, dgst)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.fetchLayer(repository, desc)
+}
 
+// fetchLayer opens a stream over a layer blob
+func (c *Client) fetchLayer(repository string, layer v1.Descriptor) (LayerInfoInterface, error) {
 	// Get the filename from the layer's annotations if available
 	filename := "plugin.zip" // Default filename
-	if manifest.Layers[0].Annotations != nil {
-		if title, ok := manifest.Layers[0].Annotations["org.opencontainers.image.title"]; ok && title != "" {
-			filename = title
-		}
+	if title, ok := layer.Annotations[v1.AnnotationTitle]; ok && title != "" {
+		filename = title
 	}
 
 	// Connect to the remote repository
@@ -119,16 +355,32 @@ func (c *Client) GetFirstLayerReader(repository, tagName string) (LayerInfoInter
 		return nil, err
 	}
 
-	// Prepare the descriptor for the layer we want to fetch.
-	// Needed else you get mismatch Content-Length errors.
-	desc := v1.Descriptor{
-		MediaType: manifest.Layers[0].MediaType,
-		Digest:    digest.Digest(layerDigest),
-		Size:      manifest.Layers[0].Size,
+	// Fetch the blob directly using the layer descriptor (which carries the
+	// expected size, else you get mismatch Content-Length errors) - this
+	// returns an io.ReadCloser we can stream
+	target := repository + "@" + layer.Digest.String()
+	fetch := func() (io.ReadCloser, error) {
+		start := time.Now()
+		rc, err := repo.Fetch(c.Context, layer)
+		c.observe("fetch", target, start)
+		if err != nil || c.resumeAttempts <= 0 {
+			return rc, err
+		}
+		// Pick up where a dropped connection left off rather than failing the download
+		return &resumingReader{
+			rc:          rc,
+			open:        func() (io.ReadCloser, error) { return repo.Fetch(c.Context, layer) },
+			maxAttempts: c.resumeAttempts,
+			target:      target,
+			logger:      c.Logger,
+		}, nil
+	}
+	var content io.ReadCloser
+	if c.blobCache != nil {
+		content, err = c.blobCache.open(layer, fetch)
+	} else {
+		content, err = fetch()
 	}
-
-	// Fetch the blob directly - this returns an io.ReadCloser we can stream
-	content, err := repo.Fetch(c.Context, desc)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch blob: %v", err)
 	}
@@ -137,25 +389,192 @@ func (c *Client) GetFirstLayerReader(repository, tagName string) (LayerInfoInter
 	return &LayerInfo{
 		Reader:    content,
 		Filename:  filename,
-		MediaType: manifest.Layers[0].MediaType,
-		Size:      manifest.Layers[0].Size,
+		MediaType: layer.MediaType,
+		Size:      layer.Size,
+		Digest:    layer.Digest,
 	}, nil
 }
 
-// ListTags returns all tags for a given repository
-func (c *Client) ListTags(repository string) ([]string, error) {
+// GetConfigBlob returns the config blob referenced by the manifest along with its descriptor.
+// The empty config (application/vnd.oci.empty.v1+json) is returned as "{}" without a fetch.
+func (c *Client) GetConfigBlob(repository, tagName string) ([]byte, *v1.Descriptor, error) {
+	manifest, err := c.getImageManifest(repository, tagName)
+	if err != nil {
+		return nil, nil, err
+	}
+	config := manifest.Config
+
+	// The empty config has well-known content so there is nothing to fetch
+	if config.MediaType == v1.MediaTypeEmptyJSON {
+		return []byte("{}"), &config, nil
+	}
+
+	// Connect to the remote repository
+	repo, err := c.GetRepository(repository)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start := time.Now()
+	content, err := repo.Fetch(c.Context, config)
+	c.observe("fetch", repository+"@"+config.Digest.String(), start)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch config blob: %w", err)
+	}
+	defer content.Close()
+
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, &config, nil
+}
+
+// ListReferrers returns the descriptors of all manifests whose subject is the given
+// reference, as reported by the registry's referrers API (or the referrers tag
+// schema fallback). Referrer descriptors carry their manifest annotations.
+func (c *Client) ListReferrers(repository, reference string) ([]v1.Descriptor, error) {
 	repo, err := c.GetRepository(repository)
 	if err != nil {
 		return nil, err
 	}
 
-	var tags []string
-	err = repo.Tags(c.Context, "", func(receivedTags []string) error {
-		tags = append(tags, receivedTags...)
+	defer c.observe("referrers", repository+":"+reference, time.Now())
+	desc, err := repo.Resolve(c.Context, reference)
+	if err != nil {
+		return nil, wrapManifestError(err)
+	}
+
+	referrers := make([]v1.Descriptor, 0)
+	err = repo.Referrers(c.Context, desc, "", func(received []v1.Descriptor) error {
+		referrers = append(referrers, received...)
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
+	return referrers, nil
+}
+
+// ResolveDescriptor resolves a tag or digest reference to its manifest descriptor
+// without fetching the manifest content
+func (c *Client) ResolveDescriptor(repository, reference string) (*v1.Descriptor, error) {
+	repo, err := c.GetRepository(repository)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	desc, err := repo.Resolve(c.Context, reference)
+	c.observe("resolve", repository+":"+reference, start)
+	if err != nil {
+		return nil, wrapManifestError(err)
+	}
+	return &desc, nil
+}
+
+// ListTags returns the tags for a given repository. When last is set, listing resumes
+// after that tag, which the registry passes to the distribution API's last parameter:
+// only tags lexically after last are returned, excluding last itself.
+func (c *Client) ListTags(repository, last string) ([]string, error) {
+	repo, err := c.GetRepository(repository)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start from an empty list so a repository without tags isn't reported as nil
+	tags := []string{}
+	defer c.observe("tags", repository, time.Now())
+	err = repo.Tags(c.Context, last, func(receivedTags []string) error {
+		tags = append(tags, receivedTags...)
+		return nil
+	})
+	if err != nil {
+		return nil, wrapRepositoryError(err)
+	}
 	return tags, nil
 }
+
+// PingResult describes what a registry advertised on its /v2/ base endpoint
+type PingResult struct {
+	// AuthScheme is the scheme the registry challenges anonymous clients with:
+	// "basic", "bearer", "none" when it allows anonymous access, or "" when unknown
+	AuthScheme string
+}
+
+// Ping checks that the registry speaks the v2 API, notes the auth scheme it
+// advertises, and checks that the configured credentials are accepted. The result
+// is filled in as far as the check got, so the scheme is known even when the
+// credentials are rejected.
+func (c *Client) Ping(ctx context.Context) (PingResult, error) {
+	var result PingResult
+	reg, err := c.GetRemoteRegistry()
+	if err != nil {
+		return result, err
+	}
+
+	// Ask anonymously first, so the registry answers with its challenge
+	url := fmt.Sprintf("https://%s/v2/", reg.Reference.Host())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return result, err
+	}
+	resp, err := c.AuthClient.Client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		result.AuthScheme = "none"
+	case http.StatusUnauthorized:
+		result.AuthScheme = challengeScheme(resp.Header.Get("WWW-Authenticate"))
+	}
+	c.Logger.Debug("Registry %s answered %s with %d, auth scheme %q", c.Registry, url, resp.StatusCode, result.AuthScheme)
+
+	// Then with credentials, which also catches registries that aren't v2 at all
+	if err := reg.Ping(ctx); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// challengeScheme returns the lowercased auth scheme of a WWW-Authenticate header
+func challengeScheme(header string) string {
+	scheme, _, _ := strings.Cut(strings.TrimSpace(header), " ")
+	return strings.ToLower(scheme)
+}
+
+// errListComplete stops a catalog listing once enough repositories have been collected
+var errListComplete = errors.New("list complete")
+
+// ListRepositories returns up to limit repository names from the registry catalog,
+// starting after last. A limit of zero or less returns every repository.
+// Returns ErrCatalogUnsupported when the registry doesn't offer the _catalog API.
+func (c *Client) ListRepositories(last string, limit int) ([]string, error) {
+	reg, err := c.GetRemoteRegistry()
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 {
+		reg.RepositoryListPageSize = limit
+	}
+
+	repositories := make([]string, 0)
+	defer c.observe("catalog", "_catalog", time.Now())
+	err = reg.Repositories(c.Context, last, func(received []string) error {
+		repositories = append(repositories, received...)
+		if limit > 0 && len(repositories) >= limit {
+			return errListComplete
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errListComplete) {
+		return nil, wrapCatalogError(err)
+	}
+
+	if limit > 0 && len(repositories) > limit {
+		repositories = repositories[:limit]
+	}
+	return repositories, nil
+}
@@ -0,0 +1,299 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// testRegistry serves manifests, blobs and tags from memory over the distribution API
+type testRegistry struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// manifests are keyed by repository, then by tag and by digest
+	manifests map[string]map[string][]byte
+	// mediaTypes holds the Content-Type each manifest is served with
+	mediaTypes map[digest.Digest]string
+	blobs      map[digest.Digest][]byte
+}
+
+// newTestRegistry starts an empty TLS registry, closed when the test ends
+func newTestRegistry(t *testing.T) *testRegistry {
+	t.Helper()
+	r := &testRegistry{
+		manifests:  make(map[string]map[string][]byte),
+		mediaTypes: make(map[digest.Digest]string),
+		blobs:      make(map[digest.Digest][]byte),
+	}
+	r.Server = httptest.NewTLSServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// client returns a client for the registry
+func (r *testRegistry) client(t *testing.T, opts ...Option) *Client {
+	t.Helper()
+	opts = append([]Option{WithTransport(r.Client().Transport)}, opts...)
+	c, err := NewClient(r.Listener.Addr().String(), "", "", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*Client)
+}
+
+// addBlob stores content as a blob, returning its descriptor
+func (r *testRegistry) addBlob(mediaType string, content []byte) v1.Descriptor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	desc := v1.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(content), Size: int64(len(content))}
+	r.blobs[desc.Digest] = content
+	return desc
+}
+
+// addManifest stores an image manifest with an empty config and the given layers in
+// repository under each tag and its digest, returning its descriptor
+func (r *testRegistry) addManifest(repository string, layers []v1.Descriptor, tags ...string) v1.Descriptor {
+	config := r.addBlob(v1.MediaTypeEmptyJSON, []byte("{}"))
+	manifest, err := json.Marshal(v1.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: v1.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    layers,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return r.addRawManifest(repository, v1.MediaTypeImageManifest, manifest, tags...)
+}
+
+// addRawManifest stores content in repository under each tag and its digest
+func (r *testRegistry) addRawManifest(repository, mediaType string, content []byte, tags ...string) v1.Descriptor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.manifests[repository] == nil {
+		r.manifests[repository] = make(map[string][]byte)
+	}
+	desc := v1.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(content), Size: int64(len(content))}
+	r.manifests[repository][desc.Digest.String()] = content
+	r.mediaTypes[desc.Digest] = mediaType
+	for _, tag := range tags {
+		r.manifests[repository][tag] = content
+	}
+	return desc
+}
+
+// serve implements the manifest, blob and tag list endpoints of the distribution API
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var content []byte
+	mediaType := "application/octet-stream"
+	switch {
+	case path == "":
+		return
+	case strings.Contains(path, "/manifests/"):
+		repository, reference, _ := strings.Cut(path, "/manifests/")
+		found, ok := r.manifests[repository][reference]
+		if !ok {
+			writeRegistryError(w, http.StatusNotFound, "MANIFEST_UNKNOWN")
+			return
+		}
+		content = found
+		mediaType = r.mediaTypes[digest.FromBytes(content)]
+	case strings.Contains(path, "/blobs/"):
+		_, encoded, _ := strings.Cut(path, "/blobs/")
+		found, ok := r.blobs[digest.Digest(encoded)]
+		if !ok {
+			writeRegistryError(w, http.StatusNotFound, "BLOB_UNKNOWN")
+			return
+		}
+		content = found
+	case strings.HasSuffix(path, "/tags/list"):
+		repository := strings.TrimSuffix(path, "/tags/list")
+		references, ok := r.manifests[repository]
+		if !ok {
+			writeRegistryError(w, http.StatusNotFound, "NAME_UNKNOWN")
+			return
+		}
+		list := struct {
+			Name string   `json:"name"`
+			Tags []string `json:"tags"`
+		}{Name: repository}
+		for reference := range references {
+			if _, err := digest.Parse(reference); err != nil {
+				list.Tags = append(list.Tags, reference)
+			}
+		}
+		content, _ = json.Marshal(list)
+		mediaType = "application/json"
+	default:
+		writeRegistryError(w, http.StatusNotFound, "NAME_UNKNOWN")
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Docker-Content-Digest", digest.FromBytes(content).String())
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	if req.Method == http.MethodGet {
+		w.Write(content)
+	}
+}
+
+// writeRegistryError writes a distribution API error response
+func writeRegistryError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"code": code, "message": strings.ToLower(code)}},
+	})
+}
+
+func TestManifestReader(t *testing.T) {
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	desc := v1.Descriptor{
+		MediaType: v1.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(manifest),
+		Size:      int64(len(manifest)),
+	}
+	corrupted := bytes.Clone(manifest)
+	corrupted[len(corrupted)-1] = ']'
+
+	tests := []struct {
+		name    string
+		content []byte
+		wantErr bool
+		// wantLen is how many bytes the reader hands out before failing or ending
+		wantLen int
+	}{
+		{name: "valid", content: manifest, wantLen: len(manifest)},
+		{name: "corrupted", content: corrupted, wantErr: true, wantLen: len(manifest) - 1},
+		{name: "trailing data", content: append(bytes.Clone(manifest), ' '), wantErr: true, wantLen: len(manifest) - 1},
+		{name: "short", content: manifest[:10], wantErr: true, wantLen: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := newManifestReader(io.NopCloser(bytes.NewReader(tt.content)), desc)
+			// A small buffer makes the reader verify partway through a read, as
+			// well as on the final one
+			var out bytes.Buffer
+			_, err := io.CopyBuffer(&out, struct{ io.Reader }{reader}, make([]byte, 7))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if out.Len() != tt.wantLen {
+				t.Errorf("read %d bytes, want %d", out.Len(), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestManifestCopiesAreIsolated(t *testing.T) {
+	registry := newTestRegistry(t)
+	// Every repository has a different manifest under the same tag
+	tests := []struct {
+		repository string
+		layer      string
+	}{
+		{repository: "team/a", layer: "a"},
+		{repository: "team/b", layer: "b"},
+		{repository: "other/a", layer: "c"},
+	}
+	want := make(map[string]digest.Digest)
+	for _, tt := range tests {
+		layer := registry.addBlob("application/zip", []byte(tt.layer))
+		want[tt.repository] = registry.addManifest(tt.repository, []v1.Descriptor{layer}, "1.0.0").Digest
+	}
+	c := registry.client(t)
+
+	for _, tt := range tests {
+		t.Run(tt.repository, func(t *testing.T) {
+			t.Parallel()
+			for range 5 {
+				desc, store, err := c.copyToStore(tt.repository, "1.0.0")
+				if err != nil {
+					t.Fatal(err)
+				}
+				// The tag in the caller's store must resolve to this repository's manifest
+				tagged, err := store.Resolve(context.Background(), "1.0.0")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if desc.Digest != want[tt.repository] || tagged.Digest != want[tt.repository] {
+					t.Fatalf("got manifest %s tagged %s, want %s", desc.Digest, tagged.Digest, want[tt.repository])
+				}
+			}
+		})
+	}
+}
+
+func TestNewClientValidatesRegistry(t *testing.T) {
+	tests := []struct {
+		registry string
+		wantErr  bool
+	}{
+		{registry: "ghcr.io"},
+		{registry: "localhost:5000"},
+		{registry: "https://ghcr.io", wantErr: true},
+		{registry: "ghcr.io/team", wantErr: true},
+		{registry: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.registry, func(t *testing.T) {
+			c, err := NewClient(tt.registry, "", "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if (c == nil) != tt.wantErr {
+				t.Errorf("got client %v alongside error %v", c, err)
+			}
+		})
+	}
+}
+
+func TestListTags(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.addManifest("team/app", nil, "1.0.0")
+	// Reachable by digest only, so the repository exists without tags
+	registry.addManifest("team/untagged", nil)
+	c := registry.client(t)
+
+	tests := []struct {
+		repository string
+		want       []string
+		wantErr    error
+	}{
+		{repository: "team/app", want: []string{"1.0.0"}},
+		{repository: "team/untagged", want: []string{}},
+		{repository: "team/missing", wantErr: ErrRepositoryNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.repository, func(t *testing.T) {
+			tags, err := c.ListTags(tt.repository, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if tags == nil || !slices.Equal(tags, tt.want) {
+				t.Errorf("got %#v, want %v", tags, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+)
+
+// DefaultMaxIndexDepth is how many levels of image indexes nested below the one
+// being copied a copy follows when CopyLimits.MaxIndexDepth is zero
+const DefaultMaxIndexDepth = 3
+
+// CopyLimits tunes the ORAS copies that pull a whole artifact, such as the
+// descriptor lookup and the OCI layout export. Zero values keep the ORAS defaults.
+type CopyLimits struct {
+	// Concurrency is the number of blobs fetched in parallel (ORAS default 3)
+	Concurrency int
+	// MaxMetadataBytes caps the size of manifests and indexes read during a copy
+	// (ORAS default 4 MiB)
+	MaxMetadataBytes int64
+	// MaxBytes caps the combined size of everything a single copy fetches; zero is unlimited
+	MaxBytes int64
+	// MaxIndexDepth caps how many levels of image indexes nested below the copied
+	// one are followed; zero means DefaultMaxIndexDepth
+	MaxIndexDepth int
+}
+
+// WithCopyLimits sets the limits applied to whole-artifact copies
+func WithCopyLimits(limits CopyLimits) Option {
+	return func(c *Client) {
+		c.copyLimits = limits
+	}
+}
+
+// copyOptions returns the ORAS copy options for a single copy. The MaxBytes guard
+// keeps a running total, so each copy needs its own options.
+func (c *Client) copyOptions() oras.CopyOptions {
+	opts := oras.DefaultCopyOptions
+	if c.copyLimits.Concurrency > 0 {
+		opts.Concurrency = c.copyLimits.Concurrency
+	}
+	if c.copyLimits.MaxMetadataBytes > 0 {
+		opts.MaxMetadataBytes = c.copyLimits.MaxMetadataBytes
+	}
+	if maxBytes := c.copyLimits.MaxBytes; maxBytes > 0 {
+		var total atomic.Int64
+		opts.PreCopy = func(ctx context.Context, desc v1.Descriptor) error {
+			if copied := total.Add(desc.Size); copied > maxBytes {
+				return fmt.Errorf("%w: more than %d bytes", ErrArtifactTooLarge, maxBytes)
+			}
+			return nil
+		}
+	}
+	maxDepth := c.copyLimits.MaxIndexDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxIndexDepth
+	}
+	depths := &indexDepths{max: maxDepth, depths: make(map[digest.Digest]int)}
+	opts.FindSuccessors = func(ctx context.Context, fetcher content.Fetcher, desc v1.Descriptor) ([]v1.Descriptor, error) {
+		successors, err := content.Successors(ctx, fetcher, desc)
+		if err != nil {
+			return nil, err
+		}
+		return successors, depths.descend(desc, successors)
+	}
+	return opts
+}
+
+// indexDepths tracks how deeply each index met during a copy is nested, so indexes
+// pointing at indexes, however crafted, can't make a copy follow them without end
+type indexDepths struct {
+	max int
+
+	mu     sync.Mutex
+	depths map[digest.Digest]int
+}
+
+// descend records the depth of the indexes among node's successors, failing with
+// ErrIndexTooDeep once they are nested more than max levels below the root
+func (d *indexDepths) descend(node v1.Descriptor, successors []v1.Descriptor) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	depth := d.depths[node.Digest] + 1
+	for _, successor := range successors {
+		if !isIndexMediaType(successor.MediaType) {
+			continue
+		}
+		if depth > d.max {
+			return fmt.Errorf("%w: %s is nested more than %d levels deep", ErrIndexTooDeep, successor.Digest, d.max)
+		}
+		d.depths[successor.Digest] = max(d.depths[successor.Digest], depth)
+	}
+	return nil
+}
+
+// isIndexMediaType reports whether mediaType is an OCI image index or a Docker
+// manifest list
+func isIndexMediaType(mediaType string) bool {
+	return mediaType == v1.MediaTypeImageIndex || mediaType == MediaTypeDockerManifestList
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/memory"
+)
+
+// pushJSON stores value as JSON in store, returning its descriptor
+func pushJSON(t *testing.T, store *memory.Store, mediaType string, value interface{}) v1.Descriptor {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := v1.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data), Size: int64(len(data))}
+	if err := store.Push(context.Background(), desc, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	return desc
+}
+
+// nestedIndexes stores an image manifest wrapped in levels image indexes, tagging
+// the outermost one "nested"
+func nestedIndexes(t *testing.T, levels int) *memory.Store {
+	t.Helper()
+	store := memory.New()
+	config := pushJSON(t, store, v1.MediaTypeEmptyJSON, struct{}{})
+	desc := pushJSON(t, store, v1.MediaTypeImageManifest, v1.Manifest{
+		MediaType: v1.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []v1.Descriptor{},
+	})
+	for range levels {
+		desc = pushJSON(t, store, v1.MediaTypeImageIndex, v1.Index{
+			MediaType: v1.MediaTypeImageIndex,
+			Manifests: []v1.Descriptor{desc},
+		})
+	}
+	if err := store.Tag(context.Background(), desc, "nested"); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestCopyIndexDepth(t *testing.T) {
+	tests := []struct {
+		name     string
+		levels   int
+		maxDepth int
+		wantErr  bool
+	}{
+		{name: "manifest only", levels: 0},
+		{name: "single index", levels: 1},
+		{name: "at default limit", levels: DefaultMaxIndexDepth + 1},
+		{name: "beyond default limit", levels: DefaultMaxIndexDepth + 2, wantErr: true},
+		{name: "at configured limit", levels: 2, maxDepth: 1},
+		{name: "beyond configured limit", levels: 3, maxDepth: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{copyLimits: CopyLimits{MaxIndexDepth: tt.maxDepth}}
+			_, err := oras.Copy(context.Background(), nestedIndexes(t, tt.levels), "nested", memory.New(), "nested", c.copyOptions())
+			if tt.wantErr != errors.Is(err, ErrIndexTooDeep) {
+				t.Errorf("got error %v, want ErrIndexTooDeep %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIndexDepthsSelfReference(t *testing.T) {
+	// An index listing itself can't be stored under its own digest, so walk the
+	// graph by hand as a registry serving one would make a copy do
+	self := v1.Descriptor{MediaType: v1.MediaTypeImageIndex, Digest: digest.FromString("self")}
+	depths := &indexDepths{max: DefaultMaxIndexDepth, depths: make(map[digest.Digest]int)}
+	for i := 0; i < DefaultMaxIndexDepth; i++ {
+		if err := depths.descend(self, []v1.Descriptor{self}); err != nil {
+			t.Fatalf("descent %d: unexpected error %v", i, err)
+		}
+	}
+	if err := depths.descend(self, []v1.Descriptor{self}); !errors.Is(err, ErrIndexTooDeep) {
+		t.Errorf("got error %v, want ErrIndexTooDeep", err)
+	}
+}
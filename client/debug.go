@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxBaseDebugBody caps how much of the /v2/ response body DebugBase reads
+const maxBaseDebugBody = 64 << 10
+
+// sensitiveHeaders are response headers DebugBase never reports as sent, since
+// they can carry credentials or session state
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Set-Cookie", "Cookie"}
+
+// BaseResponse is a registry's raw answer on its /v2/ base endpoint, for
+// troubleshooting authentication
+type BaseResponse struct {
+	// Challenge is the WWW-Authenticate header the registry sent to an anonymous request
+	Challenge string
+	// AuthScheme is the challenge's scheme, as in PingResult
+	AuthScheme string
+	// AnonymousStatus is the status code of the anonymous request
+	AnonymousStatus int
+	// StatusCode, Header and Body are the answer to the request made with the
+	// configured credentials, after any token exchange
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Truncated reports whether Body was cut short at 64 KiB
+	Truncated bool
+}
+
+// DebugBase requests /v2/ anonymously for the registry's challenge, then again
+// with the configured credentials, and returns the raw answer with credential
+// headers redacted. Unlike Ping it doesn't turn error statuses into errors; an
+// error means no response was received, e.g. because the token exchange failed,
+// and the anonymous part of the result is still filled in.
+func (c *Client) DebugBase(ctx context.Context) (*BaseResponse, error) {
+	result := &BaseResponse{}
+	reg, err := c.GetRemoteRegistry()
+	if err != nil {
+		return result, err
+	}
+	url := fmt.Sprintf("https://%s/v2/", reg.Reference.Host())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return result, err
+	}
+	anonymous, err := c.AuthClient.Client.Do(req)
+	if err != nil {
+		return result, wrapTransportError(err)
+	}
+	anonymous.Body.Close()
+	result.AnonymousStatus = anonymous.StatusCode
+	result.Challenge = anonymous.Header.Get("WWW-Authenticate")
+	switch anonymous.StatusCode {
+	case http.StatusOK:
+		result.AuthScheme = "none"
+	case http.StatusUnauthorized:
+		result.AuthScheme = challengeScheme(result.Challenge)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return result, err
+	}
+	resp, err := c.AuthClient.Do(req)
+	if err != nil {
+		return result, wrapTransportError(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBaseDebugBody+1))
+	if err != nil {
+		return result, err
+	}
+	if len(body) > maxBaseDebugBody {
+		body = body[:maxBaseDebugBody]
+		result.Truncated = true
+	}
+	result.StatusCode = resp.StatusCode
+	result.Header = resp.Header.Clone()
+	for _, name := range sensitiveHeaders {
+		if result.Header.Get(name) != "" {
+			result.Header.Set(name, "***")
+		}
+	}
+	result.Body = body
+	c.Logger.Debug("Registry %s answered %s with %d anonymously and %d with credentials", c.Registry, url, result.AnonymousStatus, result.StatusCode)
+	return result, nil
+}
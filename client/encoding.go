@@ -0,0 +1,73 @@
+package client
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// decodingTransport decompresses gzip-encoded registry responses that Go's
+// transport left encoded: those it didn't ask to be compressed, such as responses
+// to requests with a Range header or from registries that compress regardless of
+// Accept-Encoding. ORAS verifies manifests and blobs against their digests, which
+// are computed over the uncompressed bytes, so encoded bodies would fail to verify.
+type decodingTransport struct {
+	next http.RoundTripper
+}
+
+// newDecodingTransport wraps next, using http.DefaultTransport when it is nil
+func newDecodingTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &decodingTransport{next: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *decodingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.Uncompressed || req.Method == http.MethodHead {
+		return resp, err
+	}
+	// Partial content is a range of the encoded bytes, which can't be decoded on its own
+	if resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusNoContent {
+		return resp, err
+	}
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+	default:
+		return resp, err
+	}
+
+	resp.Body = &gzipBody{body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// gzipBody decompresses a response body, reading the gzip header on first use so
+// RoundTrip doesn't block on the body
+type gzipBody struct {
+	body   io.ReadCloser
+	reader *gzip.Reader
+	err    error
+}
+
+// Read implements io.Reader
+func (b *gzipBody) Read(p []byte) (int, error) {
+	if b.reader == nil && b.err == nil {
+		b.reader, b.err = gzip.NewReader(b.body)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.reader.Read(p)
+}
+
+// Close implements io.Closer
+func (b *gzipBody) Close() error {
+	return b.body.Close()
+}
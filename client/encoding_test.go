@@ -0,0 +1,95 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestDecodingTransport(t *testing.T) {
+	const body = `{"schemaVersion":2}`
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(body))
+	zw.Close()
+
+	tests := []struct {
+		name         string
+		method       string
+		status       int
+		encoding     string
+		uncompressed bool
+		content      []byte
+		want         string
+		wantEncoding string
+		wantErr      bool
+	}{
+		{name: "gzip", encoding: "gzip", content: compressed.Bytes(), want: body},
+		{name: "x-gzip", encoding: " X-Gzip ", content: compressed.Bytes(), want: body},
+		{name: "identity", content: []byte(body), want: body},
+		{name: "unknown encoding", encoding: "br", content: []byte("opaque"), want: "opaque", wantEncoding: "br"},
+		{name: "already decoded by Go", encoding: "gzip", uncompressed: true, content: []byte(body), want: body},
+		{name: "partial content", status: http.StatusPartialContent, encoding: "gzip", content: compressed.Bytes()[:10], want: string(compressed.Bytes()[:10]), wantEncoding: "gzip"},
+		{name: "HEAD", method: http.MethodHead, encoding: "gzip", want: "", wantEncoding: "gzip"},
+		{name: "corrupt gzip", encoding: "gzip", content: []byte("not gzip"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := newDecodingTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				resp := &http.Response{
+					StatusCode:    http.StatusOK,
+					Header:        http.Header{},
+					Body:          io.NopCloser(bytes.NewReader(tt.content)),
+					ContentLength: int64(len(tt.content)),
+					Uncompressed:  tt.uncompressed,
+					Request:       req,
+				}
+				if tt.status != 0 {
+					resp.StatusCode = tt.status
+				}
+				if tt.encoding != "" && !tt.uncompressed {
+					resp.Header.Set("Content-Encoding", tt.encoding)
+				}
+				return resp, nil
+			}))
+
+			method := http.MethodGet
+			if tt.method != "" {
+				method = tt.method
+			}
+			req, err := http.NewRequest(method, "https://registry.example/v2/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			got, err := io.ReadAll(resp.Body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got read error %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("got body %q, want %q", got, tt.want)
+			}
+			if encoding := resp.Header.Get("Content-Encoding"); encoding != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", encoding, tt.wantEncoding)
+			}
+		})
+	}
+}
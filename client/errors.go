@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+// ErrCatalogUnsupported is returned when a registry does not offer the _catalog API
+var ErrCatalogUnsupported = errors.New("registry does not support the catalog API")
+
+// ErrLayerNotFound is returned when a manifest has no layer matching a lookup
+var ErrLayerNotFound = errors.New("layer not found")
+
+// ErrBlobNotFound is returned when a repository has no blob with a requested digest
+var ErrBlobNotFound = errors.New("blob not found")
+
+// ErrRepositoryNotFound is returned when the registry doesn't know a repository
+var ErrRepositoryNotFound = errors.New("repository not found")
+
+// ErrManifestNotFound is matched when the registry has no manifest for a tag or digest
+var ErrManifestNotFound = errors.New("manifest not found")
+
+// ErrUnauthorized is matched by every registry 401, along with ErrCredentialsRequired
+// or ErrCredentialsRejected saying why
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrCredentialsRequired is returned when a registry answers 401 to a client that
+// has no credentials configured
+var ErrCredentialsRequired = errors.New("this repository requires credentials")
+
+// ErrCredentialsRejected is returned when a registry answers 401 to the configured credentials
+var ErrCredentialsRejected = errors.New("the registry rejected the configured credentials")
+
+// ErrRegistryUnreachable is matched when the registry couldn't be reached at all,
+// e.g. its name didn't resolve, the connection was refused or TLS failed
+var ErrRegistryUnreachable = errors.New("registry unreachable")
+
+// ErrUnsupportedManifestType is returned by config and layer operations when the
+// manifest isn't an image manifest, e.g. an index or a custom non-JSON manifest
+var ErrUnsupportedManifestType = errors.New("unsupported manifest media type")
+
+// ErrArtifactTooLarge is returned when a whole-artifact copy would fetch more than
+// the configured maximum number of bytes
+var ErrArtifactTooLarge = errors.New("artifact exceeds the configured copy size limit")
+
+// ErrIndexTooDeep is returned when a copy meets image indexes nested more deeply
+// than CopyLimits.MaxIndexDepth allows
+var ErrIndexTooDeep = errors.New("image index nested too deeply")
+
+// ErrRateLimited is matched by the RateLimitError returned while a registry's
+// Retry-After window is in effect
+var ErrRateLimited = errors.New("registry rate limit in effect")
+
+// classifiedError adds a sentinel to an error's chain without changing its message,
+// so callers can match the sentinel while errors.Is and errors.As still find the
+// underlying ORAS or network error
+type classifiedError struct {
+	class error
+	err   error
+}
+
+// classify returns err with class added to its chain
+func classify(class, err error) error {
+	return &classifiedError{class: class, err: err}
+}
+
+// Error implements error
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error and the sentinel it was classified as
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.err, e.class}
+}
+
+// wrapTransportError classifies the error of a registry request that got no
+// response: rate-limit windows stay as they are, network and TLS failures match
+// ErrRegistryUnreachable, and deadlines and cancellations are left alone so they
+// still read as timeouts
+func wrapTransportError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) || errors.Is(err, ErrRateLimited) {
+		return err
+	}
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) || errors.As(err, &certErr) {
+		return classify(ErrRegistryUnreachable, err)
+	}
+	return err
+}
+
+// wrapManifestError classifies a missing manifest as ErrManifestNotFound, keeping
+// errdef.ErrNotFound in the chain
+func wrapManifestError(err error) error {
+	if errors.Is(err, errdef.ErrNotFound) && !errors.Is(err, ErrManifestNotFound) {
+		return classify(ErrManifestNotFound, err)
+	}
+	return err
+}
+
+// wrapCatalogError maps registry responses that indicate the catalog API is
+// unavailable to ErrCatalogUnsupported, keeping the original error in the chain
+func wrapCatalogError(err error) error {
+	var errResp *errcode.ErrorResponse
+	if !errors.As(err, &errResp) {
+		return err
+	}
+
+	unsupported := errResp.StatusCode == http.StatusNotFound || errResp.StatusCode == http.StatusMethodNotAllowed
+	for _, e := range errResp.Errors {
+		if e.Code == errcode.ErrorCodeUnsupported {
+			unsupported = true
+		}
+	}
+	if unsupported {
+		return fmt.Errorf("%w: %w", ErrCatalogUnsupported, err)
+	}
+	return err
+}
+
+// wrapRepositoryError maps a registry 404 to ErrRepositoryNotFound, keeping the
+// original error in the chain. Only use it for repository-level calls such as tag
+// listing, where a 404 can't mean a missing manifest or blob.
+func wrapRepositoryError(err error) error {
+	var errResp *errcode.ErrorResponse
+	if errors.As(err, &errResp) && errResp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %w", ErrRepositoryNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+func TestWrapTransportError(t *testing.T) {
+	opErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	dnsErr := &net.DNSError{Err: "no such host", Name: "registry.invalid"}
+	rateLimit := &RateLimitError{Host: "registry.example"}
+
+	tests := []struct {
+		name            string
+		err             error
+		wantUnreachable bool
+	}{
+		{name: "connection refused", err: fmt.Errorf("Get \"https://registry.example/v2/\": %w", opErr), wantUnreachable: true},
+		{name: "DNS failure", err: dnsErr, wantUnreachable: true},
+		{name: "deadline", err: fmt.Errorf("dial: %w", context.DeadlineExceeded)},
+		{name: "canceled", err: context.Canceled},
+		{name: "rate limited", err: rateLimit},
+		{name: "other", err: errors.New("unexpected")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wrapTransportError(tt.err)
+			if !errors.Is(got, tt.err) {
+				t.Errorf("wrapped error %v lost the original", got)
+			}
+			if got.Error() != tt.err.Error() {
+				t.Errorf("message changed to %q, want %q", got.Error(), tt.err.Error())
+			}
+			if unreachable := errors.Is(got, ErrRegistryUnreachable); unreachable != tt.wantUnreachable {
+				t.Errorf("matches ErrRegistryUnreachable = %t, want %t", unreachable, tt.wantUnreachable)
+			}
+		})
+	}
+
+	// errors.As still finds the network error through the classification
+	var found *net.OpError
+	if !errors.As(wrapTransportError(opErr), &found) || found != opErr {
+		t.Error("errors.As didn't find the *net.OpError")
+	}
+}
+
+func TestWrapManifestError(t *testing.T) {
+	notFound := &errcode.ErrorResponse{Method: http.MethodGet, StatusCode: http.StatusNotFound}
+	oras := fmt.Errorf("%s: %w", "registry.example/team/app:1.0.0", errdef.ErrNotFound)
+
+	tests := []struct {
+		name         string
+		err          error
+		wantNotFound bool
+	}{
+		{name: "missing manifest", err: oras, wantNotFound: true},
+		{name: "already classified", err: classify(ErrManifestNotFound, oras), wantNotFound: true},
+		{name: "registry error", err: notFound},
+		{name: "other", err: errors.New("unexpected")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wrapManifestError(tt.err)
+			if !errors.Is(got, tt.err) {
+				t.Errorf("wrapped error %v lost the original", got)
+			}
+			if got.Error() != tt.err.Error() {
+				t.Errorf("message changed to %q, want %q", got.Error(), tt.err.Error())
+			}
+			if found := errors.Is(got, ErrManifestNotFound); found != tt.wantNotFound {
+				t.Errorf("matches ErrManifestNotFound = %t, want %t", found, tt.wantNotFound)
+			}
+			if tt.wantNotFound && !errors.Is(got, errdef.ErrNotFound) {
+				t.Error("errdef.ErrNotFound dropped from the chain")
+			}
+		})
+	}
+
+	var errResp *errcode.ErrorResponse
+	if !errors.As(wrapManifestError(fmt.Errorf("fetch: %w", notFound)), &errResp) || errResp != notFound {
+		t.Error("errors.As didn't find the registry error response")
+	}
+}
@@ -1,6 +1,14 @@
 package client
 
-import "io"
+import (
+	"errors"
+	"io"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// ErrLayerNotSeekable is returned by LayerInfo.Seek when the registry doesn't support range requests
+var ErrLayerNotSeekable = errors.New("layer content is not seekable")
 
 // LayerInfo contains metadata about a layer
 type LayerInfo struct {
@@ -8,6 +16,7 @@ type LayerInfo struct {
 	Filename  string
 	MediaType string
 	Size      int64
+	Digest    digest.Digest
 }
 
 // Read implements io.Reader for the LayerInfo struct
@@ -15,6 +24,16 @@ func (l *LayerInfo) Read(p []byte) (n int, err error) {
 	return l.Reader.Read(p)
 }
 
+// Seek implements io.Seeker when the registry supports range requests, in which
+// case each seek issues a new ranged fetch. Otherwise it returns ErrLayerNotSeekable.
+func (l *LayerInfo) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := l.Reader.(io.Seeker)
+	if !ok {
+		return 0, ErrLayerNotSeekable
+	}
+	return seeker.Seek(offset, whence)
+}
+
 // Close closes the underlying reader
 func (l *LayerInfo) Close() error {
 	return l.Reader.Close()
@@ -31,3 +50,7 @@ func (l *LayerInfo) GetMediaType() string {
 func (l *LayerInfo) GetSize() int64 {
 	return l.Size
 }
+
+func (l *LayerInfo) GetDigest() digest.Digest {
+	return l.Digest
+}
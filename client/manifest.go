@@ -0,0 +1,194 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// PluginMetadataAnnotation is the default manifest annotation holding WordPress
+// plugin metadata (name, version, tested, sections, ...) as a JSON object
+const PluginMetadataAnnotation = "org.codekaizen-github.wordpress-plugin-registry-oras.plugin-metadata"
+
+// MediaTypeDockerManifest is the Docker v2 schema 2 manifest, which has the same
+// config and layers structure as an OCI image manifest
+const MediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+
+// MediaTypeDockerManifestList is the Docker manifest list, which has the same
+// manifests structure as an OCI image index
+const MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+// CheckManifestMediaType returns ErrUnsupportedManifestType unless mediaType is an
+// image manifest type with a config and layers. An empty media type is accepted,
+// since some registries don't report one.
+func CheckManifestMediaType(mediaType string) error {
+	switch mediaType {
+	case "", v1.MediaTypeImageManifest, MediaTypeDockerManifest:
+		return nil
+	default:
+		return fmt.Errorf("%w %s", ErrUnsupportedManifestType, mediaType)
+	}
+}
+
+// Manifest is the typed representation of an OCI image manifest
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        v1.Descriptor     `json:"config"`
+	Layers        []v1.Descriptor   `json:"layers"`
+	Subject       *v1.Descriptor    `json:"subject,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// ParseManifest parses raw manifest bytes into a Manifest
+func ParseManifest(data []byte) (*Manifest, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %v", err)
+	}
+	return &manifest, nil
+}
+
+// GetPluginMetadata decodes the plugin metadata stored as a JSON object under the
+// annotation key. Returns false when the annotation is missing or isn't a JSON object.
+func (m *Manifest) GetPluginMetadata(key string) (map[string]interface{}, bool) {
+	raw, ok := m.Annotations[key]
+	if !ok {
+		return nil, false
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &metadata); err != nil || metadata == nil {
+		return nil, false
+	}
+	return metadata, true
+}
+
+// GetArtifactType returns what kind of artifact the manifest describes. Artifact
+// manifests name it in artifactType, usually with the empty config; manifests
+// without one are identified by their config media type, as the OCI image spec
+// suggests. Nothing here assumes the manifest is a container image.
+func (m *Manifest) GetArtifactType() string {
+	if m.ArtifactType != "" {
+		return m.ArtifactType
+	}
+	return m.Config.MediaType
+}
+
+// DecodedAnnotations returns the manifest annotations with any JSON object or
+// array values decoded, so structured annotations like plugin metadata are
+// returned as nested JSON rather than escaped strings
+func (m *Manifest) DecodedAnnotations() map[string]interface{} {
+	return decodeAnnotations(m.Annotations)
+}
+
+// DecodeAnnotations decodes JSON object and array values in an annotation map,
+// leaving every other value as the original string
+func DecodeAnnotations(annotations map[string]string) map[string]interface{} {
+	return decodeAnnotations(annotations)
+}
+
+// decodeAnnotations decodes JSON object and array values in an annotation map,
+// leaving every other value as the original string
+func decodeAnnotations(annotations map[string]string) map[string]interface{} {
+	decoded := make(map[string]interface{}, len(annotations))
+	for key, value := range annotations {
+		decoded[key] = value
+		if len(value) == 0 || (value[0] != '{' && value[0] != '[') {
+			continue
+		}
+		var structured interface{}
+		if err := json.Unmarshal([]byte(value), &structured); err == nil {
+			decoded[key] = structured
+		}
+	}
+	return decoded
+}
+
+// ManifestFields are the top-level manifest fields accepted by SelectFields
+var ManifestFields = []string{"schemaVersion", "mediaType", "artifactType", "config", "layers", "subject", "annotations"}
+
+// SelectFields returns only the named top-level fields of the manifest, keyed by
+// their JSON names, with annotations decoded as in DecodedAnnotations. Absent
+// optional fields are omitted. Returns an error naming any unknown fields.
+func (m *Manifest) SelectFields(names []string) (map[string]interface{}, error) {
+	selected := make(map[string]interface{}, len(names))
+	var unknown []string
+	for _, name := range names {
+		switch name {
+		case "schemaVersion":
+			selected[name] = m.SchemaVersion
+		case "mediaType":
+			if m.MediaType != "" {
+				selected[name] = m.MediaType
+			}
+		case "artifactType":
+			if m.ArtifactType != "" {
+				selected[name] = m.ArtifactType
+			}
+		case "config":
+			selected[name] = m.Config
+		case "layers":
+			layers := m.Layers
+			if layers == nil {
+				layers = []v1.Descriptor{}
+			}
+			selected[name] = layers
+		case "subject":
+			if m.Subject != nil {
+				selected[name] = m.Subject
+			}
+		case "annotations":
+			selected[name] = m.DecodedAnnotations()
+		default:
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown manifest fields %s; valid fields are %s", strings.Join(unknown, ", "), strings.Join(ManifestFields, ", "))
+	}
+	return selected, nil
+}
+
+// LayerSelector picks one layer of a manifest. When several fields are set the most
+// specific wins: Title, then MediaType, then Index. The zero value selects the first layer.
+type LayerSelector struct {
+	Index     int
+	Title     string
+	MediaType string
+}
+
+// String describes the selector for errors and logs
+func (s LayerSelector) String() string {
+	switch {
+	case s.Title != "":
+		return "title " + s.Title
+	case s.MediaType != "":
+		return "media type " + s.MediaType
+	default:
+		return fmt.Sprintf("index %d", s.Index)
+	}
+}
+
+// SelectLayer returns the layer matching selector, or ErrLayerNotFound when none does
+func (m *Manifest) SelectLayer(selector LayerSelector) (v1.Descriptor, error) {
+	switch {
+	case selector.Title != "":
+		for _, layer := range m.Layers {
+			if layer.Annotations[v1.AnnotationTitle] == selector.Title {
+				return layer, nil
+			}
+		}
+	case selector.MediaType != "":
+		for _, layer := range m.Layers {
+			if layer.MediaType == selector.MediaType {
+				return layer, nil
+			}
+		}
+	case selector.Index >= 0 && selector.Index < len(m.Layers):
+		return m.Layers[selector.Index], nil
+	}
+	return v1.Descriptor{}, fmt.Errorf("%w: %s", ErrLayerNotFound, selector)
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/opencontainers/image-spec/specs-go"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestCheckManifestMediaType(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		wantErr   bool
+	}{
+		{mediaType: v1.MediaTypeImageManifest},
+		{mediaType: MediaTypeDockerManifest},
+		{mediaType: ""},
+		{mediaType: v1.MediaTypeImageIndex, wantErr: true},
+		{mediaType: "application/vnd.docker.distribution.manifest.list.v2+json", wantErr: true},
+		{mediaType: "application/vnd.example.custom", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mediaType, func(t *testing.T) {
+			err := CheckManifestMediaType(tt.mediaType)
+			if errors.Is(err, ErrUnsupportedManifestType) != tt.wantErr {
+				t.Errorf("got error %v, want ErrUnsupportedManifestType %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLayerOperationsRejectNonImageManifests(t *testing.T) {
+	registry := newTestRegistry(t)
+	layer := registry.addBlob("application/zip", []byte("plugin"))
+	image := registry.addManifest("team/app", []v1.Descriptor{layer}, "image")
+	index, err := json.Marshal(v1.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: v1.MediaTypeImageIndex,
+		Manifests: []v1.Descriptor{image},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.addRawManifest("team/app", v1.MediaTypeImageIndex, index, "index")
+	registry.addRawManifest("team/app", "application/vnd.example.custom", []byte("not json"), "custom")
+	c := registry.client(t)
+
+	tests := []struct {
+		tag     string
+		wantErr bool
+	}{
+		{tag: "image"},
+		{tag: "index", wantErr: true},
+		{tag: "custom", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			_, _, configErr := c.GetConfigBlob("team/app", tt.tag)
+			layerInfo, layerErr := c.GetFirstLayerReader("team/app", tt.tag)
+			if layerErr == nil {
+				layerInfo.Close()
+			}
+			for _, result := range []struct {
+				operation string
+				err       error
+			}{{"GetConfigBlob", configErr}, {"GetFirstLayerReader", layerErr}} {
+				if tt.wantErr != errors.Is(result.err, ErrUnsupportedManifestType) || (!tt.wantErr && result.err != nil) {
+					t.Errorf("%s: got error %v, want ErrUnsupportedManifestType %t", result.operation, result.err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
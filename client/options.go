@@ -0,0 +1,34 @@
+package client
+
+// Logger is the logging interface used by the client
+type Logger interface {
+	Debug(format string, v ...interface{})
+	Warn(format string, v ...interface{})
+}
+
+// nopLogger discards all log messages
+type nopLogger struct{}
+
+// Debug implements Logger
+func (nopLogger) Debug(format string, v ...interface{}) {}
+
+// Warn implements Logger
+func (nopLogger) Warn(format string, v ...interface{}) {}
+
+// Option configures optional client behavior
+type Option func(*Client)
+
+// WithResumeAttempts makes layer streams re-open the blob up to attempts times after
+// a mid-stream read error, continuing from the last byte read
+func WithResumeAttempts(attempts int) Option {
+	return func(c *Client) {
+		c.resumeAttempts = attempts
+	}
+}
+
+// WithLogger sets the logger the client uses for debug output and warnings
+func WithLogger(logger Logger) Option {
+	return func(c *Client) {
+		c.Logger = logger
+	}
+}
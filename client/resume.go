@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// resumingReader re-opens a blob after a mid-stream read error and continues from
+// the last byte read, using a range request when the registry supports them.
+// It gives up, returning the read error, after maxAttempts resumptions.
+type resumingReader struct {
+	rc          io.ReadCloser
+	open        func() (io.ReadCloser, error)
+	offset      int64
+	attempts    int
+	maxAttempts int
+	target      string
+	logger      Logger
+}
+
+// Read implements io.Reader
+func (r *resumingReader) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	r.offset += int64(n)
+	if err == nil || err == io.EOF || errors.Is(err, context.Canceled) || r.attempts >= r.maxAttempts {
+		return n, err
+	}
+
+	r.attempts++
+	r.logger.Debug("Read of %s failed at byte %d, resuming (attempt %d of %d): %v", r.target, r.offset, r.attempts, r.maxAttempts, err)
+	if resumeErr := r.resume(); resumeErr != nil {
+		r.logger.Debug("Could not resume %s: %v", r.target, resumeErr)
+		return n, err
+	}
+	return n, nil
+}
+
+// resume replaces the broken stream with a new one positioned at r.offset. Without
+// range support the new stream is read up to the offset and the bytes discarded.
+func (r *resumingReader) resume() error {
+	r.rc.Close()
+	r.rc = io.NopCloser(eofReader{})
+	rc, err := r.open()
+	if err != nil {
+		return err
+	}
+	if seeker, ok := rc.(io.Seeker); ok {
+		if r.offset > 0 {
+			if _, err := seeker.Seek(r.offset, io.SeekStart); err != nil {
+				rc.Close()
+				return err
+			}
+		}
+	} else if _, err := io.CopyN(io.Discard, rc, r.offset); err != nil {
+		rc.Close()
+		return fmt.Errorf("failed to skip to byte %d: %w", r.offset, err)
+	}
+	r.rc = rc
+	return nil
+}
+
+// Seek implements io.Seeker when the underlying stream supports it
+func (r *resumingReader) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := r.rc.(io.Seeker)
+	if !ok {
+		return 0, ErrLayerNotSeekable
+	}
+	position, err := seeker.Seek(offset, whence)
+	if err == nil {
+		r.offset = position
+	}
+	return position, err
+}
+
+// Close implements io.Closer
+func (r *resumingReader) Close() error {
+	return r.rc.Close()
+}
+
+// eofReader is an empty reader standing in for a stream that couldn't be re-opened
+type eofReader struct{}
+
+// Read implements io.Reader
+func (eofReader) Read([]byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
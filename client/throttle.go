@@ -0,0 +1,138 @@
+package client
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRetryAfter caps how long a single Retry-After header can hold back requests to
+// a host, so a misbehaving registry can't take itself offline for hours
+const maxRetryAfter = 10 * time.Minute
+
+// RateLimitError is returned instead of contacting a registry host that answered
+// 429 with Retry-After, until the interval it asked for has elapsed
+type RateLimitError struct {
+	Host string
+	// RetryAfter is the time left until the host may be contacted again
+	RetryAfter time.Duration
+}
+
+// Error implements error
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v: %s asked to retry in %s", ErrRateLimited, e.Host, e.RetryAfter.Round(time.Second))
+}
+
+// Unwrap makes RateLimitError match ErrRateLimited
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// RetryAfterSeconds returns RetryAfter rounded up to whole seconds, as sent in a
+// Retry-After header
+func (e *RateLimitError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// hostThrottle holds the Retry-After deadline of each registry host that answered
+// 429 with one
+type hostThrottle struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+// sharedThrottle is the throttle of every client's transport. A host's rate budget
+// is shared by all its clients, so per-request clients, readiness probes and
+// clients rebuilt after a reload or idle eviction all respect the same window.
+var sharedThrottle = newHostThrottle()
+
+// newHostThrottle creates a throttle with no hosts held back
+func newHostThrottle() *hostThrottle {
+	return &hostThrottle{until: make(map[string]time.Time)}
+}
+
+// hold holds back requests to host for wait, capped at maxRetryAfter
+func (t *hostThrottle) hold(host string, wait time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.until[host] = time.Now().Add(min(wait, maxRetryAfter))
+}
+
+// remaining returns how long requests to host are still held back, clearing the
+// window once it has elapsed
+func (t *hostThrottle) remaining(host string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	until, ok := t.until[host]
+	if !ok {
+		return 0
+	}
+	remaining := time.Until(until)
+	if remaining <= 0 {
+		delete(t.until, host)
+		return 0
+	}
+	return remaining
+}
+
+// throttleTransport fails requests to a host held back by its throttle with a
+// RateLimitError until the window passes, so a rate-limited registry isn't sent
+// more requests that would only be throttled too, and opens a window for each 429
+// with Retry-After. It sits below the retry transport: the response that starts a
+// window is still retried as usual, and retries that fall inside the window fail
+// at once instead of waiting.
+type throttleTransport struct {
+	next     http.RoundTripper
+	throttle *hostThrottle
+}
+
+// newThrottleTransport wraps next, using http.DefaultTransport when it is nil
+func newThrottleTransport(next http.RoundTripper, throttle *hostThrottle) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &throttleTransport{next: next, throttle: throttle}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if remaining := t.throttle.remaining(host); remaining > 0 {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, &RateLimitError{Host: host, RetryAfter: remaining}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			t.throttle.hold(host, wait)
+		}
+	}
+	return resp, err
+}
+
+// parseRetryAfter reads a Retry-After header given either as delay seconds or
+// as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds <= 0 {
+			return 0, false
+		}
+		return time.Duration(min(seconds, int64(maxRetryAfter/time.Second))) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now), true
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestThrottleSharedAcrossClients(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	// The first client is throttled, opening the host's window
+	first := &http.Client{Transport: newThrottleTransport(nil, sharedThrottle)}
+	resp, err := first.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// A separately built client, like a per-request or rebuilt one, must respect it
+	tests := []struct {
+		name   string
+		client *http.Client
+	}{
+		{name: "same client", client: first},
+		{name: "new client", client: newHTTPClient(nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.client.Get(server.URL)
+			var rateLimit *RateLimitError
+			if !errors.As(err, &rateLimit) {
+				t.Fatalf("got error %v, want a RateLimitError", err)
+			}
+			if rateLimit.RetryAfter <= 0 || rateLimit.RetryAfter > time.Minute {
+				t.Errorf("RetryAfter = %s, want up to 1m", rateLimit.RetryAfter)
+			}
+		})
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("registry got %d requests, want 1", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{value: "30", want: 30 * time.Second, wantOK: true},
+		{value: " 5 ", want: 5 * time.Second, wantOK: true},
+		{value: "86400", want: maxRetryAfter, wantOK: true},
+		{value: now.Add(2 * time.Minute).Format(http.TimeFormat), want: 2 * time.Minute, wantOK: true},
+		{value: now.Add(-time.Minute).Format(http.TimeFormat)},
+		{value: "0"},
+		{value: "-1"},
+		{value: "soon"},
+		{value: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %s, %t, want %s, %t", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// Timings accumulates the time a client spent waiting on the registry. It is safe
+// for concurrent use, so one Timings can collect every registry call made while
+// serving a request.
+type Timings struct {
+	mu    sync.Mutex
+	total time.Duration
+	calls int
+}
+
+// Add records one registry round trip
+func (t *Timings) Add(elapsed time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total += elapsed
+	t.calls++
+}
+
+// Total returns the summed duration and number of recorded round trips
+func (t *Timings) Total() (time.Duration, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total, t.calls
+}
+
+// WithTimings returns a copy of the client that also adds the duration of each
+// registry call to timings. The copy shares the original's connections and tokens.
+func (c *Client) WithTimings(timings *Timings) ClientInterface {
+	timed := *c
+	timed.timings = timings
+	return &timed
+}
+
+// observe logs how long a registry operation against target took and records it
+// in the client's timings, if any. Streamed fetches are timed until the registry
+// responds, not until the body has been read.
+func (c *Client) observe(operation, target string, start time.Time) {
+	elapsed := time.Since(start)
+	c.Logger.Debug("Registry %s %s/%s took %s", operation, c.Registry, target, elapsed)
+	if c.timings != nil {
+		c.timings.Add(elapsed)
+	}
+}
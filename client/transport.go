@@ -0,0 +1,40 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+// NewTransport returns a transport for registry connections that gives up on a TCP
+// connect after dialTimeout and on a TLS handshake after tlsHandshakeTimeout. These
+// bound only connection setup, so a slow-to-connect registry fails fast however
+// long a download may take. Zero keeps the http.DefaultTransport value.
+func NewTransport(dialTimeout, tlsHandshakeTimeout time.Duration) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if dialTimeout > 0 {
+		dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}
+		transport.DialContext = dialer.DialContext
+	}
+	if tlsHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = tlsHandshakeTimeout
+	}
+	return transport
+}
+
+// WithTransport sends registry requests through transport, retrying them,
+// honoring Retry-After and decoding compressed responses as the default client
+// does. Clients sharing a transport share its connection pool.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.AuthClient.Client = newHTTPClient(transport)
+	}
+}
+
+// newHTTPClient returns the HTTP client used for registry requests over transport,
+// or http.DefaultTransport when it is nil. Every client shares sharedThrottle.
+func newHTTPClient(transport http.RoundTripper) *http.Client {
+	return &http.Client{Transport: retry.NewTransport(newThrottleTransport(newDecodingTransport(transport), sharedThrottle))}
+}
@@ -1,6 +1,10 @@
 package client
 
 import (
+	"context"
+	"io"
+
+	"github.com/opencontainers/go-digest"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
@@ -10,13 +14,29 @@ type LayerInfoInterface interface {
 	GetFilename() string
 	GetMediaType() string
 	GetSize() int64
+	GetDigest() digest.Digest
 }
 
 // ClientInterface defines the methods a client must implement
 type ClientInterface interface {
 	GetDescriptor(repository string, tagName string) (*v1.Descriptor, error)
 	GetManifest(repository string, tagName string) ([]byte, error)
+	GetManifestWithDescriptor(repository string, tagName string) (*v1.Descriptor, []byte, error)
+	FetchManifest(repository, reference string) (*v1.Descriptor, []byte, error)
+	GetManifestReader(repository, reference string) (*v1.Descriptor, io.ReadCloser, error)
 	GetFirstLayerReader(repository, tagName string) (LayerInfoInterface, error)
-	ListTags(repository string) ([]string, error)
+	GetLayerReaderByTitle(repository, tagName, title string) (LayerInfoInterface, error)
+	GetLayerReader(repository, tagName string, selector LayerSelector) (LayerInfoInterface, error)
+	FetchBlob(repository string, dgst digest.Digest) (LayerInfoInterface, error)
+	GetConfigBlob(repository, tagName string) ([]byte, *v1.Descriptor, error)
+	CopyToOCILayout(repository, reference, tagName, dir string) (*v1.Descriptor, error)
+	ResolveDescriptor(repository, reference string) (*v1.Descriptor, error)
+	ListReferrers(repository, reference string) ([]v1.Descriptor, error)
+	ListTags(repository, last string) ([]string, error)
+	ListRepositories(last string, limit int) ([]string, error)
 	GetRegistry() string
+	Ping(ctx context.Context) (PingResult, error)
+	DebugBase(ctx context.Context) (*BaseResponse, error)
+	WithTimings(timings *Timings) ClientInterface
+	WithContext(ctx context.Context) ClientInterface
 }
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// maxUnsizedManifestBytes bounds manifests read without a known size, matching the
+// limit ORAS applies to the manifests it reads
+const maxUnsizedManifestBytes = 4 * 1024 * 1024
+
+// readManifest reads the manifest described by desc and verifies it. Some
+// registries send manifests chunked and report a size of 0 when resolving them,
+// so a descriptor without a size is read up to maxUnsizedManifestBytes, verified
+// by digest alone, and corrected to the length actually read.
+func (c *Client) readManifest(reader io.Reader, desc *v1.Descriptor, target string) ([]byte, error) {
+	if desc.Size > 0 {
+		return content.ReadAll(reader, *desc)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxUnsizedManifestBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxUnsizedManifestBytes {
+		return nil, fmt.Errorf("manifest %s: %w: more than %d bytes", target, errdef.ErrSizeExceedsLimit, maxUnsizedManifestBytes)
+	}
+
+	if int64(len(data)) != desc.Size {
+		c.Logger.Warn("Registry %s reported size %d for manifest %s, but %d bytes were read", c.Registry, desc.Size, target, len(data))
+	}
+	desc.Size = int64(len(data))
+	return content.ReadAll(bytes.NewReader(data), *desc)
+}
+
+// unsizedRepository corrects manifests fetched by reference with readManifest
+// before ORAS copies them, since a copy verifies each node against its size
+type unsizedRepository struct {
+	*remote.Repository
+	client *Client
+}
+
+// FetchReference implements registry.ReferenceFetcher. ORAS resolves the root of a
+// copy with it, so a missing reference is classified as ErrManifestNotFound here.
+func (r unsizedRepository) FetchReference(ctx context.Context, reference string) (v1.Descriptor, io.ReadCloser, error) {
+	desc, rc, err := r.Repository.FetchReference(ctx, reference)
+	if err != nil {
+		return v1.Descriptor{}, nil, wrapManifestError(err)
+	}
+	if desc.Size > 0 {
+		return desc, rc, nil
+	}
+	defer rc.Close()
+
+	data, err := r.client.readManifest(rc, &desc, r.Reference.Repository+":"+reference)
+	if err != nil {
+		return v1.Descriptor{}, nil, err
+	}
+	return desc, io.NopCloser(bytes.NewReader(data)), nil
+}
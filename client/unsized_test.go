@@ -0,0 +1,48 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+)
+
+func TestReadManifest(t *testing.T) {
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	oversized := bytes.Repeat([]byte(" "), maxUnsizedManifestBytes+1)
+
+	tests := []struct {
+		name    string
+		content []byte
+		desc    v1.Descriptor
+		wantErr error
+	}{
+		{name: "sized", content: manifest, desc: v1.Descriptor{Digest: digest.FromBytes(manifest), Size: int64(len(manifest))}},
+		{name: "reported without a size", content: manifest, desc: v1.Descriptor{Digest: digest.FromBytes(manifest)}},
+		{name: "unsized with the wrong digest", content: manifest, desc: v1.Descriptor{Digest: digest.FromString("other")}, wantErr: content.ErrMismatchedDigest},
+		{name: "unsized over the limit", content: oversized, desc: v1.Descriptor{Digest: digest.FromBytes(oversized)}, wantErr: errdef.ErrSizeExceedsLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{Logger: nopLogger{}}
+			desc := tt.desc
+			got, err := c.readManifest(bytes.NewReader(tt.content), &desc, "team/app:1.0.0")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if !bytes.Equal(got, tt.content) {
+				t.Errorf("got %q, want %q", got, tt.content)
+			}
+			if desc.Size != int64(len(tt.content)) {
+				t.Errorf("descriptor size %d, want %d", desc.Size, len(tt.content))
+			}
+		})
+	}
+}
@@ -2,12 +2,13 @@ module github.com/codekaizen-github/orashub
 
 go 1.24.5
 
-require oras.land/oras-go/v2 v2.6.0
-
 require (
-	github.com/a8m/envsubst v1.4.3 // indirect
-	github.com/opencontainers/go-digest v1.0.0 // indirect
-	github.com/opencontainers/image-spec v1.1.1 // indirect
-	golang.org/x/sync v0.14.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	github.com/a8m/envsubst v1.4.3
+	github.com/klauspost/compress v1.18.0
+	github.com/opencontainers/go-digest v1.0.0
+	github.com/opencontainers/image-spec v1.1.1
+	golang.org/x/net v0.40.0
+	golang.org/x/sync v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
+	oras.land/oras-go/v2 v2.6.0
 )
@@ -0,0 +1,30 @@
+// Package assets embeds the default web assets so the server works as a single binary
+package assets
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed static templates
+var embedded embed.FS
+
+// Static returns the embedded static files (favicon, styles, images)
+func Static() fs.FS {
+	return sub("static")
+}
+
+// Templates returns the embedded default HTML templates
+func Templates() fs.FS {
+	return sub("templates")
+}
+
+// sub returns an embedded directory as its own filesystem
+func sub(dir string) fs.FS {
+	fsys, err := fs.Sub(embedded, dir)
+	if err != nil {
+		// The directory is embedded at build time so this can only fail if the embed directive changes
+		panic(err)
+	}
+	return fsys
+}
@@ -0,0 +1,61 @@
+// Package cache provides pluggable storage backends for ORASHub's response caches.
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// Cache stores byte values under string keys, each with its own time to live.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value stored under key, if present and not expired
+	Get(key string) ([]byte, bool)
+	// Set stores value under key until ttl elapses
+	Set(key string, value []byte, ttl time.Duration)
+	// Delete removes key from the cache
+	Delete(key string)
+	// Stats reports the backend's counters and approximate size
+	Stats() Stats
+}
+
+// Stats describes how well a cache backend is doing. Hits and misses count Get
+// calls since the backend was created; Entries and Bytes are approximate and may
+// include expired entries that haven't been evicted yet.
+type Stats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Entries int    `json:"entries"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// Backend names accepted by New
+const (
+	BackendMemory = "memory"
+	BackendDisk   = "disk"
+)
+
+// Options configures the backend created by New
+type Options struct {
+	// Backend selects the implementation, BackendMemory when empty
+	Backend string
+	// MaxEntries bounds the memory backend, evicting the least recently used entries
+	MaxEntries int
+	// Dir is the directory the disk backend stores entries in
+	Dir string
+}
+
+// New creates the cache backend selected by opts
+func New(opts Options) (Cache, error) {
+	switch opts.Backend {
+	case "", BackendMemory:
+		return NewMemory(opts.MaxEntries), nil
+	case BackendDisk:
+		if opts.Dir == "" {
+			return nil, fmt.Errorf("cache backend %q requires a directory", BackendDisk)
+		}
+		return NewDisk(opts.Dir)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q (supported: %s, %s)", opts.Backend, BackendMemory, BackendDisk)
+	}
+}
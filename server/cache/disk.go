@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// diskSweepInterval is the minimum time between sweeps for expired entries
+const diskSweepInterval = time.Minute
+
+// Disk stores cache entries as files in a directory, so they survive restarts and
+// can be shared by processes using the same directory. Each file holds the expiry
+// time followed by the value.
+type Disk struct {
+	dir string
+
+	mu        sync.Mutex
+	lastSweep time.Time
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewDisk creates a disk cache in dir, creating the directory if needed
+func NewDisk(dir string) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Disk{dir: dir, lastSweep: time.Now()}, nil
+}
+
+// path returns the file used for key. Keys are hashed so any string is a safe filename.
+func (c *Disk) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get implements Cache
+func (c *Disk) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil || len(data) < 8 {
+		c.misses.Add(1)
+		return nil, false
+	}
+	expires := time.Unix(0, int64(binary.BigEndian.Uint64(data[:8])))
+	if time.Now().After(expires) {
+		os.Remove(c.path(key))
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return data[8:], true
+}
+
+// Set implements Cache. Entries are written to a temporary file and renamed into
+// place so readers never see a partial entry.
+func (c *Disk) Set(key string, value []byte, ttl time.Duration) {
+	c.startSweep()
+
+	data := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(data[:8], uint64(time.Now().Add(ttl).UnixNano()))
+	copy(data[8:], value)
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// Delete implements Cache
+func (c *Disk) Delete(key string) {
+	os.Remove(c.path(key))
+}
+
+// Stats implements Cache. Entries and Bytes come from listing the directory, so
+// they include entries written by other processes sharing it.
+func (c *Disk) Stats() Stats {
+	stats := Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return stats
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".tmp-") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		stats.Entries++
+		stats.Bytes += info.Size()
+	}
+	return stats
+}
+
+// startSweep starts a sweep in the background, at most once per diskSweepInterval,
+// so keys that are never read again don't accumulate on disk and writers don't wait
+// for the directory scan
+func (c *Disk) startSweep() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.lastSweep) < diskSweepInterval {
+		return
+	}
+	c.lastSweep = time.Now()
+	go c.sweep()
+}
+
+// sweep removes expired entries. Temporary files belong to writers that are still
+// filling them in, so they are left alone.
+func (c *Disk) sweep() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".tmp-") {
+			continue
+		}
+		name := filepath.Join(c.dir, entry.Name())
+		file, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		var header [8]byte
+		_, err = file.ReadAt(header[:], 0)
+		file.Close()
+		if err != nil || now.After(time.Unix(0, int64(binary.BigEndian.Uint64(header[:])))) {
+			os.Remove(name)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDisk(t *testing.T) {
+	tests := []struct {
+		name   string
+		ttl    time.Duration
+		delete bool
+		want   bool
+	}{
+		{name: "stored", ttl: time.Minute, want: true},
+		{name: "expired", ttl: -time.Second, want: false},
+		{name: "deleted", ttl: time.Minute, delete: true, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewDisk(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Set("key", []byte("value"), tt.ttl)
+			if tt.delete {
+				c.Delete("key")
+			}
+			value, ok := c.Get("key")
+			if ok != tt.want {
+				t.Fatalf("Get found %t, want %t", ok, tt.want)
+			}
+			if ok && string(value) != "value" {
+				t.Errorf("Get = %q, want %q", value, "value")
+			}
+		})
+	}
+}
+
+func TestDiskSweep(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDisk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("live", []byte("value"), time.Minute)
+	c.Set("expired", []byte("value"), -time.Second)
+	// A temporary file another writer is still filling in, too short for a header
+	tmp := filepath.Join(dir, ".tmp-in-progress")
+	if err := os.WriteFile(tmp, []byte("abc"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c.sweep()
+
+	if _, err := os.Stat(c.path("live")); err != nil {
+		t.Errorf("live entry removed: %v", err)
+	}
+	if _, err := os.Stat(c.path("expired")); !os.IsNotExist(err) {
+		t.Errorf("expired entry kept: %v", err)
+	}
+	if _, err := os.Stat(tmp); err != nil {
+		t.Errorf("temporary file removed: %v", err)
+	}
+	if got := c.Stats().Entries; got != 1 {
+		t.Errorf("Stats().Entries = %d, want 1", got)
+	}
+}
+
+func TestDiskSetSweepsInBackground(t *testing.T) {
+	c, err := NewDisk(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("expired", []byte("value"), -time.Second)
+
+	// Make a sweep due, so the next Set starts one
+	c.mu.Lock()
+	c.lastSweep = time.Now().Add(-2 * diskSweepInterval)
+	c.mu.Unlock()
+	c.Set("live", []byte("value"), time.Minute)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(c.path("expired")); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired entry was not swept")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := c.Get("live"); !ok {
+		t.Error("live entry missing after the sweep")
+	}
+}
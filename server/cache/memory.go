@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultMaxEntries is the memory backend size used when none is configured
+const DefaultMaxEntries = 10000
+
+// memoryEntry is an element of the LRU list
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// Memory is an in-memory LRU cache with per-entry expiry
+type Memory struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	entries    map[string]*list.Element
+	// bytes is the total size of the keys and values held
+	bytes  int64
+	hits   uint64
+	misses uint64
+}
+
+// NewMemory creates a memory cache holding at most maxEntries entries.
+// A value of zero or less uses DefaultMaxEntries.
+func NewMemory(maxEntries int) *Memory {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &Memory{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get implements Cache
+func (c *Memory) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	entry := element.Value.(*memoryEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(element)
+		c.misses++
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	c.hits++
+	return entry.value, true
+}
+
+// Set implements Cache
+func (c *Memory) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*memoryEntry)
+		c.bytes += int64(len(value) - len(entry.value))
+		entry.value = value
+		entry.expires = expires
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+	c.bytes += int64(len(key) + len(value))
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+// Delete implements Cache
+func (c *Memory) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// Len returns the number of entries currently held, including expired ones not yet evicted
+func (c *Memory) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Stats implements Cache. Bytes counts keys and values, not bookkeeping overhead.
+func (c *Memory) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{Hits: c.hits, Misses: c.misses, Entries: c.order.Len(), Bytes: c.bytes}
+}
+
+// remove drops an element from both the list and the index. The caller must hold mu.
+func (c *Memory) remove(element *list.Element) {
+	entry := element.Value.(*memoryEntry)
+	c.order.Remove(element)
+	delete(c.entries, entry.key)
+	c.bytes -= int64(len(entry.key) + len(entry.value))
+}
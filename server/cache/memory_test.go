@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxEntries int
+		set        []string
+		ttl        time.Duration
+		// get is read after the sets, refreshing its recency, and then "c" is set
+		get      string
+		want     map[string]bool
+		wantSize int
+	}{
+		{name: "stored", maxEntries: 10, set: []string{"a", "b"}, ttl: time.Minute, want: map[string]bool{"a": true, "b": true}, wantSize: 2},
+		{name: "expired", maxEntries: 10, set: []string{"a"}, ttl: -time.Second, want: map[string]bool{"a": false}, wantSize: 0},
+		{name: "evicts least recently used", maxEntries: 2, set: []string{"a", "b", "c"}, ttl: time.Minute, want: map[string]bool{"a": false, "b": true, "c": true}, wantSize: 2},
+		{name: "get refreshes recency", maxEntries: 2, set: []string{"a", "b"}, ttl: time.Minute, get: "a", want: map[string]bool{"a": true, "b": false, "c": true}, wantSize: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMemory(tt.maxEntries)
+			for _, key := range tt.set {
+				c.Set(key, []byte("value-"+key), tt.ttl)
+			}
+			if tt.get != "" {
+				c.Get(tt.get)
+				c.Set("c", []byte("value-c"), tt.ttl)
+			}
+			for key, want := range tt.want {
+				value, ok := c.Get(key)
+				if ok != want {
+					t.Errorf("Get(%q) found %t, want %t", key, ok, want)
+				}
+				if ok && string(value) != "value-"+key {
+					t.Errorf("Get(%q) = %q", key, value)
+				}
+			}
+			if got := c.Len(); got != tt.wantSize {
+				t.Errorf("Len() = %d, want %d", got, tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestMemoryStats(t *testing.T) {
+	c := NewMemory(0)
+	c.Set("key", []byte("value"), time.Minute)
+	c.Set("key", []byte("longer value"), time.Minute)
+	c.Get("key")
+	c.Get("missing")
+	c.Delete("other")
+
+	want := Stats{Hits: 1, Misses: 1, Entries: 1, Bytes: int64(len("key") + len("longer value"))}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	c.Delete("key")
+	if got := c.Stats(); got.Entries != 0 || got.Bytes != 0 {
+		t.Errorf("Stats() after Delete = %+v, want no entries", got)
+	}
+}
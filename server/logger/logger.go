@@ -1,10 +1,15 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // LogLevel represents the level of logging
@@ -90,7 +95,7 @@ func (l *DefaultLogger) GetLevel() LogLevel {
 // SetLevel sets the current log level
 func (l *DefaultLogger) SetLevel(level LogLevel) {
 	l.currentLevel = level
-	l.Info("Log level set to %s", l.getLevelName(level))
+	l.Info("Log level set to %s", level)
 }
 
 // SetLevelFromString sets the current log level from a string
@@ -137,8 +142,8 @@ func (l *DefaultLogger) getLevelPrefix(level LogLevel) string {
 	return prefix
 }
 
-// getLevelName returns the name of the specified log level
-func (l *DefaultLogger) getLevelName(level LogLevel) string {
+// String returns the name of the log level, e.g. "INFO"
+func (level LogLevel) String() string {
 	switch level {
 	case LogLevelError:
 		return "ERROR"
@@ -155,18 +160,119 @@ func (l *DefaultLogger) getLevelName(level LogLevel) string {
 	}
 }
 
-// LoggingMiddleware creates middleware that logs HTTP requests
-func LoggingMiddleware(logger Logger, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Always log requests at INFO level
-		logger.Info("%s %s %s", r.RemoteAddr, r.Method, r.URL.Path)
+// sensitiveHeaders are request headers whose values must never be logged
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Registry-Authorization"}
+
+// redactHeaders returns a copy of the headers with credential values replaced by ***
+func redactHeaders(headers http.Header) http.Header {
+	redacted := headers.Clone()
+	for _, name := range sensitiveHeaders {
+		if _, ok := redacted[http.CanonicalHeaderKey(name)]; ok {
+			redacted.Set(name, "***")
+		}
+	}
+	return redacted
+}
+
+// requestFieldsKey is the context key for the access log fields of a request
+type requestFieldsKey struct{}
+
+// requestFields holds structured fields attached to a request's access log entry
+type requestFields struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// AddRequestFields attaches structured fields (e.g. registry, repository) to the
+// access log entry for the request. Handlers call this once routing has matched,
+// and the fields are logged when the request completes. It is a no-op for
+// requests that didn't pass through LoggingMiddleware.
+func AddRequestFields(ctx context.Context, fields map[string]string) {
+	rf, ok := ctx.Value(requestFieldsKey{}).(*requestFields)
+	if !ok {
+		return
+	}
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	for key, value := range fields {
+		rf.values[key] = value
+	}
+}
+
+// format renders the fields as space-separated key=value pairs sorted by key
+func (rf *requestFields) format() string {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	keys := make([]string, 0, len(rf.values))
+	for key := range rf.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%q", key, rf.values[key])
+	}
+	return b.String()
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
 
+// Unwrap returns the underlying ResponseWriter so http.ResponseController can reach it
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// LoggingMiddleware creates middleware that logs HTTP requests. The access log
+// entry is written when the request completes so it can include the status,
+// duration and any fields handlers attached with AddRequestFields. Only 1 in
+// sampleRate successful requests is logged; 4xx and 5xx responses are always
+// logged. A sampleRate of 1 or less logs every request.
+func LoggingMiddleware(logger Logger, sampleRate int, next http.Handler) http.Handler {
+	var successes atomic.Uint64
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Log more details at DEBUG level
 		if logger.GetLevel() >= LogLevelDebug {
-			logger.Debug("Request Headers: %v", r.Header)
+			logger.Debug("Request Headers: %v", redactHeaders(r.Header))
 			logger.Debug("Request Query: %v", r.URL.Query())
 		}
 
-		next.ServeHTTP(w, r)
+		fields := &requestFields{values: make(map[string]string)}
+		recorder := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), requestFieldsKey{}, fields)))
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		// Log errors and a sample of successful requests at INFO level
+		if status < http.StatusBadRequest && sampleRate > 1 && (successes.Add(1)-1)%uint64(sampleRate) != 0 {
+			return
+		}
+		logger.Info("%s %s %s status=%d duration=%s%s", r.RemoteAddr, r.Method, r.URL.Path, status, time.Since(start).Round(time.Millisecond), fields.format())
 	})
 }
@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"html/template"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/codekaizen-github/orashub/server/assets"
 	"github.com/codekaizen-github/orashub/server/logger"
 	"github.com/codekaizen-github/orashub/server/policy"
 	"github.com/codekaizen-github/orashub/server/router"
@@ -37,53 +45,27 @@ var (
 	Date    = "unknown"
 )
 
-// LoadTemplates loads all templates from the templates directory
-func LoadTemplates(templatesPath string) (*template.Template, error) {
-	templates, err := template.ParseGlob(filepath.Join(templatesPath, "*.html"))
+// LoadEmbeddedTemplates parses the default templates embedded in the binary
+func LoadEmbeddedTemplates() (*template.Template, error) {
+	templates, err := template.ParseFS(assets.Templates(), "*.html")
 	if err != nil {
-		return nil, fmt.Errorf("error loading templates: %v", err)
+		return nil, fmt.Errorf("error loading embedded templates: %v", err)
 	}
 	return templates, nil
 }
 
-// CreateFallbackTemplate creates an in-memory template with the default HTML content
-func CreateFallbackTemplate() *template.Template {
-	tmpl := `<!DOCTYPE html>
-<html>
-<head>
-    <title>ORASHub</title>
-    <style>
-        body { font-family: system-ui, -apple-system, sans-serif; line-height: 1.6; max-width: 800px; margin: 0 auto; padding: 20px; }
-        h1 { color: #2c3e50; }
-        a { color: #3498db; text-decoration: none; }
-        a:hover { text-decoration: underline; }
-        .api-link { display: inline-block; margin-top: 20px; background: #3498db; color: white; padding: 10px 15px; border-radius: 4px; }
-        .api-link:hover { background: #2980b9; text-decoration: none; }
-        code { background: #f8f8f8; padding: 2px 5px; border-radius: 3px; }
-    </style>
-</head>
-<body>
-    <h1>ORASHub</h1>
-    <p>A service for storing and retrieving files using OCI Registry As Storage (ORAS).</p>
-
-    <h2>API Access</h2>
-    <p>The API is available at: <code>{{.ApiURL}}</code></p>
-    <a href="{{.ApiURL}}" class="api-link">Explore the API</a>
-
-    <h2>Documentation</h2>
-    <p>For more information, please refer to the <a href="https://github.com/codekaizen-github/orashub">GitHub repository</a>.</p>
-</body>
-</html>`
-
-	// Parse the template
-	t, err := template.New("index.html").Parse(tmpl)
+// LoadTemplates loads all templates from the templates directory on top of the
+// base templates, so templates with the same name replace the embedded defaults
+func LoadTemplates(base *template.Template, templatesPath string) (*template.Template, error) {
+	templates, err := base.Clone()
 	if err != nil {
-		// Using standard log here is fine since this is initialization code
-		// and the logger might not be fully set up yet
-		log.Printf("Error parsing fallback template: %v", err)
-		return nil
+		return nil, fmt.Errorf("error loading templates: %v", err)
+	}
+	templates, err = templates.ParseGlob(filepath.Join(templatesPath, "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("error loading templates: %v", err)
 	}
-	return t
+	return templates, nil
 }
 
 // Start initializes and starts the server, handling version flags
@@ -130,6 +112,16 @@ func Initialize(appLogger logger.Logger) {
 	if port == "" {
 		port = "8080" // Default port if not set
 	}
+	addr := ":" + port
+
+	// A full listen address, e.g. 127.0.0.1:8080, replaces the port-only default
+	if value := os.Getenv("ORASHUB_LISTEN_ADDR"); value != "" {
+		if err := validateListenAddr(value); err != nil {
+			appLogger.Error("Invalid ORASHUB_LISTEN_ADDR: %v", err)
+			log.Fatalf("Invalid ORASHUB_LISTEN_ADDR: %v", err)
+		}
+		addr = value
+	}
 
 	// Get config file path with default fallback
 	configPath := os.Getenv("ORASHUB_CONFIG_PATH")
@@ -145,48 +137,263 @@ func Initialize(appLogger logger.Logger) {
 		log.Fatalf("Error loading configuration: %v", err)
 	}
 
+	// Validate configuration and report every problem at once
+	if err := config.Validate(); err != nil {
+		appLogger.Error("Invalid configuration in %s:\n%v", configPath, err)
+		log.Fatalf("Invalid configuration in %s:\n%v", configPath, err)
+	}
+
 	// Get image policy from the configuration
 	imagePolicy := config.GetImagePolicy()
 
-	// Load templates if path is provided
-	templatesPath := os.Getenv("ORASHUB_TEMPLATES_PATH")
-	var templates *template.Template
+	// Load the embedded templates, which are always available
+	templates, err := LoadEmbeddedTemplates()
+	if err != nil {
+		appLogger.Error("%v", err)
+		log.Fatalf("%v", err)
+	}
 
+	// Override them with templates from disk if a path is provided
+	templatesPath := os.Getenv("ORASHUB_TEMPLATES_PATH")
 	if templatesPath != "" {
-		var err error
-		templates, err = LoadTemplates(templatesPath)
+		overridden, err := LoadTemplates(templates, templatesPath)
 		if err != nil {
 			appLogger.Warn("Error loading templates from %s: %v", templatesPath, err)
-			appLogger.Warn("Using fallback template instead")
-			templates = CreateFallbackTemplate()
+			appLogger.Warn("Using embedded templates instead")
 		} else {
+			templates = overridden
 			appLogger.Info("Loaded templates from: %s", templatesPath)
 		}
 	} else {
-		appLogger.Info("ORASHUB_TEMPLATES_PATH not set, using fallback template")
-		templates = CreateFallbackTemplate()
+		appLogger.Info("ORASHUB_TEMPLATES_PATH not set, using embedded templates")
+	}
+
+	// Load tunable API settings, starting from the defaults
+	settings := router.DefaultApiSettings()
+	settings.FetchConcurrency = getEnvInt("ORASHUB_FETCH_CONCURRENCY", settings.FetchConcurrency, appLogger)
+	settings.DigestLookupCacheTTL = getEnvDuration("ORASHUB_DIGEST_LOOKUP_CACHE_TTL", settings.DigestLookupCacheTTL, appLogger)
+	settings.ArtifactTypesMaxTags = getEnvInt("ORASHUB_ARTIFACT_TYPES_MAX_TAGS", settings.ArtifactTypesMaxTags, appLogger)
+	settings.ArtifactTypesCacheTTL = getEnvDuration("ORASHUB_ARTIFACT_TYPES_CACHE_TTL", settings.ArtifactTypesCacheTTL, appLogger)
+	settings.CacheBackend = os.Getenv("ORASHUB_CACHE_BACKEND")
+	settings.CacheDir = os.Getenv("ORASHUB_CACHE_DIR")
+	settings.BlobCacheDir = os.Getenv("ORASHUB_BLOB_CACHE_DIR")
+	if settings.BlobCacheDir != "" {
+		appLogger.Info("Caching layer blobs in: %s", settings.BlobCacheDir)
+	}
+	settings.CacheMaxEntries = getEnvInt("ORASHUB_CACHE_MAX_ENTRIES", settings.CacheMaxEntries, appLogger)
+	settings.TagCacheTTL = getEnvDuration("ORASHUB_TAG_CACHE_TTL", settings.TagCacheTTL, appLogger)
+	settings.CacheControl = getEnvBool("ORASHUB_CACHE_CONTROL", settings.CacheControl, appLogger)
+	settings.TagMaxAge = getEnvDuration("ORASHUB_TAG_MAX_AGE", settings.TagMaxAge, appLogger)
+	settings.DownloadStallTimeout = getEnvDuration("ORASHUB_DOWNLOAD_STALL_TIMEOUT", settings.DownloadStallTimeout, appLogger)
+	settings.DownloadBufferSize = getEnvInt("ORASHUB_DOWNLOAD_BUFFER_SIZE", settings.DownloadBufferSize, appLogger)
+	if settings.DownloadBufferSize <= 0 {
+		appLogger.Warn("Invalid value for ORASHUB_DOWNLOAD_BUFFER_SIZE (%d), using default %d", settings.DownloadBufferSize, router.DefaultApiSettings().DownloadBufferSize)
+		settings.DownloadBufferSize = router.DefaultApiSettings().DownloadBufferSize
+	}
+	settings.DialTimeout = getEnvDuration("ORASHUB_DIAL_TIMEOUT", settings.DialTimeout, appLogger)
+	settings.TLSHandshakeTimeout = getEnvDuration("ORASHUB_TLS_HANDSHAKE_TIMEOUT", settings.TLSHandshakeTimeout, appLogger)
+	settings.ClientIdleTimeout = getEnvDuration("ORASHUB_CLIENT_IDLE_TIMEOUT", settings.ClientIdleTimeout, appLogger)
+	if value := os.Getenv("ORASHUB_ROUTE_TIMEOUTS"); value != "" {
+		routeTimeouts, err := router.ParseRouteTimeouts(value)
+		if err != nil {
+			appLogger.Error("Invalid ORASHUB_ROUTE_TIMEOUTS: %v", err)
+			log.Fatalf("Invalid ORASHUB_ROUTE_TIMEOUTS: %v", err)
+		}
+		settings.RouteTimeouts = routeTimeouts
+	}
+	settings.DefaultRegistry = os.Getenv("ORASHUB_DEFAULT_REGISTRY")
+	settings.DownloadResumeAttempts = getEnvInt("ORASHUB_DOWNLOAD_RESUME_ATTEMPTS", settings.DownloadResumeAttempts, appLogger)
+	settings.CopyLimits.Concurrency = getEnvInt("ORASHUB_COPY_CONCURRENCY", settings.CopyLimits.Concurrency, appLogger)
+	settings.CopyLimits.MaxMetadataBytes = int64(getEnvInt("ORASHUB_COPY_MAX_METADATA_BYTES", int(settings.CopyLimits.MaxMetadataBytes), appLogger))
+	settings.CopyLimits.MaxBytes = int64(getEnvInt("ORASHUB_COPY_MAX_BYTES", int(settings.CopyLimits.MaxBytes), appLogger))
+	settings.CopyLimits.MaxIndexDepth = getEnvInt("ORASHUB_COPY_MAX_INDEX_DEPTH", settings.CopyLimits.MaxIndexDepth, appLogger)
+	settings.MaxDownloadsPerIP = getEnvInt("ORASHUB_MAX_DOWNLOADS_PER_IP", settings.MaxDownloadsPerIP, appLogger)
+	settings.DownloadMinThroughput = int64(getEnvInt("ORASHUB_DOWNLOAD_MIN_THROUGHPUT", int(settings.DownloadMinThroughput), appLogger))
+	settings.AllowCredentialOverride = getEnvBool("ORASHUB_ALLOW_CREDENTIAL_OVERRIDE", settings.AllowCredentialOverride, appLogger)
+	if settings.AllowCredentialOverride {
+		appLogger.Warn("Per-request registry credentials are enabled; callers are trusted to supply credentials via %s", router.CredentialOverrideHeader)
+	}
+	settings.ReadinessCacheTTL = getEnvDuration("ORASHUB_READINESS_CACHE_TTL", settings.ReadinessCacheTTL, appLogger)
+	settings.ReadinessBackoffBase = getEnvDuration("ORASHUB_READINESS_BACKOFF_BASE", settings.ReadinessBackoffBase, appLogger)
+	settings.ReadinessBackoffMax = getEnvDuration("ORASHUB_READINESS_BACKOFF_MAX", settings.ReadinessBackoffMax, appLogger)
+	settings.AdminToken = os.Getenv("ORASHUB_ADMIN_TOKEN")
+	settings.RootRedirect = getEnvBool("ORASHUB_ROOT_REDIRECT", settings.RootRedirect, appLogger)
+	if key := os.Getenv("ORASHUB_METADATA_ANNOTATION_KEY"); key != "" {
+		settings.MetadataAnnotationKey = key
+	}
+	if value := os.Getenv("ORASHUB_TRUSTED_PROXIES"); value != "" {
+		proxies, err := router.ParseTrustedProxies(value)
+		if err != nil {
+			appLogger.Error("Invalid ORASHUB_TRUSTED_PROXIES: %v", err)
+			log.Fatalf("Invalid ORASHUB_TRUSTED_PROXIES: %v", err)
+		}
+		settings.TrustedProxies = proxies
+	} else {
+		appLogger.Info("ORASHUB_TRUSTED_PROXIES is not set, so X-Forwarded-* headers are ignored; set it when running behind a reverse proxy")
+	}
+	latestFallback, err := router.ParseLatestFallback(os.Getenv("ORASHUB_LATEST_FALLBACK"))
+	if err != nil {
+		appLogger.Error("Invalid ORASHUB_LATEST_FALLBACK: %v", err)
+		log.Fatalf("Invalid ORASHUB_LATEST_FALLBACK: %v", err)
+	}
+	settings.LatestFallback = latestFallback
+	settings.DownloadFilenameFromMetadata = os.Getenv("ORASHUB_DOWNLOAD_FILENAME") == "auto"
+	settings.CORSAllowedOrigins = router.ParseCORSOrigins(os.Getenv("ORASHUB_CORS_ALLOWED_ORIGINS"))
+	if len(settings.CORSAllowedOrigins) > 0 {
+		appLogger.Info("CORS enabled for origins: %s", strings.Join(settings.CORSAllowedOrigins, ", "))
+	}
+	settings.UpstreamTimingHeader = getEnvBool("ORASHUB_UPSTREAM_TIMING_HEADER", settings.UpstreamTimingHeader, appLogger)
+	settings.StaticDir = os.Getenv("ORASHUB_STATIC_DIR")
+	if settings.StaticDir != "" {
+		appLogger.Info("Serving static files from: %s", settings.StaticDir)
 	}
 
 	// Create API manager
-	manager := router.NewApiManager(config, imagePolicy, templates, appLogger)
+	manager := router.NewApiManager(config, imagePolicy, templates, appLogger, settings)
+
+	if err := manager.ValidateDefaultRegistry(); err != nil {
+		appLogger.Error("Invalid ORASHUB_DEFAULT_REGISTRY: %v", err)
+		log.Fatalf("Invalid ORASHUB_DEFAULT_REGISTRY: %v", err)
+	}
+	appLogger.Info("Routing paths without a registry to: %s", manager.DefaultRegistry())
+
+	// Pick up registry changes from the config file on SIGHUP
+	watchConfigReload(configPath, manager, appLogger)
+
+	// Probe each registry so typos in names or credentials show up at boot
+	if getEnvBool("ORASHUB_SKIP_STARTUP_CHECK", false, appLogger) {
+		appLogger.Info("Skipping startup registry connectivity check")
+	} else {
+		manager.CheckConnectivity(context.Background(), getEnvDuration("ORASHUB_STARTUP_CHECK_TIMEOUT", 5*time.Second, appLogger))
+	}
 
 	// Create mux and set up routes using the manager
 	mux := http.NewServeMux()
 	manager.SetupRoutes(mux)
 
-	// Wrap mux with logging middleware
-	loggedMux := logger.LoggingMiddleware(appLogger, mux)
+	// Route paths without a registry to the default one, reject oversized request
+	// bodies, add configured response headers, then wrap with logging middleware
+	maxBodySize := int64(getEnvInt("ORASHUB_MAX_BODY_SIZE", 1<<20, appLogger))
+	limitedMux := router.LimitRequestBody(maxBodySize, manager.WithDefaultRegistry(mux))
+	logSampleRate := getEnvInt("ORASHUB_LOG_SAMPLE_RATE", 1, appLogger)
+	if logSampleRate > 1 {
+		appLogger.Info("Logging 1 in %d successful requests", logSampleRate)
+	}
+	headeredMux := router.ResponseHeaders(config.ResponseHeaders, limitedMux)
+	loggedMux := logger.LoggingMiddleware(appLogger, logSampleRate, headeredMux)
+
+	// Load HTTP server timeouts
+	timeouts := ServerTimeouts{
+		ReadHeader: getEnvDuration("ORASHUB_READ_HEADER_TIMEOUT", 10*time.Second, appLogger),
+		Write:      getEnvDuration("ORASHUB_WRITE_TIMEOUT", 0, appLogger),
+		Idle:       getEnvDuration("ORASHUB_IDLE_TIMEOUT", 120*time.Second, appLogger),
+	}
 
 	// Start the server with the configured mux
-	Serve(loggedMux, port, appLogger)
+	Serve(loggedMux, addr, timeouts, appLogger)
+}
+
+// ServerTimeouts holds the timeouts applied to the HTTP server. A zero value means no timeout.
+type ServerTimeouts struct {
+	// ReadHeader bounds how long a client may take to send request headers
+	ReadHeader time.Duration
+	// Write bounds the time to write an entire response, including streamed downloads
+	Write time.Duration
+	// Idle bounds how long a keep-alive connection may sit idle between requests
+	Idle time.Duration
+}
+
+// getEnvInt reads an integer environment variable, returning fallback when unset or invalid
+func getEnvInt(name string, fallback int, appLogger logger.Logger) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		appLogger.Warn("Invalid value for %s (%q), using default %d: %v", name, value, fallback, err)
+		return fallback
+	}
+	return parsed
+}
+
+// getEnvBool reads a boolean environment variable (e.g. "true", "1"), returning fallback when unset or invalid
+func getEnvBool(name string, fallback bool, appLogger logger.Logger) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		appLogger.Warn("Invalid value for %s (%q), using default %t: %v", name, value, fallback, err)
+		return fallback
+	}
+	return parsed
+}
+
+// getEnvDuration reads a duration environment variable (e.g. "30s", "5m"),
+// returning fallback when unset or invalid
+func getEnvDuration(name string, fallback time.Duration, appLogger logger.Logger) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		appLogger.Warn("Invalid value for %s (%q), using default %s: %v", name, value, fallback, err)
+		return fallback
+	}
+	return parsed
+}
+
+// validateListenAddr checks that addr is a host:port listen address. The host may be
+// empty to listen on every interface, and IPv6 hosts must be bracketed, e.g. [::1]:8080.
+func validateListenAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%q is not a host:port address: %v", addr, err)
+	}
+	if number, err := strconv.Atoi(port); err != nil || number < 0 || number > 65535 {
+		return fmt.Errorf("%q has an invalid port %q", addr, port)
+	}
+	return nil
 }
 
 // Entry point of the program
-func Serve(handler http.Handler, port string, appLogger logger.Logger) {
+func Serve(handler http.Handler, addr string, timeouts ServerTimeouts, appLogger logger.Logger) {
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%s", port),
-		Handler: handler,
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
 	}
-	appLogger.Info("Server listening on port %s", port)
+	appLogger.Info("Server listening on %s", addr)
 	appLogger.Error("Server stopped: %v", server.ListenAndServe()) // Run the http server
 }
+
+// watchConfigReload reloads the registries section of the configuration file each
+// time the process receives SIGHUP, picking the default registry again. A file that
+// fails to load or validate, or leaves the default registry unconfigured, is
+// reported and the running registries are kept. Other sections need a restart.
+func watchConfigReload(configPath string, manager *router.ApiManager, appLogger logger.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			appLogger.Info("Received SIGHUP, reloading registries from %s", configPath)
+			config, err := policy.LoadConfig(configPath)
+			if err != nil {
+				appLogger.Error("Error reloading configuration, keeping current registries: %v", err)
+				continue
+			}
+			if err := config.Validate(); err != nil {
+				appLogger.Error("Invalid configuration in %s, keeping current registries:\n%v", configPath, err)
+				continue
+			}
+			if err := manager.ReloadRegistries(config); err != nil {
+				appLogger.Error("Invalid default registry after reloading %s, keeping current registries: %v", configPath, err)
+			}
+		}
+	}()
+}
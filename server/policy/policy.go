@@ -1,9 +1,17 @@
 package policy
 
 import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/a8m/envsubst"
 	"gopkg.in/yaml.v3"
@@ -14,20 +22,157 @@ type ConfigFile struct {
 	Registries          []RegistryCredentials `yaml:"registries"`
 	AllowedRepositories []string              `yaml:"allowed_repositories"`
 	BlockedRepositories []string              `yaml:"blocked_repositories"`
+	// RequireNonemptyConfig rejects artifacts whose config is the empty descriptor
+	// (application/vnd.oci.empty.v1+json)
+	RequireNonemptyConfig bool `yaml:"require_nonempty_config"`
+	// RequiredAnnotations only serves artifacts whose manifest carries every listed
+	// annotation with the given value
+	RequiredAnnotations map[string]string `yaml:"required_annotations"`
+	// ResponseHeaders are added to every response unless the handler sets them itself
+	ResponseHeaders map[string]string `yaml:"response_headers"`
+	// APITokens require callers to authenticate, each token granting access to its
+	// own repository patterns on top of the global policy
+	APITokens []APIToken `yaml:"api_tokens"`
+	// PublicRepositories are the repository patterns callers without a token may
+	// access while api_tokens is configured
+	PublicRepositories []string `yaml:"public_repositories"`
+	// DownloadAllowedRepositories and DownloadBlockedRepositories further restrict
+	// the endpoints that serve artifact content, on top of the repository lists
+	DownloadAllowedRepositories []string `yaml:"download_allowed_repositories"`
+	DownloadBlockedRepositories []string `yaml:"download_blocked_repositories"`
+	// MaxLayers rejects artifacts with more layers than this from the endpoints that
+	// process every layer. Zero means unlimited.
+	MaxLayers int `yaml:"max_layers"`
+	// GoneRepositories are repositories removed for good, answered with 410 Gone
+	// rather than the 403 of blocked_repositories
+	GoneRepositories []string `yaml:"gone_repositories"`
+	// DefaultRegistry is the registry, by name or alias, that API paths without a
+	// registry segment are routed to. Empty uses the first registry.
+	DefaultRegistry string `yaml:"default_registry"`
+}
+
+// APIToken is a bearer token for API callers and the repositories it grants
+type APIToken struct {
+	Name                string   `yaml:"name"`
+	Token               string   `yaml:"token"`
+	AllowedRepositories []string `yaml:"allowed_repositories"`
 }
 
 // RegistryCredentials represents the credentials for a registry
 type RegistryCredentials struct {
-	Name     string `yaml:"name"`
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+	Name     string   `yaml:"name"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	Aliases  []string `yaml:"aliases"`
+	// NamespacePrefix optionally confines the registry to repositories under this
+	// path (e.g. "codekaizen-github"), regardless of the allowed/blocked lists
+	NamespacePrefix string `yaml:"namespace_prefix"`
+	// Cache overrides the global cache settings for this registry
+	Cache RegistryCache `yaml:"cache"`
+	// DownloadURL redirects downloads of this registry's layers to a mirror in
+	// object storage or a CDN instead of streaming them through ORASHub
+	DownloadURL *DownloadURL `yaml:"download_url"`
+}
+
+// DownloadURL describes where a registry's layer blobs are mirrored. Downloads are
+// redirected to URL with its placeholders filled in, signed when SigningKey is set.
+type DownloadURL struct {
+	// URL is a template taking {registry}, {repository}, {digest}, {algorithm},
+	// {encoded} and {filename}, e.g. "https://cdn.example.com/blobs/{digest}"
+	URL string `yaml:"url"`
+	// SigningKey adds expires and signature query parameters, an HMAC-SHA256 of the
+	// URL path and expiry time, for the storage or CDN to check
+	SigningKey string `yaml:"signing_key"`
+	// Expires is how long a signed URL stays valid; zero means 15 minutes
+	Expires time.Duration `yaml:"expires"`
+	// MinSize redirects only layers of at least this many bytes, streaming smaller ones
+	MinSize int64 `yaml:"min_size"`
+}
+
+// RegistryCache holds per-registry cache settings, e.g. to cache a rate-limited
+// public registry longer than a fast local mirror. Unset fields fall back to the
+// global settings.
+type RegistryCache struct {
+	// TagTTL replaces ORASHUB_TAG_CACHE_TTL for tag listings, zero disabling the cache
+	TagTTL *time.Duration `yaml:"tag_ttl"`
+	// ManifestTTL replaces the TTLs of the caches built from manifests: tag metadata,
+	// digest lookups and artifact types
+	ManifestTTL *time.Duration `yaml:"manifest_ttl"`
+	// BlobCache turns the layer blob cache off for this registry with false. It can't
+	// turn it on without ORASHUB_BLOB_CACHE_DIR.
+	BlobCache *bool `yaml:"blob_cache"`
 }
 
 // ImagePolicy represents the allowed and blocked repositories
 // Note: Despite the name "ImagePolicy", this is now focused on repository paths rather than images
 type ImagePolicy struct {
-	AllowedRepositories []string `yaml:"allowed_repositories"`
-	BlockedRepositories []string `yaml:"blocked_repositories"`
+	AllowedRepositories   []string `yaml:"allowed_repositories"`
+	BlockedRepositories   []string `yaml:"blocked_repositories"`
+	RequireNonemptyConfig bool     `yaml:"require_nonempty_config"`
+	// RequiredAnnotations are checked against the manifest after it is fetched,
+	// unlike the repository lists which are checked before any registry request
+	RequiredAnnotations map[string]string `yaml:"required_annotations"`
+	// APITokens and PublicRepositories scope access by caller identity
+	APITokens          []APIToken `yaml:"api_tokens"`
+	PublicRepositories []string   `yaml:"public_repositories"`
+	// DownloadAllowedRepositories and DownloadBlockedRepositories apply only to
+	// content downloads, after the general lists have allowed the repository
+	DownloadAllowedRepositories []string `yaml:"download_allowed_repositories"`
+	DownloadBlockedRepositories []string `yaml:"download_blocked_repositories"`
+	// MaxLayers is checked against the manifest once it is fetched, zero meaning unlimited
+	MaxLayers int `yaml:"max_layers"`
+	// GoneRepositories take precedence over every other list
+	GoneRepositories []string `yaml:"gone_repositories"`
+}
+
+// Authenticate returns the API token matching token, comparing in constant time
+func (p *ImagePolicy) Authenticate(token string) (*APIToken, bool) {
+	for i := range p.APITokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(p.APITokens[i].Token)) == 1 {
+			return &p.APITokens[i], true
+		}
+	}
+	return nil, false
+}
+
+// IdentityAllows reports whether a caller may access a repository: with its token's
+// patterns or the public ones, or with the public ones alone when token is nil for
+// an anonymous caller. Without api_tokens every caller may access every repository,
+// leaving the decision to IsAllowed.
+func IdentityAllows(repository string, token *APIToken, policy *ImagePolicy) bool {
+	if len(policy.APITokens) == 0 {
+		return true
+	}
+	patterns := policy.PublicRepositories
+	if token != nil {
+		patterns = append(patterns[:len(patterns):len(patterns)], token.AllowedRepositories...)
+	}
+	for _, pattern := range patterns {
+		if repositoryMatches(pattern, repository) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckAnnotations returns an error naming the first required annotation (in key
+// order) that annotations is missing or has a different value for
+func (p *ImagePolicy) CheckAnnotations(annotations map[string]string) error {
+	keys := make([]string, 0, len(p.RequiredAnnotations))
+	for key := range p.RequiredAnnotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, ok := annotations[key]
+		if !ok {
+			return fmt.Errorf("annotation %s is required", key)
+		}
+		if value != p.RequiredAnnotations[key] {
+			return fmt.Errorf("annotation %s must be '%s'", key, p.RequiredAnnotations[key])
+		}
+	}
+	return nil
 }
 
 // LoadConfig loads the configuration file with environment variable substitution
@@ -58,13 +203,250 @@ func LoadConfig(path string) (*ConfigFile, error) {
 // GetImagePolicy extracts the repository policy from the configuration
 func (c *ConfigFile) GetImagePolicy() *ImagePolicy {
 	return &ImagePolicy{
-		AllowedRepositories: c.AllowedRepositories,
-		BlockedRepositories: c.BlockedRepositories,
+		AllowedRepositories:   c.AllowedRepositories,
+		BlockedRepositories:   c.BlockedRepositories,
+		RequireNonemptyConfig: c.RequireNonemptyConfig,
+		RequiredAnnotations:   c.RequiredAnnotations,
+		APITokens:             c.APITokens,
+		PublicRepositories:    c.PublicRepositories,
+
+		DownloadAllowedRepositories: c.DownloadAllowedRepositories,
+		DownloadBlockedRepositories: c.DownloadBlockedRepositories,
+		MaxLayers:                   c.MaxLayers,
+		GoneRepositories:            c.GoneRepositories,
+	}
+}
+
+// regexPatternPrefix marks a repository pattern as a regular expression
+const regexPatternPrefix = "re:"
+
+// Validate checks the configuration for problems that would otherwise only
+// surface as confusing runtime behavior. All problems found are returned
+// together as a single joined error.
+func (c *ConfigFile) Validate() error {
+	var errs []error
+
+	if len(c.Registries) == 0 {
+		errs = append(errs, errors.New("registries: at least one registry must be configured"))
+	}
+
+	seen := make(map[string]int)
+	for i, registry := range c.Registries {
+		name := strings.TrimSpace(registry.Name)
+		if name == "" {
+			errs = append(errs, fmt.Errorf("registries[%d]: name must not be empty", i))
+			continue
+		}
+		if first, ok := seen[name]; ok {
+			errs = append(errs, fmt.Errorf("registries[%d]: duplicate registry name '%s' (first defined at registries[%d])", i, name, first))
+			continue
+		}
+		seen[name] = i
+	}
+
+	// Aliases share the registry namespace in URLs so they must not collide with
+	// each other or with a registry name
+	aliasOwners := make(map[string]int)
+	for i, registry := range c.Registries {
+		for j, alias := range registry.Aliases {
+			alias = strings.TrimSpace(alias)
+			switch {
+			case alias == "":
+				errs = append(errs, fmt.Errorf("registries[%d].aliases[%d]: alias must not be empty", i, j))
+			case strings.Contains(alias, "/"):
+				errs = append(errs, fmt.Errorf("registries[%d].aliases[%d]: alias '%s' must not contain '/'", i, j, alias))
+			default:
+				if _, ok := seen[alias]; ok {
+					errs = append(errs, fmt.Errorf("registries[%d].aliases[%d]: alias '%s' conflicts with a registry name", i, j, alias))
+				} else if owner, ok := aliasOwners[alias]; ok {
+					errs = append(errs, fmt.Errorf("registries[%d].aliases[%d]: duplicate alias '%s' (already used by registries[%d])", i, j, alias, owner))
+				} else {
+					aliasOwners[alias] = i
+				}
+			}
+		}
+	}
+
+	if c.DefaultRegistry != "" {
+		if _, ok := seen[c.DefaultRegistry]; !ok {
+			if _, ok := aliasOwners[c.DefaultRegistry]; !ok {
+				errs = append(errs, fmt.Errorf("default_registry: '%s' is not a configured registry or alias", c.DefaultRegistry))
+			}
+		}
+	}
+
+	for i, registry := range c.Registries {
+		prefix := registry.NamespacePrefix
+		if prefix != "" && (strings.TrimSpace(prefix) != prefix || strings.Trim(prefix, "/") == "" || strings.Contains(prefix, "*")) {
+			errs = append(errs, fmt.Errorf("registries[%d].namespace_prefix: '%s' must be a repository path like 'org' or 'org/team'", i, prefix))
+		}
+		if ttl := registry.Cache.TagTTL; ttl != nil && *ttl < 0 {
+			errs = append(errs, fmt.Errorf("registries[%d].cache.tag_ttl: must not be negative", i))
+		}
+		if ttl := registry.Cache.ManifestTTL; ttl != nil && *ttl < 0 {
+			errs = append(errs, fmt.Errorf("registries[%d].cache.manifest_ttl: must not be negative", i))
+		}
+		if download := registry.DownloadURL; download != nil {
+			errs = append(errs, validateDownloadURL(fmt.Sprintf("registries[%d].download_url", i), download)...)
+		}
+	}
+
+	errs = append(errs, validatePatterns("allowed_repositories", c.AllowedRepositories)...)
+	errs = append(errs, validatePatterns("blocked_repositories", c.BlockedRepositories)...)
+
+	tokenNames := make(map[string]int)
+	tokenValues := make(map[string]int)
+	for i, token := range c.APITokens {
+		name := strings.TrimSpace(token.Name)
+		if name == "" {
+			errs = append(errs, fmt.Errorf("api_tokens[%d]: name must not be empty", i))
+		} else if first, ok := tokenNames[name]; ok {
+			errs = append(errs, fmt.Errorf("api_tokens[%d]: duplicate token name '%s' (first defined at api_tokens[%d])", i, name, first))
+		} else {
+			tokenNames[name] = i
+		}
+		if strings.TrimSpace(token.Token) == "" {
+			errs = append(errs, fmt.Errorf("api_tokens[%d]: token must not be empty", i))
+		} else if first, ok := tokenValues[token.Token]; ok {
+			errs = append(errs, fmt.Errorf("api_tokens[%d]: token is the same as api_tokens[%d]", i, first))
+		} else {
+			tokenValues[token.Token] = i
+		}
+		errs = append(errs, validatePatterns(fmt.Sprintf("api_tokens[%d].allowed_repositories", i), token.AllowedRepositories)...)
+	}
+	errs = append(errs, validatePatterns("public_repositories", c.PublicRepositories)...)
+	errs = append(errs, validatePatterns("download_allowed_repositories", c.DownloadAllowedRepositories)...)
+	errs = append(errs, validatePatterns("download_blocked_repositories", c.DownloadBlockedRepositories)...)
+	errs = append(errs, validatePatterns("gone_repositories", c.GoneRepositories)...)
+
+	if c.MaxLayers < 0 {
+		errs = append(errs, fmt.Errorf("max_layers: %d must not be negative (use 0 for unlimited)", c.MaxLayers))
+	}
+
+	for key := range c.RequiredAnnotations {
+		if strings.TrimSpace(key) == "" {
+			errs = append(errs, errors.New("required_annotations: annotation key must not be empty"))
+		}
+	}
+
+	headerNames := make([]string, 0, len(c.ResponseHeaders))
+	for name := range c.ResponseHeaders {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	for _, name := range headerNames {
+		value := c.ResponseHeaders[name]
+		if !validHeaderName(name) {
+			errs = append(errs, fmt.Errorf("response_headers: '%s' is not a valid header name", name))
+		} else if strings.ContainsAny(value, "\r\n") {
+			errs = append(errs, fmt.Errorf("response_headers.%s: value must not contain line breaks", name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// downloadURLPlaceholders are the placeholders a download_url template may use
+var downloadURLPlaceholders = []string{"{registry}", "{repository}", "{digest}", "{algorithm}", "{encoded}", "{filename}"}
+
+// validateDownloadURL checks a registry's download_url, reporting problems under field
+func validateDownloadURL(field string, download *DownloadURL) []error {
+	var errs []error
+	// Fill in the placeholders so only the rest of the template is checked
+	template := download.URL
+	for _, placeholder := range downloadURLPlaceholders {
+		template = strings.ReplaceAll(template, placeholder, "x")
+	}
+	if parsed, err := url.Parse(template); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		errs = append(errs, fmt.Errorf("%s.url: '%s' must be an absolute http or https URL", field, download.URL))
+	}
+	if download.Expires < 0 {
+		errs = append(errs, fmt.Errorf("%s.expires: must not be negative", field))
+	}
+	if download.Expires > 0 && download.SigningKey == "" {
+		errs = append(errs, fmt.Errorf("%s.expires: only applies with a signing_key", field))
+	}
+	if download.MinSize < 0 {
+		errs = append(errs, fmt.Errorf("%s.min_size: must not be negative", field))
+	}
+	return errs
+}
+
+// validHeaderName reports whether name is a valid HTTP header field name (an RFC 9110 token)
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r > 0x7e || !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// validatePatterns checks each repository pattern in a policy list
+func validatePatterns(field string, patterns []string) []error {
+	var errs []error
+	for i, pattern := range patterns {
+		if err := validatePattern(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("%s[%d]: %v", field, i, err))
+		}
+	}
+	return errs
+}
+
+// validatePattern checks that a single repository pattern can be matched
+func validatePattern(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return errors.New("pattern must not be empty")
 	}
+
+	// Regular expression patterns must compile
+	if expr, ok := strings.CutPrefix(pattern, regexPatternPrefix); ok {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid regular expression '%s': %v", expr, err)
+		}
+		return nil
+	}
+
+	// Wildcards are only supported as a trailing suffix
+	if idx := strings.Index(pattern, "*"); idx != -1 && idx != len(pattern)-1 {
+		return fmt.Errorf("invalid pattern '%s': wildcard '*' is only supported at the end of a pattern", pattern)
+	}
+	return nil
+}
+
+// patternRegexps caches the compiled "re:" patterns by expression, so each is
+// compiled once rather than on every policy check
+var patternRegexps sync.Map
+
+// patternRegexp returns the compiled, anchored regular expression for expr.
+// Regular expressions are anchored so they must match the whole repository path.
+func patternRegexp(expr string) (*regexp.Regexp, error) {
+	if re, ok := patternRegexps.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, err
+	}
+	patternRegexps.Store(expr, re)
+	return re, nil
 }
 
 // repositoryMatches checks if a repository matches a pattern, supporting wildcards
+// and regular expressions prefixed with "re:"
 func repositoryMatches(pattern, repository string) bool {
+	if expr, ok := strings.CutPrefix(pattern, regexPatternPrefix); ok {
+		re, err := patternRegexp(expr)
+		if err != nil {
+			log.Printf("Invalid regular expression pattern %s: %v", pattern, err)
+			return false
+		}
+		return re.MatchString(repository)
+	}
+
 	// Simple wildcard support
 	if strings.HasSuffix(pattern, "*") {
 		return strings.HasPrefix(repository, strings.TrimSuffix(pattern, "*"))
@@ -105,3 +487,40 @@ func IsAllowed(repository string, policy *ImagePolicy) bool {
 	log.Printf("Repository %s did not match any allowed patterns, denying access", repository)
 	return false
 }
+
+// IsDownloadAllowed checks if content downloads from a repository are allowed by
+// the download-specific lists, with the same blocked-first rules as IsAllowed. It
+// only narrows IsAllowed, which must pass as well. With neither list configured
+// downloads follow the general policy alone.
+func IsDownloadAllowed(repository string, policy *ImagePolicy) bool {
+	if len(policy.DownloadAllowedRepositories) == 0 && len(policy.DownloadBlockedRepositories) == 0 {
+		return true
+	}
+	return IsAllowed(repository, &ImagePolicy{
+		AllowedRepositories: policy.DownloadAllowedRepositories,
+		BlockedRepositories: policy.DownloadBlockedRepositories,
+	})
+}
+
+// IsGone reports whether a repository matches gone_repositories, meaning it was
+// removed on purpose and won't come back
+func IsGone(repository string, policy *ImagePolicy) bool {
+	for _, gone := range policy.GoneRepositories {
+		if repositoryMatches(gone, repository) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithinNamespacePrefix reports whether a repository path (namespace/repository,
+// without the registry) lies under prefix. Matching is by whole path segments, so
+// the prefix "org" matches "org/app" but not "organization/app". An empty prefix
+// matches everything.
+func WithinNamespacePrefix(repositoryPath, prefix string) bool {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	return repositoryPath == prefix || strings.HasPrefix(repositoryPath, prefix+"/")
+}
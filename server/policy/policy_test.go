@@ -0,0 +1,194 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ConfigFile
+		// wantErrs are substrings the combined error must contain, one per problem
+		wantErrs []string
+	}{
+		{
+			name: "valid",
+			config: ConfigFile{
+				Registries:          []RegistryCredentials{{Name: "ghcr.io", Aliases: []string{"gh"}}},
+				AllowedRepositories: []string{"ghcr.io/org/*", `re:ghcr\.io/org/plugin-.+`},
+			},
+		},
+		{
+			name:     "no registries",
+			config:   ConfigFile{},
+			wantErrs: []string{"at least one registry must be configured"},
+		},
+		{
+			name:     "empty registry name",
+			config:   ConfigFile{Registries: []RegistryCredentials{{Name: " "}}},
+			wantErrs: []string{"registries[0]: name must not be empty"},
+		},
+		{
+			name:     "duplicate registry names",
+			config:   ConfigFile{Registries: []RegistryCredentials{{Name: "ghcr.io"}, {Name: "ghcr.io"}}},
+			wantErrs: []string{"registries[1]: duplicate registry name 'ghcr.io'"},
+		},
+		{
+			name: "alias collides with a registry",
+			config: ConfigFile{Registries: []RegistryCredentials{
+				{Name: "ghcr.io"},
+				{Name: "docker.io", Aliases: []string{"ghcr.io"}},
+			}},
+			wantErrs: []string{"conflicts with a registry name"},
+		},
+		{
+			name: "malformed patterns",
+			config: ConfigFile{
+				Registries:          []RegistryCredentials{{Name: "ghcr.io"}},
+				AllowedRepositories: []string{"ghcr.io/*/plugin", "re:ghcr.io/(unclosed"},
+				BlockedRepositories: []string{""},
+			},
+			wantErrs: []string{
+				"allowed_repositories[0]: invalid pattern 'ghcr.io/*/plugin'",
+				"allowed_repositories[1]: invalid regular expression",
+				"blocked_repositories[0]: pattern must not be empty",
+			},
+		},
+		{
+			name: "malformed API tokens",
+			config: ConfigFile{
+				Registries: []RegistryCredentials{{Name: "ghcr.io"}},
+				APITokens: []APIToken{
+					{Name: "ci", Token: "secret"},
+					{Name: "ci", Token: "secret"},
+					{Name: " ", Token: " ", AllowedRepositories: []string{""}},
+				},
+				PublicRepositories: []string{"ghcr.io/*/plugin"},
+			},
+			wantErrs: []string{
+				"api_tokens[1]: duplicate token name 'ci'",
+				"api_tokens[1]: token is the same as api_tokens[0]",
+				"api_tokens[2]: name must not be empty",
+				"api_tokens[2]: token must not be empty",
+				"api_tokens[2].allowed_repositories[0]: pattern must not be empty",
+				"public_repositories[0]: invalid pattern",
+			},
+		},
+		{
+			name: "every problem is reported",
+			config: ConfigFile{
+				Registries:      []RegistryCredentials{{Name: ""}, {Name: "a.example"}, {Name: "a.example"}},
+				MaxLayers:       -1,
+				DefaultRegistry: "b.example",
+				ResponseHeaders: map[string]string{"Bad Header": "x"},
+			},
+			wantErrs: []string{
+				"registries[0]: name must not be empty",
+				"registries[2]: duplicate registry name",
+				"max_layers: -1 must not be negative",
+				"default_registry: 'b.example' is not a configured registry or alias",
+				"response_headers: 'Bad Header' is not a valid header name",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q doesn't mention %q", err, want)
+				}
+			}
+			if got := len(strings.Split(err.Error(), "\n")); got != len(tt.wantErrs) {
+				t.Errorf("got %d problems, want %d:\n%v", got, len(tt.wantErrs), err)
+			}
+		})
+	}
+}
+
+func TestRepositoryMatches(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		repository string
+		want       bool
+	}{
+		{pattern: "ghcr.io/org/app", repository: "ghcr.io/org/app", want: true},
+		{pattern: "ghcr.io/org/app", repository: "ghcr.io/org/app2"},
+		{pattern: "ghcr.io/org/*", repository: "ghcr.io/org/app", want: true},
+		{pattern: "ghcr.io/org/*", repository: "ghcr.io/other/app"},
+		{pattern: `re:ghcr\.io/org/plugin-.+`, repository: "ghcr.io/org/plugin-seo", want: true},
+		// Regular expressions must match the whole path
+		{pattern: `re:ghcr\.io/org/plugin-.+`, repository: "mirror/ghcr.io/org/plugin-seo"},
+		{pattern: "re:org|other", repository: "org/app"},
+		{pattern: "re:(unclosed", repository: "(unclosed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.repository, func(t *testing.T) {
+			// Twice, so the cached expression is exercised as well
+			for range 2 {
+				if got := repositoryMatches(tt.pattern, tt.repository); got != tt.want {
+					t.Errorf("repositoryMatches(%q, %q) = %t, want %t", tt.pattern, tt.repository, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestIdentityAllows(t *testing.T) {
+	scoped := &ImagePolicy{
+		APITokens: []APIToken{
+			{Name: "team", Token: "team-token", AllowedRepositories: []string{"ghcr.io/team/*"}},
+			{Name: "other", Token: "other-token", AllowedRepositories: []string{"ghcr.io/other/*"}},
+		},
+		PublicRepositories: []string{"ghcr.io/public/*"},
+	}
+
+	tests := []struct {
+		name       string
+		policy     *ImagePolicy
+		token      string
+		repository string
+		want       bool
+	}{
+		{name: "no tokens configured", policy: &ImagePolicy{}, repository: "ghcr.io/team/app", want: true},
+		{name: "token in scope", policy: scoped, token: "team-token", repository: "ghcr.io/team/app", want: true},
+		{name: "token out of scope", policy: scoped, token: "other-token", repository: "ghcr.io/team/app"},
+		{name: "token reaches public", policy: scoped, token: "other-token", repository: "ghcr.io/public/app", want: true},
+		{name: "anonymous reaches public", policy: scoped, repository: "ghcr.io/public/app", want: true},
+		{name: "anonymous outside public", policy: scoped, repository: "ghcr.io/team/app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var token *APIToken
+			if tt.token != "" {
+				var ok bool
+				if token, ok = tt.policy.Authenticate(tt.token); !ok {
+					t.Fatalf("token %s didn't authenticate", tt.token)
+				}
+			}
+			if got := IdentityAllows(tt.repository, token, tt.policy); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+
+	// Public patterns must not pick up a token's patterns across calls
+	team, _ := scoped.Authenticate("team-token")
+	IdentityAllows("ghcr.io/team/app", team, scoped)
+	if IdentityAllows("ghcr.io/team/app", nil, scoped) {
+		t.Error("a token's patterns leaked into the public ones")
+	}
+	if _, ok := scoped.Authenticate("unknown"); ok {
+		t.Error("an unknown token authenticated")
+	}
+}
@@ -0,0 +1,403 @@
+package router
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+)
+
+// requireAdmin wraps a handler so it is only reachable with the admin token, sent as
+// "Authorization: Bearer <token>". Admin endpoints are disabled when no token is configured.
+func (m *ApiManager) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if m.Settings.AdminToken == "" {
+			http.Error(w, "admin endpoints are disabled: ORASHUB_ADMIN_TOKEN is not set", http.StatusForbidden)
+			return
+		}
+
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || !m.isAdminToken(token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="orashub"`)
+			http.Error(w, "invalid or missing admin token", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, req)
+	}
+}
+
+// isAdminToken reports whether token is the configured admin token
+func (m *ApiManager) isAdminToken(token string) bool {
+	return m.Settings.AdminToken != "" && subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(m.Settings.AdminToken)) == 1
+}
+
+// policyRegistry describes a configured registry in the policy response, without credentials
+type policyRegistry struct {
+	Name            string   `json:"name"`
+	Aliases         []string `json:"aliases"`
+	NamespacePrefix string   `json:"namespace_prefix,omitempty"`
+}
+
+// requiredAnnotations returns the annotation rules of a policy, never nil
+func requiredAnnotations(imagePolicy *policy.ImagePolicy) map[string]string {
+	if imagePolicy.RequiredAnnotations == nil {
+		return map[string]string{}
+	}
+	return imagePolicy.RequiredAnnotations
+}
+
+// HandlePolicy returns the effective repository policy and configured registries.
+// Credentials are never included.
+func (m *ApiManager) HandlePolicy(w http.ResponseWriter, req *http.Request) {
+	// Group aliases by the registry they stand for
+	set := m.registries()
+	aliases := make(map[string][]string)
+	for alias, name := range set.aliases {
+		aliases[name] = append(aliases[name], alias)
+	}
+
+	registries := make([]policyRegistry, 0, len(set.clients))
+	for name := range set.clients {
+		registryAliases := aliases[name]
+		if registryAliases == nil {
+			registryAliases = []string{}
+		}
+		sort.Strings(registryAliases)
+		registries = append(registries, policyRegistry{Name: name, Aliases: registryAliases, NamespacePrefix: set.namespacePrefixes[name]})
+	}
+	sort.Slice(registries, func(i, j int) bool { return registries[i].Name < registries[j].Name })
+
+	allowed := m.ImagePolicy.AllowedRepositories
+	if allowed == nil {
+		allowed = []string{}
+	}
+	blocked := m.ImagePolicy.BlockedRepositories
+	if blocked == nil {
+		blocked = []string{}
+	}
+
+	// Build response
+	response := map[string]interface{}{
+		"registries":              registries,
+		"allowed_repositories":    allowed,
+		"blocked_repositories":    blocked,
+		"require_nonempty_config": m.ImagePolicy.RequireNonemptyConfig,
+		"required_annotations":    requiredAnnotations(m.ImagePolicy),
+
+		"download_allowed_repositories": append([]string{}, m.ImagePolicy.DownloadAllowedRepositories...),
+		"download_blocked_repositories": append([]string{}, m.ImagePolicy.DownloadBlockedRepositories...),
+		"max_layers":                    m.ImagePolicy.MaxLayers,
+		"gone_repositories":             append([]string{}, m.ImagePolicy.GoneRepositories...),
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		m.Logger.Error("Error encoding policy response: %v", err)
+	}
+}
+
+// redacted replaces secret values in the admin config view
+const redacted = "***"
+
+// redact hides a secret, leaving empty values empty so it's still clear whether one is set
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// configRegistry describes a configured registry in the admin config view with its
+// credentials redacted
+type configRegistry struct {
+	Name            string   `json:"name"`
+	Aliases         []string `json:"aliases"`
+	NamespacePrefix string   `json:"namespace_prefix,omitempty"`
+	Username        string   `json:"username,omitempty"`
+	Password        string   `json:"password,omitempty"`
+	Available       bool     `json:"available"`
+	// ClientActive reports whether the registry currently holds a client, which is
+	// built on first use and torn down after ClientIdleTimeout
+	ClientActive bool                 `json:"client_active"`
+	Cache        *configRegistryCache `json:"cache,omitempty"`
+	DownloadURL  *configDownloadURL   `json:"download_url,omitempty"`
+	Error        string               `json:"error,omitempty"`
+}
+
+// configDownloadURL is a registry's download_url in the admin config view, with its
+// signing key redacted
+type configDownloadURL struct {
+	URL        string `json:"url"`
+	SigningKey string `json:"signing_key,omitempty"`
+	Expires    string `json:"expires,omitempty"`
+	MinSize    int64  `json:"min_size"`
+}
+
+// newConfigDownloadURL describes download, or returns nil when it isn't set
+func newConfigDownloadURL(download *policy.DownloadURL) *configDownloadURL {
+	if download == nil {
+		return nil
+	}
+	view := &configDownloadURL{URL: download.URL, SigningKey: redact(download.SigningKey), MinSize: download.MinSize}
+	if download.SigningKey != "" {
+		expires := download.Expires
+		if expires == 0 {
+			expires = defaultDownloadURLExpiry
+		}
+		view.Expires = expires.String()
+	}
+	return view
+}
+
+// configRegistryCache is a registry's cache overrides in the admin config view,
+// leaving out the ones that fall back to the global settings
+type configRegistryCache struct {
+	TagTTL      string `json:"tag_ttl,omitempty"`
+	ManifestTTL string `json:"manifest_ttl,omitempty"`
+	BlobCache   *bool  `json:"blob_cache,omitempty"`
+}
+
+// newConfigRegistryCache describes cache, or returns nil when it overrides nothing
+func newConfigRegistryCache(cache policy.RegistryCache) *configRegistryCache {
+	if cache.TagTTL == nil && cache.ManifestTTL == nil && cache.BlobCache == nil {
+		return nil
+	}
+	view := &configRegistryCache{BlobCache: cache.BlobCache}
+	if cache.TagTTL != nil {
+		view.TagTTL = cache.TagTTL.String()
+	}
+	if cache.ManifestTTL != nil {
+		view.ManifestTTL = cache.ManifestTTL.String()
+	}
+	return view
+}
+
+// configPolicy is the repository policy in the admin config view
+type configPolicy struct {
+	AllowedRepositories   []string          `json:"allowed_repositories"`
+	BlockedRepositories   []string          `json:"blocked_repositories"`
+	RequireNonemptyConfig bool              `json:"require_nonempty_config"`
+	RequiredAnnotations   map[string]string `json:"required_annotations"`
+	APITokens             []configAPIToken  `json:"api_tokens"`
+	PublicRepositories    []string          `json:"public_repositories"`
+
+	DownloadAllowedRepositories []string `json:"download_allowed_repositories"`
+	DownloadBlockedRepositories []string `json:"download_blocked_repositories"`
+	MaxLayers                   int      `json:"max_layers"`
+	GoneRepositories            []string `json:"gone_repositories"`
+}
+
+// configAPIToken describes an API token in the admin config view with its value redacted
+type configAPIToken struct {
+	Name                string   `json:"name"`
+	Token               string   `json:"token"`
+	AllowedRepositories []string `json:"allowed_repositories"`
+}
+
+// configCache holds the caching settings in the admin config view
+type configCache struct {
+	Backend               string `json:"backend"`
+	MaxEntries            int    `json:"max_entries"`
+	Dir                   string `json:"dir,omitempty"`
+	BlobCacheDir          string `json:"blob_cache_dir,omitempty"`
+	TagCacheTTL           string `json:"tag_cache_ttl"`
+	DigestLookupCacheTTL  string `json:"digest_lookup_cache_ttl"`
+	ArtifactTypesCacheTTL string `json:"artifact_types_cache_ttl"`
+	CacheControl          bool   `json:"cache_control"`
+	TagMaxAge             string `json:"tag_max_age"`
+}
+
+// configTimeouts holds the download and readiness timing settings in the admin config view
+type configTimeouts struct {
+	DialTimeout           string `json:"dial_timeout"`
+	TLSHandshakeTimeout   string `json:"tls_handshake_timeout"`
+	ClientIdleTimeout     string `json:"client_idle_timeout"`
+	DownloadStallTimeout  string `json:"download_stall_timeout"`
+	DownloadMinThroughput int64  `json:"download_min_throughput"`
+	ReadinessCacheTTL     string `json:"readiness_cache_ttl"`
+	ReadinessBackoffBase  string `json:"readiness_backoff_base"`
+	ReadinessBackoffMax   string `json:"readiness_backoff_max"`
+	// Routes are the effective per-route timeouts, keyed by route name; routes
+	// without a timeout are left out
+	Routes map[string]string `json:"routes"`
+}
+
+// configCopy holds the whole-artifact copy limits in the admin config view; zero
+// means the ORAS default, client.DefaultMaxIndexDepth for max_index_depth, or no
+// limit for max_bytes
+type configCopy struct {
+	Concurrency      int   `json:"concurrency"`
+	MaxMetadataBytes int64 `json:"max_metadata_bytes"`
+	MaxBytes         int64 `json:"max_bytes"`
+	MaxIndexDepth    int   `json:"max_index_depth"`
+}
+
+// configResponse is the response body of the admin config endpoint
+type configResponse struct {
+	LogLevel                     string            `json:"log_level"`
+	Registries                   []configRegistry  `json:"registries"`
+	DefaultRegistry              string            `json:"default_registry,omitempty"`
+	Policy                       configPolicy      `json:"policy"`
+	Cache                        configCache       `json:"cache"`
+	Timeouts                     configTimeouts    `json:"timeouts"`
+	Copy                         configCopy        `json:"copy"`
+	ResponseHeaders              map[string]string `json:"response_headers"`
+	FetchConcurrency             int               `json:"fetch_concurrency"`
+	DownloadBufferSize           int               `json:"download_buffer_size"`
+	DownloadResumeAttempts       int               `json:"download_resume_attempts"`
+	DownloadFilenameFromMetadata bool              `json:"download_filename_from_metadata"`
+	MaxDownloadsPerIP            int               `json:"max_downloads_per_ip"`
+	LatestFallback               string            `json:"latest_fallback,omitempty"`
+	ArtifactTypesMaxTags         int               `json:"artifact_types_max_tags"`
+	MetadataAnnotationKey        string            `json:"metadata_annotation_key"`
+	StaticDir                    string            `json:"static_dir,omitempty"`
+	RootRedirect                 bool              `json:"root_redirect"`
+	UpstreamTimingHeader         bool              `json:"upstream_timing_header"`
+	AllowCredentialOverride      bool              `json:"allow_credential_override"`
+	TrustedProxies               []string          `json:"trusted_proxies"`
+	CORSAllowedOrigins           []string          `json:"cors_allowed_origins"`
+	AdminToken                   string            `json:"admin_token"`
+}
+
+// HandleAdminConfig returns the effective runtime configuration for support and
+// debugging. Registry credentials and the admin token are replaced with ***.
+func (m *ApiManager) HandleAdminConfig(w http.ResponseWriter, req *http.Request) {
+	set := m.registries()
+	// List every configured registry, including the ones that failed to initialize
+	names := make([]string, 0, len(set.credentials))
+	for name := range set.credentials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	registries := make([]configRegistry, 0, len(names))
+	for _, name := range names {
+		registry := set.credentials[name]
+		aliases := append([]string{}, registry.Aliases...)
+		sort.Strings(aliases)
+		entry := configRegistry{
+			Name:            name,
+			Aliases:         aliases,
+			NamespacePrefix: registry.NamespacePrefix,
+			Username:        redact(registry.Username),
+			Password:        redact(registry.Password),
+			Available:       set.clients[name] != nil,
+			Cache:           newConfigRegistryCache(registry.Cache),
+			DownloadURL:     newConfigDownloadURL(registry.DownloadURL),
+		}
+		if holder, ok := set.clients[name]; ok {
+			entry.ClientActive = holder.live()
+		}
+		if err, ok := set.unavailable[name]; ok {
+			entry.Error = err.Error()
+		}
+		registries = append(registries, entry)
+	}
+
+	repositoryPolicy := configPolicy{
+		AllowedRepositories:   append([]string{}, m.ImagePolicy.AllowedRepositories...),
+		BlockedRepositories:   append([]string{}, m.ImagePolicy.BlockedRepositories...),
+		RequireNonemptyConfig: m.ImagePolicy.RequireNonemptyConfig,
+		RequiredAnnotations:   requiredAnnotations(m.ImagePolicy),
+		APITokens:             make([]configAPIToken, 0, len(m.ImagePolicy.APITokens)),
+		PublicRepositories:    append([]string{}, m.ImagePolicy.PublicRepositories...),
+
+		DownloadAllowedRepositories: append([]string{}, m.ImagePolicy.DownloadAllowedRepositories...),
+		DownloadBlockedRepositories: append([]string{}, m.ImagePolicy.DownloadBlockedRepositories...),
+		MaxLayers:                   m.ImagePolicy.MaxLayers,
+		GoneRepositories:            append([]string{}, m.ImagePolicy.GoneRepositories...),
+	}
+	for _, token := range m.ImagePolicy.APITokens {
+		repositoryPolicy.APITokens = append(repositoryPolicy.APITokens, configAPIToken{
+			Name:                token.Name,
+			Token:               redact(token.Token),
+			AllowedRepositories: append([]string{}, token.AllowedRepositories...),
+		})
+	}
+
+	settings := m.Settings
+	backend := settings.CacheBackend
+	if backend == "" {
+		backend = "memory"
+	}
+	routeTimeouts := make(map[string]string)
+	for _, route := range m.Routes {
+		if route.Timeout > 0 {
+			routeTimeouts[routeKey(route)] = route.Timeout.String()
+		}
+	}
+	proxies := []string{}
+	for _, prefix := range settings.TrustedProxies {
+		proxies = append(proxies, prefix.String())
+	}
+	responseHeaders := m.responseHeaders
+	if responseHeaders == nil {
+		responseHeaders = map[string]string{}
+	}
+
+	response := configResponse{
+		LogLevel:        m.Logger.GetLevel().String(),
+		Registries:      registries,
+		DefaultRegistry: m.DefaultRegistry(),
+		Policy:          repositoryPolicy,
+		Cache: configCache{
+			Backend:               backend,
+			MaxEntries:            settings.CacheMaxEntries,
+			Dir:                   settings.CacheDir,
+			BlobCacheDir:          settings.BlobCacheDir,
+			TagCacheTTL:           settings.TagCacheTTL.String(),
+			DigestLookupCacheTTL:  settings.DigestLookupCacheTTL.String(),
+			ArtifactTypesCacheTTL: settings.ArtifactTypesCacheTTL.String(),
+			CacheControl:          settings.CacheControl,
+			TagMaxAge:             settings.TagMaxAge.String(),
+		},
+		Timeouts: configTimeouts{
+			DialTimeout:           settings.DialTimeout.String(),
+			TLSHandshakeTimeout:   settings.TLSHandshakeTimeout.String(),
+			ClientIdleTimeout:     settings.ClientIdleTimeout.String(),
+			DownloadStallTimeout:  settings.DownloadStallTimeout.String(),
+			DownloadMinThroughput: settings.DownloadMinThroughput,
+			ReadinessCacheTTL:     settings.ReadinessCacheTTL.String(),
+			ReadinessBackoffBase:  settings.ReadinessBackoffBase.String(),
+			ReadinessBackoffMax:   settings.ReadinessBackoffMax.String(),
+			Routes:                routeTimeouts,
+		},
+		Copy: configCopy{
+			Concurrency:      settings.CopyLimits.Concurrency,
+			MaxMetadataBytes: settings.CopyLimits.MaxMetadataBytes,
+			MaxBytes:         settings.CopyLimits.MaxBytes,
+			MaxIndexDepth:    settings.CopyLimits.MaxIndexDepth,
+		},
+		ResponseHeaders:              responseHeaders,
+		FetchConcurrency:             settings.FetchConcurrency,
+		DownloadBufferSize:           settings.DownloadBufferSize,
+		DownloadResumeAttempts:       settings.DownloadResumeAttempts,
+		DownloadFilenameFromMetadata: settings.DownloadFilenameFromMetadata,
+		MaxDownloadsPerIP:            settings.MaxDownloadsPerIP,
+		LatestFallback:               settings.LatestFallback,
+		ArtifactTypesMaxTags:         settings.ArtifactTypesMaxTags,
+		MetadataAnnotationKey:        settings.MetadataAnnotationKey,
+		StaticDir:                    settings.StaticDir,
+		RootRedirect:                 settings.RootRedirect,
+		UpstreamTimingHeader:         settings.UpstreamTimingHeader,
+		AllowCredentialOverride:      settings.AllowCredentialOverride,
+		TrustedProxies:               proxies,
+		CORSAllowedOrigins:           append([]string{}, settings.CORSAllowedOrigins...),
+		AdminToken:                   redact(settings.AdminToken),
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		m.Logger.Error("Error encoding config response: %v", err)
+	}
+}
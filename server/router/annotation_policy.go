@@ -0,0 +1,60 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/codekaizen-github/orashub/client"
+)
+
+// annotationPolicyEnabled reports whether required_annotations is configured. Those
+// rules need the manifest, so each checked request costs an extra manifest fetch.
+func (m *ApiManager) annotationPolicyEnabled() bool {
+	return m.ImagePolicy != nil && len(m.ImagePolicy.RequiredAnnotations) > 0
+}
+
+// manifestAnnotationsAllowed checks raw manifest content against required_annotations.
+// Manifests that aren't JSON have no annotations, so they only pass without rules.
+func (m *ApiManager) manifestAnnotationsAllowed(content []byte) error {
+	if !m.annotationPolicyEnabled() {
+		return nil
+	}
+	var manifest struct {
+		Annotations map[string]string `json:"annotations"`
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		manifest.Annotations = nil
+	}
+	return m.ImagePolicy.CheckAnnotations(manifest.Annotations)
+}
+
+// writeAnnotationDenied rejects a request for an artifact that fails required_annotations
+func (m *ApiManager) writeAnnotationDenied(w http.ResponseWriter, repository, reference string, err error) {
+	m.Logger.Warn("Access denied to %s:%s by annotation policy: %v", repository, reference, err)
+	http.Error(w, fmt.Sprintf("artifact is denied by policy: %v", err), http.StatusForbidden)
+}
+
+// checkAnnotationPolicy enforces required_annotations for reference, fetching its
+// manifest when rules are configured. It returns the reference to read the artifact
+// with from then on: the digest of the checked manifest, so a tag that moves in
+// between can't serve content that wasn't checked, or reference itself without
+// rules. It writes the error response and returns false when the artifact may not
+// be served.
+func (m *ApiManager) checkAnnotationPolicy(w http.ResponseWriter, apiClient client.ClientInterface, repository, reference string) (string, bool) {
+	if !m.annotationPolicyEnabled() {
+		return reference, true
+	}
+
+	desc, content, err := apiClient.FetchManifest(repository, reference)
+	if err != nil {
+		m.Logger.Error("Error getting manifest of %s:%s for the annotation policy: %v", repository, reference, err)
+		writeRegistryError(w, err)
+		return "", false
+	}
+	if err := m.manifestAnnotationsAllowed(content); err != nil {
+		m.writeAnnotationDenied(w, repository, reference, err)
+		return "", false
+	}
+	return desc.Digest.String(), true
+}
@@ -0,0 +1,88 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/client"
+	"github.com/codekaizen-github/orashub/server/policy"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// movingClient re-points a tag at another manifest right after its first fetch by
+// tag, like a push racing the request
+type movingClient struct {
+	*fakeClient
+	repository, tag string
+	next            v1.Manifest
+	moved           bool
+}
+
+func (c *movingClient) WithContext(ctx context.Context) client.ClientInterface { return c }
+
+func (c *movingClient) WithTimings(timings *client.Timings) client.ClientInterface { return c }
+
+func (c *movingClient) FetchManifest(repository, reference string) (*v1.Descriptor, []byte, error) {
+	desc, content, err := c.fakeClient.FetchManifest(repository, reference)
+	if !c.moved && repository == c.repository && reference == c.tag {
+		c.moved = true
+		c.fakeClient.addManifest(c.repository, c.next, c.tag)
+	}
+	return desc, content, err
+}
+
+func TestAnnotationPolicyPinsDigest(t *testing.T) {
+	const repository = "team/app"
+	fake := newFakeClient()
+	approved := fake.addManifest(repository, v1.Manifest{Annotations: map[string]string{"approved": "true"}}, "1.0.0")
+	moving := &movingClient{
+		fakeClient: fake,
+		repository: repository,
+		tag:        "1.0.0",
+		next:       v1.Manifest{Annotations: map[string]string{"approved": "false"}},
+	}
+	config := &policy.ConfigFile{RequiredAnnotations: map[string]string{"approved": "true"}}
+	manager := newTestManager(t, config, DefaultApiSettings(), nil)
+	manager.registries().clients[testRegistry].client = moving
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "descriptor", path: "/descriptor/"},
+		{name: "manifest", path: "/manifest/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			moving.moved = false
+			fake.addManifest(repository, v1.Manifest{Annotations: map[string]string{"approved": "true"}}, "1.0.0")
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/"+testRegistry+"/"+repository+"/1.0.0"+tt.path, nil)
+			resp := serve(manager, req)
+			if resp.Code != http.StatusOK {
+				t.Fatalf("got status %d: %s", resp.Code, resp.Body)
+			}
+			if !moving.moved {
+				t.Fatal("the tag was never fetched by name")
+			}
+
+			// Whatever the tag points at now, the checked manifest is served
+			var body struct {
+				Digest      string            `json:"digest"`
+				Annotations map[string]string `json:"annotations"`
+			}
+			if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Digest != "" && body.Digest != approved.String() {
+				t.Errorf("served %s, want the checked %s", body.Digest, approved)
+			}
+			if body.Annotations != nil && body.Annotations["approved"] != "true" {
+				t.Errorf("served annotations %v, want the checked manifest's", body.Annotations)
+			}
+		})
+	}
+}
@@ -6,19 +6,27 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/codekaizen-github/orashub/client"
+	"github.com/codekaizen-github/orashub/server/cache"
 	"github.com/codekaizen-github/orashub/server/logger"
 	"github.com/codekaizen-github/orashub/server/policy"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 // Custom error types
 var (
-	ErrRegistryNotFound  = errors.New("registry not found")
-	ErrNoRegistryClients = errors.New("no registry clients available")
+	ErrRegistryNotFound    = errors.New("registry not found")
+	ErrNoRegistryClients   = errors.New("no registry clients available")
+	ErrInvalidCredentials  = errors.New("invalid registry credentials header")
+	ErrRegistryUnavailable = errors.New("registry unavailable")
 )
 
 // RouteDefinition defines an API route and associated handler
@@ -27,48 +35,227 @@ type RouteDefinition struct {
 	Pattern     string
 	Description string
 	Handler     func(http.ResponseWriter, *http.Request)
+	// ExposedHeaders are response headers made readable to cross-origin scripts when CORS is enabled
+	ExposedHeaders []string
+	// Timeout bounds the registry calls made while serving the route; zero means no
+	// timeout. ORASHUB_ROUTE_TIMEOUTS overrides it.
+	Timeout time.Duration
+}
+
+// ApiSettings holds tunable runtime settings for the API manager
+type ApiSettings struct {
+	// FetchConcurrency bounds the number of concurrent registry fetches made by fan-out endpoints
+	FetchConcurrency int
+	// DigestLookupCacheTTL is how long tags-for-digest results are cached
+	DigestLookupCacheTTL time.Duration
+	// CacheControl enables Cache-Control headers on manifest, descriptor and download responses
+	CacheControl bool
+	// TagMaxAge is the max-age used for responses to tag references; zero means no-cache
+	TagMaxAge time.Duration
+	// StaticDir is an optional directory of static files served in preference to the embedded defaults
+	StaticDir string
+	// DownloadStallTimeout aborts a download when a single write blocks for this long, and is the
+	// window over which DownloadMinThroughput is measured. Zero disables stall protection.
+	DownloadStallTimeout time.Duration
+	// AllowCredentialOverride lets callers supply their own registry credentials per request
+	// via CredentialOverrideHeader. This trusts the caller and is off by default.
+	AllowCredentialOverride bool
+	// DownloadMinThroughput is the minimum average bytes per second a client must read a download at
+	// over each DownloadStallTimeout window. Zero disables the throughput check.
+	DownloadMinThroughput int64
+	// ReadinessCacheTTL is how long a successful registry probe is reused by /readyz
+	ReadinessCacheTTL time.Duration
+	// ReadinessBackoffBase and ReadinessBackoffMax bound the jittered exponential backoff
+	// before a registry that failed its probe is probed again
+	ReadinessBackoffBase time.Duration
+	ReadinessBackoffMax  time.Duration
+	// DownloadBufferSize is the size in bytes of the pooled buffers used to stream downloads
+	DownloadBufferSize int
+	// DownloadResumeAttempts caps how often a layer fetch is resumed after the registry
+	// connection drops mid-stream; zero disables resumption
+	DownloadResumeAttempts int
+	// CacheBackend selects the storage used by response caches: "memory" (default) or "disk"
+	CacheBackend string
+	// CacheMaxEntries bounds the memory cache backend
+	CacheMaxEntries int
+	// CacheDir is the directory used by the disk cache backend
+	CacheDir string
+	// BlobCacheDir is where downloaded layer blobs are kept; empty disables the blob cache
+	BlobCacheDir string
+	// TagCacheTTL is how long tag listings are cached; zero disables the cache
+	TagCacheTTL time.Duration
+	// AdminToken is the bearer token required by admin endpoints such as the policy
+	// view. Admin endpoints are disabled when it is empty.
+	AdminToken string
+	// RootRedirect makes the root endpoint redirect to the API root instead of rendering HTML
+	RootRedirect bool
+	// ArtifactTypesMaxTags caps the number of tags scanned by the artifact types endpoint;
+	// zero or less scans every tag
+	ArtifactTypesMaxTags int
+	// ArtifactTypesCacheTTL is how long artifact type summaries are cached
+	ArtifactTypesCacheTTL time.Duration
+	// MetadataAnnotationKey is the manifest annotation holding plugin metadata as a JSON object
+	MetadataAnnotationKey string
+	// DownloadFilenameFromMetadata names downloads {slug}.{version}.zip from the plugin
+	// metadata, as ?filename=auto does per request
+	DownloadFilenameFromMetadata bool
+	// TrustedProxies lists the peers that may set X-Forwarded-* headers; empty trusts none
+	TrustedProxies TrustedProxies
+	// UpstreamTimingHeader adds UpstreamDurationHeader to API responses, reporting the
+	// time spent waiting on the registry
+	UpstreamTimingHeader bool
+	// CORSAllowedOrigins enables CORS for the listed origins; empty disables CORS
+	CORSAllowedOrigins CORSOrigins
+	// DialTimeout and TLSHandshakeTimeout bound connection setup to a registry,
+	// independently of how long the request itself may take
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	// ClientIdleTimeout tears down a registry's client after it has gone unused this
+	// long; the next request builds a new one. Zero keeps clients for the process lifetime.
+	ClientIdleTimeout time.Duration
+	// RouteTimeouts overrides the timeouts declared in the route table, keyed by
+	// route name (e.g. "manifest"); zero removes a route's timeout
+	RouteTimeouts map[string]time.Duration
+	// DefaultRegistry is the registry, by name or alias, that API paths without a
+	// registry segment are routed to. Empty uses the config file's default_registry,
+	// or else its first registry.
+	DefaultRegistry string
+	// LatestFallback serves requests for a missing latest tag from another tag:
+	// LatestFallbackSemver or LatestFallbackStable; empty serves them a 404
+	LatestFallback string
+	// MaxDownloadsPerIP caps the content streams (downloads, blobs and OCI layout
+	// exports) one client IP may have open at once; zero means no limit
+	MaxDownloadsPerIP int
+	// CopyLimits tunes the ORAS copies that pull a whole artifact (descriptor lookups and
+	// OCI layout exports); zero values keep the ORAS defaults
+	CopyLimits client.CopyLimits
+}
+
+// DefaultApiSettings returns the settings used when nothing is overridden
+func DefaultApiSettings() ApiSettings {
+	return ApiSettings{
+		FetchConcurrency:       8,
+		DownloadBufferSize:     256 << 10,
+		DownloadResumeAttempts: 3,
+		DialTimeout:            30 * time.Second,
+		TLSHandshakeTimeout:    10 * time.Second,
+		DigestLookupCacheTTL:   time.Minute,
+		ReadinessCacheTTL:      10 * time.Second,
+		ReadinessBackoffBase:   time.Second,
+		ReadinessBackoffMax:    time.Minute,
+		ArtifactTypesMaxTags:   100,
+		ArtifactTypesCacheTTL:  time.Minute,
+		MetadataAnnotationKey:  client.PluginMetadataAnnotation,
+	}
 }
 
 // ApiManager manages the API routing and client interactions
 type ApiManager struct {
-	Clients     map[string]client.ClientInterface
 	Templates   *template.Template
 	ImagePolicy *policy.ImagePolicy
 	Routes      []RouteDefinition
 	Logger      logger.Logger
+	Settings    ApiSettings
+	// DownloadURLResolver redirects downloads to mirrored copies of their blobs.
+	// It defaults to each registry's download_url; nil streams every download.
+	DownloadURLResolver DownloadURLResolver
+
+	// registrySet holds the configured registries, swapped as a whole on reload
+	registrySet atomic.Pointer[registrySet]
+	reloadMu    sync.Mutex
+
+	fetchLimiter       *fetchLimiter
+	downloadsPerIP     *ipLimiter
+	digestLookupCache  *ttlCache[[]string]
+	tagCache           *ttlCache[[]string]
+	tagMetadataCache   *ttlCache[tagMetadata]
+	artifactTypesCache *ttlCache[artifactTypesResponse]
+	cacheBackend       cache.Cache
+	blobCache          *client.BlobCache
+	staticFS           fs.FS
+	downloadBuffers    *bufferPool
+	clientOptions      []client.Option
+	// responseHeaders are the configured default response headers, reported by the admin config view
+	responseHeaders map[string]string
 }
 
 // NewApiManager creates a new API manager with the given configuration
-func NewApiManager(config *policy.ConfigFile, imagePolicy *policy.ImagePolicy, templates *template.Template, logger logger.Logger) *ApiManager {
+func NewApiManager(config *policy.ConfigFile, imagePolicy *policy.ImagePolicy, templates *template.Template, logger logger.Logger, settings ApiSettings) *ApiManager {
 	// Check if there are any registries configured - this is a fatal error if not
 	if len(config.Registries) == 0 {
 		logger.Error("Fatal error: No registries configured. Please specify at least one registry in the configuration.")
 		log.Fatalf("Fatal error: No registries configured. Please specify at least one registry in the configuration.")
 	}
 
+	// Create the storage backend shared by all response caches
+	cacheBackend, err := cache.New(cache.Options{
+		Backend:    settings.CacheBackend,
+		MaxEntries: settings.CacheMaxEntries,
+		Dir:        settings.CacheDir,
+	})
+	if err != nil {
+		logger.Error("Fatal error: Could not create cache backend: %v", err)
+		log.Fatalf("Fatal error: Could not create cache backend: %v", err)
+	}
+
+	// Options shared by every registry client, including per-request ones
+	clientOptions := []client.Option{
+		client.WithLogger(logger),
+		client.WithResumeAttempts(settings.DownloadResumeAttempts),
+		client.WithTransport(client.NewTransport(settings.DialTimeout, settings.TLSHandshakeTimeout)),
+		client.WithCopyLimits(settings.CopyLimits),
+	}
+	var blobCache *client.BlobCache
+	if settings.BlobCacheDir != "" {
+		blobCache, err = client.NewBlobCache(settings.BlobCacheDir)
+		if err != nil {
+			logger.Error("Fatal error: Could not create blob cache: %v", err)
+			log.Fatalf("Fatal error: Could not create blob cache: %v", err)
+		}
+		clientOptions = append(clientOptions, client.WithBlobCache(blobCache))
+	}
+
+	var downloadsPerIP *ipLimiter
+	if settings.MaxDownloadsPerIP > 0 {
+		downloadsPerIP = newIPLimiter(settings.MaxDownloadsPerIP)
+	}
+
 	manager := &ApiManager{
-		Clients:     make(map[string]client.ClientInterface),
 		ImagePolicy: imagePolicy,
 		Templates:   templates,
 		Logger:      logger,
+		Settings:    settings,
+
+		fetchLimiter:       newFetchLimiter(settings.FetchConcurrency),
+		downloadsPerIP:     downloadsPerIP,
+		digestLookupCache:  newTTLCache[[]string](cacheBackend, "digest-lookup", settings.DigestLookupCacheTTL),
+		tagCache:           newTTLCache[[]string](cacheBackend, "tags", settings.TagCacheTTL),
+		tagMetadataCache:   newTTLCache[tagMetadata](cacheBackend, "tag-metadata", settings.TagCacheTTL),
+		artifactTypesCache: newTTLCache[artifactTypesResponse](cacheBackend, "artifact-types", settings.ArtifactTypesCacheTTL),
+		cacheBackend:       cacheBackend,
+		blobCache:          blobCache,
+		staticFS:           newStaticFS(settings.StaticDir),
+		downloadBuffers:    newBufferPool(settings.DownloadBufferSize),
+		clientOptions:      clientOptions,
+		responseHeaders:    config.ResponseHeaders,
 	}
 
-	// Create clients for each registry in the config
-	for _, registry := range config.Registries {
+	manager.DownloadURLResolver = registryDownloadURLs{manager: manager}
 
-		// Create client for this registry
-		apiClient := client.NewClient(
-			registry.Name,
-			registry.Username,
-			registry.Password,
-		)
+	// Set up clients for each registry in the config, built on first use
+	registries := manager.buildRegistrySet(config, nil)
+	manager.registrySet.Store(registries)
+	if settings.ClientIdleTimeout > 0 {
+		go manager.evictIdleClients(settings.ClientIdleTimeout)
+	}
 
-		// Store client in map
-		manager.Clients[registry.Name] = apiClient
+	if len(registries.clients) == 0 {
+		logger.Error("No registries could be initialized; every registry route will return 503")
 	}
 
 	// Define routes after creating the manager so handlers can be properly bound
 	manager.defineRoutes()
+	manager.applyRouteTimeouts()
 
 	return manager
 }
@@ -78,21 +265,103 @@ func (m *ApiManager) defineRoutes() {
 	m.Routes = []RouteDefinition{
 		{Method: "GET", Pattern: "/{$}", Description: "Root endpoint", Handler: m.HandleRoot},
 		{Method: "GET", Pattern: "/api/v1/{$}", Description: "API root information", Handler: m.HandleApiRoot},
-		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{$}", Description: "List tags", Handler: m.HandleListTags},
-		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/{$}", Description: "Resource info", Handler: m.HandleResourceInfo},
-		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/descriptor/{$}", Description: "Descriptor", Handler: m.HandleDescriptor},
-		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/manifest/{$}", Description: "Manifest", Handler: m.HandleManifest},
-		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/download/{$}", Description: "Download", Handler: m.HandleDownload},
+		{Method: "GET", Pattern: "/api/v1/routes/{$}", Description: "Routes", Handler: m.HandleRoutes},
+		{Method: "GET", Pattern: "/api/v1/policy/{$}", Description: "Policy", Handler: m.requireAdmin(m.HandlePolicy)},
+		{Method: "GET", Pattern: "/api/v1/admin/config/{$}", Description: "Admin config", Handler: m.requireAdmin(m.HandleAdminConfig)},
+		{Method: "GET", Pattern: "/api/v1/admin/cache/stats/{$}", Description: "Cache stats", Handler: m.requireAdmin(m.HandleCacheStats)},
+		{Method: "GET", Pattern: "/api/v1/search/{$}", Description: "Search", Handler: m.withIdentity(m.HandleSearch), Timeout: metadataRouteTimeout},
+		{Method: "GET", Pattern: "/api/v1/{registry}/_catalog/{$}", Description: "List repositories", Handler: m.HandleCatalog, Timeout: metadataRouteTimeout},
+		{Method: "GET", Pattern: "/api/v1/{registry}/_debug/v2/{$}", Description: "Debug registry base", Handler: m.requireAdmin(m.HandleDebugBase), Timeout: metadataRouteTimeout},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{$}", Description: "List tags", Handler: m.HandleListTags, Timeout: metadataRouteTimeout},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/diff/{$}", Description: "Diff", Handler: m.HandleDiff},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/artifact-types/{$}", Description: "Artifact types", Handler: m.HandleArtifactTypes},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/stable/{$}", Description: "Stable", Handler: m.HandleStable, Timeout: metadataRouteTimeout},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/{$}", Description: "Resource info", Handler: m.HandleResourceInfo, Timeout: metadataRouteTimeout},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/descriptor/{$}", Description: "Descriptor", Handler: m.HandleDescriptor, Timeout: metadataRouteTimeout},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/manifest/{$}", Description: "Manifest", Handler: m.HandleManifest, Timeout: metadataRouteTimeout},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/download/{$}", Description: "Download", Handler: m.limitDownloadsPerIP(m.HandleDownload), ExposedHeaders: downloadExposedHeaders},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/config/{$}", Description: "Config", Handler: m.HandleConfig, Timeout: metadataRouteTimeout},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/tags/{$}", Description: "Tags for digest", Handler: m.HandleTagsForDigest},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/size/{$}", Description: "Size", Handler: m.HandleSize, Timeout: metadataRouteTimeout},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/validate/{$}", Description: "Validate", Handler: m.HandleValidate, Timeout: metadataRouteTimeout},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/oci-layout/{$}", Description: "OCI layout export", Handler: m.limitDownloadsPerIP(m.HandleOCILayout)},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/readme/{$}", Description: "Readme", Handler: m.HandleReadme, Timeout: metadataRouteTimeout},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/index/{$}", Description: "Index manifests", Handler: m.HandleIndex, Timeout: metadataRouteTimeout},
+		{Method: "POST", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/compare/{$}", Description: "Compare", Handler: m.HandleCompare, Timeout: metadataRouteTimeout},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/file/{path...}", Description: "File", Handler: m.HandleArchiveFile},
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/asset/{name}", Description: "Asset", Handler: m.HandleAsset},
+		// Keyed like the other per-reference routes: a blobs/{digest} segment would overlap them
+		{Method: "GET", Pattern: "/api/v1/{registry}/{namespace}/{repository}/{digest}/blob/{$}", Description: "Blob", Handler: m.limitDownloadsPerIP(m.HandleBlob), ExposedHeaders: blobExposedHeaders},
 	}
 }
 
-// SetupRoutes registers all HTTP routes for the server
+// SetupRoutes registers all HTTP routes for the server. Every route also answers
+// OPTIONS with the methods it supports.
 func (m *ApiManager) SetupRoutes(mux *http.ServeMux) {
+	var registered routeMethods
+	for _, route := range m.Routes {
+		registered.add(route.Method, route.Pattern)
+	}
+	cors := len(m.Settings.CORSAllowedOrigins) > 0
+
 	// Register all routes from our routes data structure
 	for _, route := range m.Routes {
 		pattern := fmt.Sprintf("%s %s", route.Method, route.Pattern)
 		m.Logger.Info("Registering route: %s", pattern)
-		mux.HandleFunc(pattern, route.Handler)
+		handler := route.Handler
+		exposed := route.ExposedHeaders
+		if hasTag(route.Pattern) {
+			handler = m.withTagFallback(m.withLatestFallback(handler))
+			exposed = append(append([]string{}, exposed...), ResolvedTagHeader)
+		}
+		if route.Timeout > 0 {
+			handler = withRouteTimeout(route.Timeout, handler)
+		}
+		if hasRegistry(route.Pattern) {
+			handler = m.withUpstreamHeaders(m.withIdentity(handler))
+			exposed = append(append([]string{}, exposed...), upstreamHeaders...)
+		}
+		if m.Settings.UpstreamTimingHeader {
+			handler = withUpstreamTiming(handler)
+		}
+		if cors {
+			handler = m.withCORS(registered.allow(route.Pattern), exposed, handler)
+		}
+		mux.HandleFunc(pattern, handler)
+	}
+
+	// Register static asset handlers outside of the API route table
+	mux.HandleFunc("GET /favicon.ico", m.HandleFavicon)
+	registered.add(http.MethodGet, "/favicon.ico")
+	mux.HandleFunc("GET /static/{path...}", m.HandleStatic)
+	registered.add(http.MethodGet, "/static/{path...}")
+
+	// Register the readiness probe for orchestrators
+	mux.HandleFunc("GET /readyz", m.HandleReadyz)
+	registered.add(http.MethodGet, "/readyz")
+
+	// Redirect the other spelling of each route to its canonical one, so clients
+	// get the same result whether or not they add a trailing slash
+	for _, pattern := range registered.patterns {
+		if variant, ok := slashVariant(pattern); ok {
+			for _, method := range registered.methods[pattern] {
+				// GET patterns already match HEAD
+				if method != http.MethodHead {
+					mux.HandleFunc(method+" "+variant, redirectSlash)
+				}
+			}
+			registered.alias(variant, pattern)
+		}
+	}
+
+	// Answer OPTIONS on every route and its redirecting spelling with the allowed
+	// methods, doubling as the CORS preflight response when CORS is enabled
+	for _, pattern := range registered.patterns {
+		handler := handleOptions(registered.allow(pattern))
+		if cors {
+			handler = m.withCORS(registered.allow(pattern), nil, handler)
+		}
+		mux.HandleFunc(http.MethodOptions+" "+pattern, handler)
 	}
 
 	// // Add a catch-all handler for any routes that don't match
@@ -102,26 +371,79 @@ func (m *ApiManager) SetupRoutes(mux *http.ServeMux) {
 	// })
 }
 
+// checkConfigPolicy enforces require_nonempty_config, rejecting artifacts whose config
+// is the empty descriptor with 422. Returns false when a response has been written.
+func (m *ApiManager) checkConfigPolicy(w http.ResponseWriter, content []byte) bool {
+	if m.ImagePolicy == nil || !m.ImagePolicy.RequireNonemptyConfig {
+		return true
+	}
+
+	manifest, err := client.ParseManifest(content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if manifest.Config.MediaType == v1.MediaTypeEmptyJSON {
+		http.Error(w, fmt.Sprintf("artifact has an empty config (%s), which is rejected by policy", v1.MediaTypeEmptyJSON), http.StatusUnprocessableEntity)
+		return false
+	}
+	return true
+}
+
+// manifestPluginMetadata returns the plugin metadata of raw manifest content, or nil
+// when the manifest can't be parsed or has none
+func (m *ApiManager) manifestPluginMetadata(content []byte) map[string]interface{} {
+	manifest, err := client.ParseManifest(content)
+	if err != nil {
+		return nil
+	}
+	metadata, _ := manifest.GetPluginMetadata(m.Settings.MetadataAnnotationKey)
+	return metadata
+}
+
 // getClient returns the client for the specified registry
 // Returns error of type ErrRegistryNotFound if the registry was not found
 // Returns error of type ErrNoRegistryClients if no clients are available
 func (m *ApiManager) getClient(registry string) (client.ClientInterface, error) {
-	// Try to get the client for the specified registry
-	if client, ok := m.Clients[registry]; ok {
-		return client, nil
+	// Try to get the client for the specified registry, resolving aliases first
+	registries := m.registries()
+	name := registries.resolve(registry)
+	if holder, ok := registries.clients[name]; ok {
+		apiClient, err := holder.get()
+		if err != nil {
+			return nil, fmt.Errorf("%w: '%s': %v", ErrRegistryUnavailable, registry, err)
+		}
+		return apiClient, nil
+	}
+	if err, ok := registries.unavailable[name]; ok {
+		return nil, fmt.Errorf("%w: '%s': %v", ErrRegistryUnavailable, registry, err)
 	}
 
 	// If the registry doesn't exist in our clients map
 	return nil, fmt.Errorf("%w: '%s'", ErrRegistryNotFound, registry)
-} // HandleRoot handles the root endpoint
+}
+
+// resolveRegistry maps a registry alias to the registry name it stands for.
+// Names that aren't aliases are returned unchanged.
+func (m *ApiManager) resolveRegistry(registry string) string {
+	return m.registries().resolve(registry)
+}
+
+// HandleRoot handles the root endpoint
 func (m *ApiManager) HandleRoot(w http.ResponseWriter, req *http.Request) {
 
 	// Check if we have any clients configured
-	if len(m.Clients) == 0 {
+	if len(m.registries().clients) == 0 {
 		http.Error(w, "No registry clients configured", http.StatusServiceUnavailable)
 		return
 	}
 
+	// Headless deployments skip the landing page entirely
+	if m.Settings.RootRedirect {
+		http.Redirect(w, req, getServerInfo(req, m.Settings.TrustedProxies).ApiURL, http.StatusFound)
+		return
+	}
+
 	// Define template data with relative URL
 	data := struct {
 		ApiURL string
@@ -129,26 +451,15 @@ func (m *ApiManager) HandleRoot(w http.ResponseWriter, req *http.Request) {
 		ApiURL: "/api/v1",
 	}
 
-	// Execute template
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-
-	if m.Templates != nil {
-		if err := m.Templates.ExecuteTemplate(w, "index.html", data); err != nil {
-			m.Logger.Error("Error executing template: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-		}
-	} else {
-		// This should never happen as we always set up a template in main.go
-		m.Logger.Warn("No templates available")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-	}
+	// Execute template, writing the status only once it has rendered
+	m.renderTemplate(w, "index.html", data)
 }
 
 // HandleApiRoot handles the API root endpoint
 func (m *ApiManager) HandleApiRoot(w http.ResponseWriter, req *http.Request) {
 	// Check if we have any clients configured
-	if len(m.Clients) == 0 {
+	registries := m.registries()
+	if len(registries.clients) == 0 {
 		http.Error(w, "No registry clients configured", http.StatusServiceUnavailable)
 		return
 	}
@@ -162,7 +473,7 @@ func (m *ApiManager) HandleApiRoot(w http.ResponseWriter, req *http.Request) {
 		}
 
 		// Create a key based on the description and store the path with placeholders intact
-		key := strings.ToLower(strings.ReplaceAll(route.Description, " ", "_"))
+		key := routeKey(route)
 		cleanPattern := cleanPatternString(route.Pattern)
 		endpointsPattern[key] = cleanPattern
 	}
@@ -173,6 +484,7 @@ func (m *ApiManager) HandleApiRoot(w http.ResponseWriter, req *http.Request) {
 		"description":          "ORASHub API",
 		"endpoints_pattern":    endpointsPattern,
 		"available_registries": m.getAvailableRegistries(),
+		"registry_aliases":     registries.aliases,
 	}
 
 	// Return JSON response
@@ -184,20 +496,84 @@ func (m *ApiManager) HandleApiRoot(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-// getAvailableRegistries returns a list of available registry names
+// routeInfo describes a route for the routes endpoint
+type routeInfo struct {
+	Method      string   `json:"method"`
+	Pattern     string   `json:"pattern"`
+	Path        string   `json:"path"`
+	Description string   `json:"description"`
+	Parameters  []string `json:"parameters"`
+}
+
+// HandleRoutes lists every route in the route table with its method, mux pattern,
+// path template, description and path parameter names, for simple clients that
+// discover the API at runtime
+func (m *ApiManager) HandleRoutes(w http.ResponseWriter, req *http.Request) {
+	routes := make([]routeInfo, 0, len(m.Routes))
+	for _, route := range m.Routes {
+		parameters := patternVariables(route.Pattern)
+		if parameters == nil {
+			parameters = []string{}
+		}
+		path := cleanPatternString(route.Pattern)
+		if path == "" {
+			path = "/"
+		}
+		routes = append(routes, routeInfo{
+			Method:      route.Method,
+			Pattern:     route.Pattern,
+			Path:        path,
+			Description: route.Description,
+			Parameters:  parameters,
+		})
+	}
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"routes": routes}); err != nil {
+		m.Logger.Error("Error encoding routes response: %v", err)
+	}
+}
+
+// setCacheControl sets the Cache-Control header for a response about the given reference.
+// Digest references are immutable and can be cached forever, tags must be revalidated.
+func (m *ApiManager) setCacheControl(w http.ResponseWriter, reference string) {
+	if !m.Settings.CacheControl {
+		return
+	}
+
+	switch {
+	case isDigestReference(reference):
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	case m.Settings.TagMaxAge > 0:
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(m.Settings.TagMaxAge.Seconds())))
+	default:
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+}
+
+// getAvailableRegistries returns a list of available registry names and aliases
 func (m *ApiManager) getAvailableRegistries() []string {
-	registries := make([]string, 0, len(m.Clients))
-	for registry := range m.Clients {
+	set := m.registries()
+	registries := make([]string, 0, len(set.clients)+len(set.aliases))
+	for registry := range set.clients {
 		registries = append(registries, registry)
 	}
+	for alias := range set.aliases {
+		registries = append(registries, alias)
+	}
 	return registries
 }
 
 // checkImagePolicy checks if the requested repository is allowed by policy
 func (m *ApiManager) checkImagePolicy(w http.ResponseWriter, req *http.Request, registry, namespace, repository string) bool {
-	// If no policy is configured, allow all repositories
-	if m.ImagePolicy == nil || (len(m.ImagePolicy.AllowedRepositories) == 0 && len(m.ImagePolicy.BlockedRepositories) == 0) {
-		return true
+	// Registries confined to a namespace prefix never serve anything outside it. This is
+	// reported as not found so the restriction doesn't reveal what exists elsewhere.
+	if !m.withinNamespacePrefix(registry, fmt.Sprintf("%s/%s", namespace, repository)) {
+		m.Logger.Warn("Access denied to repository %s/%s outside the namespace prefix of %s", namespace, repository, registry)
+		http.Error(w, "repository not found", http.StatusNotFound)
+		return false
 	}
 
 	// Registry should never be empty - this is a requirement
@@ -206,40 +582,72 @@ func (m *ApiManager) checkImagePolicy(w http.ResponseWriter, req *http.Request,
 		http.Error(w, "Registry is required for policy check", http.StatusBadRequest)
 		return false
 	}
+	repositoryPath := m.policyRepositoryPath(registry, namespace, repository)
+	m.Logger.Debug("Repository path for policy check: %s", repositoryPath)
+
+	// Tombstoned repositories are gone for everyone, which caches and crawlers can
+	// treat as permanent, unlike a 403
+	if m.ImagePolicy != nil && policy.IsGone(repositoryPath, m.ImagePolicy) {
+		m.Logger.Info("Repository %s is gone by policy", repositoryPath)
+		http.Error(w, "This repository has been removed", http.StatusGone)
+		return false
+	}
 
-	// Create repository path without the tag
-	// Important: Do NOT include the registry in the path again if it's already part of namespace
-	if strings.HasPrefix(namespace, registry+"/") {
-		// The namespace already contains the registry, don't duplicate
-		repositoryPath := fmt.Sprintf("%s/%s", namespace, repository)
-		m.Logger.Debug("Repository path for policy check: %s", repositoryPath)
-
-		// Check if the repository is allowed by policy
-		if !policy.IsAllowed(repositoryPath, m.ImagePolicy) {
-			m.Logger.Warn("Access denied to repository %s by policy", repositoryPath)
-			http.Error(w, "Access to this repository is denied by policy", http.StatusForbidden)
-			return false
-		}
-	} else {
-		// Normal case, combine registry with namespace and repository
-		repositoryPath := fmt.Sprintf("%s/%s/%s", registry, namespace, repository)
-		m.Logger.Debug("Repository path for policy check: %s", repositoryPath)
-
-		// Check if the repository is allowed by policy
-		if !policy.IsAllowed(repositoryPath, m.ImagePolicy) {
-			m.Logger.Warn("Access denied to repository %s by policy", repositoryPath)
-			http.Error(w, "Access to this repository is denied by policy", http.StatusForbidden)
-			return false
-		}
+	// The caller's API token narrows what it may access, on top of the global policy
+	if !m.checkIdentityPolicy(w, req, repositoryPath) {
+		return false
 	}
 
+	// If no policy is configured, allow all repositories
+	if m.ImagePolicy == nil || (len(m.ImagePolicy.AllowedRepositories) == 0 && len(m.ImagePolicy.BlockedRepositories) == 0) {
+		return true
+	}
+
+	// Check if the repository is allowed by policy
+	if !policy.IsAllowed(repositoryPath, m.ImagePolicy) {
+		m.Logger.Warn("Access denied to repository %s by policy", repositoryPath)
+		http.Error(w, "Access to this repository is denied by policy", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// checkDownloadPolicy checks the download-specific repository lists for endpoints
+// that serve artifact content, so metadata can stay public while downloads are
+// restricted. It only adds to checkImagePolicy, which must be called first.
+func (m *ApiManager) checkDownloadPolicy(w http.ResponseWriter, registry, namespace, repository string) bool {
+	if m.ImagePolicy == nil {
+		return true
+	}
+	repositoryPath := m.policyRepositoryPath(registry, namespace, repository)
+	if !policy.IsDownloadAllowed(repositoryPath, m.ImagePolicy) {
+		m.Logger.Warn("Download from repository %s denied by policy", repositoryPath)
+		http.Error(w, "Downloads from this repository are denied by policy", http.StatusForbidden)
+		return false
+	}
 	return true
 }
 
+// policyRepositoryPath returns the registry/namespace/repository path policies are
+// matched against. Policies are written against registry names, not aliases.
+func (m *ApiManager) policyRepositoryPath(registry, namespace, repository string) string {
+	registry = m.resolveRegistry(registry)
+
+	// Important: Do NOT include the registry in the path again if it's already part of namespace
+	if strings.HasPrefix(namespace, registry+"/") {
+		return fmt.Sprintf("%s/%s", namespace, repository)
+	}
+	return fmt.Sprintf("%s/%s/%s", registry, namespace, repository)
+}
+
 // HandleListTags handles the list tags endpoint for both default and registry-specific routes
 func (m *ApiManager) HandleListTags(w http.ResponseWriter, req *http.Request) {
-	// Get all path values using the request pattern directly
-	pathValues := getPathValues(req, req.Pattern)
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
 	registry := pathValues["registry"]
 	namespace := pathValues["namespace"]
 	repository := pathValues["repository"]
@@ -248,17 +656,9 @@ func (m *ApiManager) HandleListTags(w http.ResponseWriter, req *http.Request) {
 	m.Logger.Debug("HandleListTags called with registry=%s, namespace=%s, repository=%s", registry, namespace, repository)
 
 	// Get client
-	client, err := m.getClient(registry)
+	client, err := m.getRequestClient(req, registry)
 	if err != nil {
-		// Handle specific error types
-		switch {
-		case errors.Is(err, ErrRegistryNotFound):
-			http.Error(w, err.Error(), http.StatusNotFound)
-		case errors.Is(err, ErrNoRegistryClients):
-			http.Error(w, err.Error(), http.StatusServiceUnavailable)
-		default:
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		writeClientError(w, err)
 		return
 	}
 
@@ -270,11 +670,40 @@ func (m *ApiManager) HandleListTags(w http.ResponseWriter, req *http.Request) {
 	// Build namespaced repository
 	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
 
-	// Get tags
-	tags, err := client.ListTags(namespacedRepository)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	// ?last= resumes the listing after a known tag, for incremental mirroring
+	last := req.URL.Query().Get("last")
+
+	// Get tags, from the cache when enabled. ?nocache=1 skips the cached listing and
+	// refreshes it. Listings made with per-request credentials or resumed with ?last=
+	// are never cached.
+	cacheKey := fmt.Sprintf("%s/%s", client.GetRegistry(), namespacedRepository)
+	cacheTTL := m.tagCacheTTL(client.GetRegistry())
+	useCache := cacheTTL > 0 && req.Header.Get(CredentialOverrideHeader) == "" && last == ""
+	var tags []string
+	hit := false
+	if useCache && req.URL.Query().Get("nocache") != "1" {
+		tags, hit = m.tagCache.GetWithTTL(cacheKey, cacheTTL)
+	}
+	if !hit {
+		tags, err = client.ListTags(namespacedRepository, last)
+		if err != nil {
+			writeRegistryError(w, err)
+			return
+		}
+		if useCache {
+			m.tagCache.SetWithTTL(cacheKey, tags, cacheTTL)
+		}
+	}
+	// An existing repository without tags is an empty list, never null
+	if tags == nil {
+		tags = []string{}
+	}
+	if useCache {
+		if hit {
+			w.Header().Set("X-Cache", "HIT")
+		} else {
+			w.Header().Set("X-Cache", "MISS")
+		}
 	}
 
 	// Create a template URL for tags with placeholders - using relative URL
@@ -288,6 +717,63 @@ func (m *ApiManager) HandleListTags(w http.ResponseWriter, req *http.Request) {
 		tagEndpoints[tag] = tagURL
 	}
 
+	// Optionally include each tag's plugin metadata, e.g. for a versions table, or
+	// keep only the tags whose metadata matches ?meta.{field}= filters or whose
+	// artifact type is ?artifact_type=. Each fetches every tag's manifest.
+	expanded := req.URL.Query().Get("expand") == "metadata"
+	filters, err := metadataFilters(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	artifactType := req.URL.Query().Get("artifact_type")
+	var metadata map[string]tagMetadata
+	if expanded || len(filters) > 0 || artifactType != "" {
+		cacheMetadata := req.Header.Get(CredentialOverrideHeader) == "" && req.URL.Query().Get("nocache") != "1"
+		metadata, err = m.fetchTagMetadata(req.Context(), client, namespacedRepository, tags, cacheMetadata)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	if len(filters) > 0 || artifactType != "" {
+		matching := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			result := metadata[tag]
+			if (len(filters) == 0 || matchesMetadataFilters(result, filters)) &&
+				(artifactType == "" || (result.Error == "" && result.ArtifactType == artifactType)) {
+				matching = append(matching, tag)
+			} else {
+				delete(tagEndpoints, tag)
+				delete(metadata, tag)
+			}
+		}
+		tags = matching
+	}
+
+	// Render a browsable page for browsers, JSON for everyone else
+	w.Header().Add("Vary", "Accept")
+	if wantsHTML(req) {
+		links := make([]tagLink, 0, len(tags))
+		for _, tag := range tags {
+			link := tagLink{Name: tag, URL: tagEndpoints[tag]}
+			if result, ok := metadata[tag]; ok {
+				link.Version = result.metadataString("version")
+				link.Tested = result.metadataString("tested")
+				link.Error = result.Error
+			}
+			links = append(links, link)
+		}
+		m.renderTemplate(w, "tags.html", tagsPageData{
+			Title:      namespacedRepository,
+			Registry:   client.GetRegistry(),
+			Repository: namespacedRepository,
+			Tags:       links,
+			Expanded:   expanded,
+		})
+		return
+	}
+
 	// Build response
 	response := map[string]interface{}{
 		"repository": namespacedRepository,
@@ -295,6 +781,18 @@ func (m *ApiManager) HandleListTags(w http.ResponseWriter, req *http.Request) {
 		"tags":       tags,
 		"endpoints":  tagEndpoints,
 	}
+	if last != "" {
+		response["last"] = last
+	}
+	if len(filters) > 0 {
+		response["filters"] = filters
+	}
+	if artifactType != "" {
+		response["artifact_type"] = artifactType
+	}
+	if expanded {
+		response["metadata"] = metadata
+	}
 
 	// Return response
 	w.Header().Set("Content-Type", "application/json")
@@ -307,25 +805,20 @@ func (m *ApiManager) HandleListTags(w http.ResponseWriter, req *http.Request) {
 
 // HandleResourceInfo handles the resource info endpoint for both default and registry-specific routes
 func (m *ApiManager) HandleResourceInfo(w http.ResponseWriter, req *http.Request) {
-	// Get all path values using the request pattern directly
-	pathValues := getPathValues(req, req.Pattern)
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
 	registry := pathValues["registry"]
 	namespace := pathValues["namespace"]
 	repository := pathValues["repository"]
 	tag := pathValues["tag"]
 
 	// Get client
-	client, err := m.getClient(registry)
+	client, err := m.getRequestClient(req, registry)
 	if err != nil {
-		// Handle specific error types
-		switch {
-		case errors.Is(err, ErrRegistryNotFound):
-			http.Error(w, err.Error(), http.StatusNotFound)
-		case errors.Is(err, ErrNoRegistryClients):
-			http.Error(w, err.Error(), http.StatusServiceUnavailable)
-		default:
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		writeClientError(w, err)
 		return
 	}
 
@@ -334,6 +827,12 @@ func (m *ApiManager) HandleResourceInfo(w http.ResponseWriter, req *http.Request
 		return
 	}
 
+	// Check annotation policy, which needs the manifest
+	reference, ok := m.checkAnnotationPolicy(w, client, fmt.Sprintf("%s/%s", namespace, repository), tag)
+	if !ok {
+		return
+	}
+
 	// Create endpoints for this resource directly using the pattern structure
 	endpoints := make(map[string]string)
 	cleanRequestPattern := cleanPatternString(req.Pattern)
@@ -346,18 +845,52 @@ func (m *ApiManager) HandleResourceInfo(w http.ResponseWriter, req *http.Request
 
 		if strings.HasPrefix(cleanRoutePattern, cleanRequestPattern) {
 			// Create a key based on the description and store the relative URL
-			key := strings.ToLower(strings.ReplaceAll(route.Description, " ", "_"))
+			key := routeKey(route)
 			// interpolate /api/v1/{registry}/{namespace}/{repository}/{tag} without baseURL
 			endpoints[key] = interpolatePattern(cleanRoutePattern, pathValues)
 		}
 	}
 
+	resource := fmt.Sprintf("%s/%s:%s", namespace, repository, tag)
+
+	// Look up the artifact type, and the subject so tooling can walk from a referrer
+	// back to what it describes. The resource directory is still useful without
+	// them, so failures aren't fatal.
+	var subject *v1.Descriptor
+	var artifactType string
+	if _, content, err := client.FetchManifest(fmt.Sprintf("%s/%s", namespace, repository), reference); err != nil {
+		m.Logger.Warn("Error getting manifest for %s: %v", resource, err)
+	} else {
+		subject = manifestSubject(content)
+		artifactType = manifestArtifactType(content)
+	}
+
+	// Render a browsable page for browsers, JSON for everyone else
+	w.Header().Add("Vary", "Accept")
+	if wantsHTML(req) {
+		m.renderTemplate(w, "resource.html", resourcePageData{
+			Title:         resource,
+			Registry:      client.GetRegistry(),
+			Resource:      resource,
+			RepositoryURL: interpolatePattern("/api/v1/{registry}/{namespace}/{repository}/", pathValues),
+			ArtifactType:  artifactType,
+			Endpoints:     endpoints,
+		})
+		return
+	}
+
 	// Create API directory response
 	response := map[string]interface{}{
 		"registry":  client.GetRegistry(),
-		"resource":  fmt.Sprintf("%s/%s:%s", namespace, repository, tag),
+		"resource":  resource,
 		"endpoints": endpoints,
 	}
+	if artifactType != "" {
+		response["artifact_type"] = artifactType
+	}
+	if subject != nil {
+		response["subject"] = subject
+	}
 
 	// Return JSON response
 	w.Header().Set("Content-Type", "application/json")
@@ -370,8 +903,11 @@ func (m *ApiManager) HandleResourceInfo(w http.ResponseWriter, req *http.Request
 
 // HandleDescriptor handles the descriptor endpoint for both default and registry-specific routes
 func (m *ApiManager) HandleDescriptor(w http.ResponseWriter, req *http.Request) {
-	// Get all path values using the request pattern directly
-	pathValues := getPathValues(req, req.Pattern)
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
 	registry := pathValues["registry"]
 	namespace := pathValues["namespace"]
 	repository := pathValues["repository"]
@@ -382,17 +918,9 @@ func (m *ApiManager) HandleDescriptor(w http.ResponseWriter, req *http.Request)
 		registry, namespace, repository, tag)
 
 	// Get client
-	client, err := m.getClient(registry)
+	client, err := m.getRequestClient(req, registry)
 	if err != nil {
-		// Handle specific error types
-		switch {
-		case errors.Is(err, ErrRegistryNotFound):
-			http.Error(w, err.Error(), http.StatusNotFound)
-		case errors.Is(err, ErrNoRegistryClients):
-			http.Error(w, err.Error(), http.StatusServiceUnavailable)
-		default:
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		writeClientError(w, err)
 		return
 	}
 
@@ -404,20 +932,59 @@ func (m *ApiManager) HandleDescriptor(w http.ResponseWriter, req *http.Request)
 	// Build namespaced repository
 	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
 
-	// Get descriptor
-	desc, err := client.GetDescriptor(namespacedRepository, tag)
+	// Check annotation policy, which needs the manifest
+	reference, ok := m.checkAnnotationPolicy(w, client, namespacedRepository, tag)
+	if !ok {
+		return
+	}
+
+	// Get descriptor along with the manifest, which carries any subject
+	desc, content, err := client.FetchManifest(namespacedRepository, reference)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeRegistryError(w, err)
+		return
+	}
+	if !m.checkManifestLayerLimit(w, namespacedRepository, tag, content) {
 		return
 	}
 
 	// Log the description
 	m.Logger.Info("Description for %s/%s:%s: %v", namespace, repository, tag, desc)
 
+	// ?include_data=false drops inline base64 data, which can bloat the response
+	includeData := req.URL.Query().Get("include_data") != "false"
+	response := descriptorResponse{Descriptor: *desc, Subject: manifestSubject(content)}
+	if response.ArtifactType == "" {
+		// Registries rarely report artifactType when resolving, so take it from the
+		// manifest, falling back to the config media type as resource info does
+		response.ArtifactType = manifestArtifactType(content)
+	}
+	if !includeData {
+		response.Data = nil
+		if response.Subject != nil {
+			subject := *response.Subject
+			subject.Data = nil
+			response.Subject = &subject
+		}
+	}
+
+	// Optionally return the descriptors exactly as the manifest carries them
+	var body interface{} = response
+	if req.URL.Query().Get("raw") == "true" {
+		raw, err := newRawDescriptorResponse(desc, content, includeData)
+		if err != nil {
+			m.Logger.Error("Error parsing manifest for %s/%s:%s: %v", namespace, repository, tag, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		body = raw
+	}
+
 	// Return response
+	m.setCacheControl(w, tag)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(desc); err != nil {
+	if err := json.NewEncoder(w).Encode(body); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -425,25 +992,20 @@ func (m *ApiManager) HandleDescriptor(w http.ResponseWriter, req *http.Request)
 
 // HandleManifest handles the manifest endpoint for both default and registry-specific routes
 func (m *ApiManager) HandleManifest(w http.ResponseWriter, req *http.Request) {
-	// Get all path values using the request pattern directly
-	pathValues := getPathValues(req, req.Pattern)
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
 	registry := pathValues["registry"]
 	namespace := pathValues["namespace"]
 	repository := pathValues["repository"]
 	tag := pathValues["tag"]
 
 	// Get client
-	client, err := m.getClient(registry)
+	client, err := m.getRequestClient(req, registry)
 	if err != nil {
-		// Handle specific error types
-		switch {
-		case errors.Is(err, ErrRegistryNotFound):
-			http.Error(w, err.Error(), http.StatusNotFound)
-		case errors.Is(err, ErrNoRegistryClients):
-			http.Error(w, err.Error(), http.StatusServiceUnavailable)
-		default:
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		writeClientError(w, err)
 		return
 	}
 
@@ -455,15 +1017,81 @@ func (m *ApiManager) HandleManifest(w http.ResponseWriter, req *http.Request) {
 	// Build namespaced repository
 	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
 
+	// The response format depends on the Accept header. Referrers are only listed in
+	// the normalized form, so asking for them in another is an error.
+	fields := req.URL.Query().Get("fields")
+	format := preferredMediaType(req.Header.Get("Accept"), []string{v1.MediaTypeImageManifest, "application/json"})
+	if req.URL.Query().Get("include_referrers") == "true" && (format != "application/json" || fields != "") {
+		http.Error(w, "include_referrers requires the normalized manifest: send Accept: application/json without fields", http.StatusBadRequest)
+		return
+	}
+
+	// Check annotation policy, which needs the manifest
+	reference, ok := m.checkAnnotationPolicy(w, client, namespacedRepository, tag)
+	if !ok {
+		return
+	}
+
+	// The raw manifest needs no parsing, so unless the config policy or the layer
+	// limit has to inspect it, it is streamed to the response rather than buffered
+	if fields == "" && format != "application/json" && (m.ImagePolicy == nil || !m.ImagePolicy.RequireNonemptyConfig) && !m.layerLimitEnabled() {
+		m.streamManifest(w, client, namespacedRepository, tag, reference, format)
+		return
+	}
+
 	// Get manifest
-	content, err := client.GetManifest(namespacedRepository, tag)
+	desc, content, err := client.FetchManifest(namespacedRepository, reference)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeRegistryError(w, err)
+		return
+	}
+	if !m.checkConfigPolicy(w, content) {
+		return
+	}
+	if !m.checkManifestLayerLimit(w, namespacedRepository, tag, content) {
 		return
 	}
 
-	// Return response
-	w.Header().Set("Content-Type", "application/json")
+	// Return just the requested fields of the parsed manifest, as JSON
+	if fields != "" {
+		m.writeManifestFields(w, tag, content, strings.Split(fields, ","))
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	// Return the normalized manifest when our own JSON schema is requested
+	if format == "application/json" {
+		parsed, err := parseManifestResponse(content)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Optionally merge in annotations from referrers such as signatures and SBOMs
+		if req.URL.Query().Get("include_referrers") == "true" {
+			referrers, err := client.ListReferrers(namespacedRepository, reference)
+			if err != nil {
+				m.Logger.Error("Error listing referrers for %s:%s: %v", namespacedRepository, tag, err)
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			parsed.Referrers = newReferrerSummaries(referrers)
+		}
+
+		m.setCacheControl(w, tag)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(parsed); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		return
+	}
+
+	// Otherwise return the raw manifest bytes
+	m.setCacheControl(w, tag)
+	w.Header().Set("Content-Type", rawManifestContentType(format, desc))
 	w.WriteHeader(http.StatusOK)
 	if _, err := w.Write(content); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -471,27 +1099,220 @@ func (m *ApiManager) HandleManifest(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// rawManifestContentType is the content type of raw manifest bytes: generic JSON
+// unless the OCI manifest type was explicitly requested, and then the media type
+// the registry reports, so an index isn't labelled as an image manifest
+func rawManifestContentType(format string, desc *v1.Descriptor) string {
+	if format != v1.MediaTypeImageManifest {
+		return "application/json"
+	}
+	if desc.MediaType != "" {
+		return desc.MediaType
+	}
+	return v1.MediaTypeImageManifest
+}
+
+// streamManifest copies the raw manifest reference points at to the response as it
+// arrives from the registry, with Content-Length from its descriptor and the content
+// type from rawManifestContentType. Caching follows the requested tag.
+func (m *ApiManager) streamManifest(w http.ResponseWriter, apiClient client.ClientInterface, repository, tag, reference, format string) {
+	desc, reader, err := apiClient.GetManifestReader(repository, reference)
+	if err != nil {
+		writeRegistryError(w, err)
+		return
+	}
+	defer reader.Close()
+
+	m.setCacheControl(w, tag)
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", rawManifestContentType(format, desc))
+	setContentLength(w, desc.Size)
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, reader); err != nil {
+		// The reader holds back the last byte of a manifest that fails to verify, so
+		// breaking the connection leaves the client with a short, failed response
+		m.Logger.Error("Aborting manifest response for %s:%s: %v", repository, tag, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// writeManifestFields writes the listed top-level manifest fields as a JSON object.
+// Unknown field names are rejected with 400 so typos don't silently return less.
+func (m *ApiManager) writeManifestFields(w http.ResponseWriter, tag string, content []byte, fields []string) {
+	manifest, err := client.ParseManifest(content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	names := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			names = append(names, field)
+		}
+	}
+	selected, err := manifest.SelectFields(names)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.setCacheControl(w, tag)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(selected); err != nil {
+		m.Logger.Error("Error encoding manifest fields: %v", err)
+	}
+}
+
+// parsedManifestResponse is the normalized JSON representation of a manifest
+type parsedManifestResponse struct {
+	SchemaVersion int                    `json:"schemaVersion"`
+	MediaType     string                 `json:"mediaType,omitempty"`
+	ArtifactType  string                 `json:"artifactType,omitempty"`
+	Config        v1.Descriptor          `json:"config"`
+	Layers        []v1.Descriptor        `json:"layers"`
+	Subject       *v1.Descriptor         `json:"subject,omitempty"`
+	Annotations   map[string]interface{} `json:"annotations"`
+	Referrers     []referrerSummary      `json:"referrers,omitempty"`
+}
+
+// descriptorResponse is a manifest descriptor plus the subject the manifest refers to, if any
+type descriptorResponse struct {
+	v1.Descriptor
+	Subject *v1.Descriptor `json:"subject,omitempty"`
+}
+
+// manifestArtifactType returns the artifact type of a manifest, or "" when it can't be parsed
+func manifestArtifactType(content []byte) string {
+	manifest, err := client.ParseManifest(content)
+	if err != nil {
+		return ""
+	}
+	return manifest.GetArtifactType()
+}
+
+// manifestSubject returns the subject of a manifest, or nil when it has none or can't be parsed
+func manifestSubject(content []byte) *v1.Descriptor {
+	manifest, err := client.ParseManifest(content)
+	if err != nil {
+		return nil
+	}
+	return manifest.Subject
+}
+
+// referrerSummary describes a manifest that refers to another, with its decoded annotations
+type referrerSummary struct {
+	MediaType    string                 `json:"mediaType"`
+	ArtifactType string                 `json:"artifactType,omitempty"`
+	Digest       string                 `json:"digest"`
+	Size         int64                  `json:"size"`
+	Annotations  map[string]interface{} `json:"annotations"`
+}
+
+// newReferrerSummaries converts referrer descriptors into their response form
+func newReferrerSummaries(referrers []v1.Descriptor) []referrerSummary {
+	summaries := make([]referrerSummary, 0, len(referrers))
+	for _, referrer := range referrers {
+		summaries = append(summaries, referrerSummary{
+			MediaType:    referrer.MediaType,
+			ArtifactType: referrer.ArtifactType,
+			Digest:       referrer.Digest.String(),
+			Size:         referrer.Size,
+			Annotations:  client.DecodeAnnotations(referrer.Annotations),
+		})
+	}
+	return summaries
+}
+
+// parseManifestResponse parses raw manifest bytes into the normalized response
+func parseManifestResponse(content []byte) (*parsedManifestResponse, error) {
+	manifest, err := client.ParseManifest(content)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := manifest.Layers
+	if layers == nil {
+		layers = []v1.Descriptor{}
+	}
+	return &parsedManifestResponse{
+		SchemaVersion: manifest.SchemaVersion,
+		MediaType:     manifest.MediaType,
+		ArtifactType:  manifest.ArtifactType,
+		Config:        manifest.Config,
+		Layers:        layers,
+		Subject:       manifest.Subject,
+		Annotations:   manifest.DecodedAnnotations(),
+	}, nil
+}
+
+// HandleConfig handles the config blob endpoint, returning the blob with its declared media type
+func (m *ApiManager) HandleConfig(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+	namespace := pathValues["namespace"]
+	repository := pathValues["repository"]
+	tag := pathValues["tag"]
+
+	// Get client
+	client, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	// Check policy
+	if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+		return
+	}
+
+	// Build namespaced repository
+	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+
+	// Check annotation policy, which needs the manifest
+	reference, ok := m.checkAnnotationPolicy(w, client, namespacedRepository, tag)
+	if !ok {
+		return
+	}
+
+	// Get config blob
+	content, desc, err := client.GetConfigBlob(namespacedRepository, reference)
+	if err != nil {
+		m.Logger.Error("Error getting config blob for %s/%s:%s: %v", namespace, repository, tag, err)
+		writeRegistryError(w, err)
+		return
+	}
+
+	// Return response
+	m.setCacheControl(w, tag)
+	w.Header().Set("Content-Type", desc.MediaType)
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(content)))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(content); err != nil {
+		m.Logger.Error("Error writing config blob: %v", err)
+	}
+}
+
 // HandleDownload handles the download endpoint for both default and registry-specific routes
 func (m *ApiManager) HandleDownload(w http.ResponseWriter, req *http.Request) {
-	// Get all path values using the request pattern directly
-	pathValues := getPathValues(req, req.Pattern)
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
 	registry := pathValues["registry"]
 	namespace := pathValues["namespace"]
 	repository := pathValues["repository"]
 	tag := pathValues["tag"]
 
 	// Get client
-	client, err := m.getClient(registry)
+	client, err := m.getRequestClient(req, registry)
 	if err != nil {
-		// Handle specific error types
-		switch {
-		case errors.Is(err, ErrRegistryNotFound):
-			http.Error(w, err.Error(), http.StatusNotFound)
-		case errors.Is(err, ErrNoRegistryClients):
-			http.Error(w, err.Error(), http.StatusServiceUnavailable)
-		default:
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		writeClientError(w, err)
 		return
 	}
 
@@ -499,15 +1320,80 @@ func (m *ApiManager) HandleDownload(w http.ResponseWriter, req *http.Request) {
 	if !m.checkImagePolicy(w, req, registry, namespace, repository) {
 		return
 	}
+	if !m.checkDownloadPolicy(w, registry, namespace, repository) {
+		return
+	}
 
 	// Build namespaced repository
 	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
 
+	// Check annotation policy, which needs the manifest
+	reference, ok := m.checkAnnotationPolicy(w, client, namespacedRepository, tag)
+	if !ok {
+		return
+	}
+
+	// When the caller expects a specific digest, check the tag still points at it and
+	// download by that digest, so the tag can't move between the check and the fetch
+	if expected := expectedDigests(req); len(expected) > 0 {
+		desc, err := client.ResolveDescriptor(namespacedRepository, reference)
+		if err != nil {
+			m.Logger.Error("Error resolving %s/%s:%s: %v", namespace, repository, tag, err)
+			writeRegistryError(w, err)
+			return
+		}
+		if !digestMatches(expected, desc.Digest.String()) {
+			http.Error(w, fmt.Sprintf("%s currently resolves to %s", tag, desc.Digest), http.StatusPreconditionFailed)
+			return
+		}
+		reference = desc.Digest.String()
+	}
+
+	// Artifacts without a real config may be rejected by policy, the download can be
+	// named from the plugin metadata, and a mirrored layer is picked from it to be
+	// redirected to. All need the manifest, after which the layer is fetched by the
+	// checked digest so the tag can't move in between.
+	autoFilename := m.Settings.DownloadFilenameFromMetadata || req.URL.Query().Get("filename") == "auto"
+	redirecting := m.redirectsDownloads(registry) && req.URL.Query().Get("decompress") != "true"
+	var metadata map[string]interface{}
+	var manifest []byte
+	if autoFilename || redirecting || (m.ImagePolicy != nil && m.ImagePolicy.RequireNonemptyConfig) {
+		desc, content, err := client.FetchManifest(namespacedRepository, reference)
+		if err != nil {
+			m.Logger.Error("Error getting manifest for %s/%s:%s: %v", namespace, repository, tag, err)
+			writeRegistryError(w, err)
+			return
+		}
+		if !m.checkConfigPolicy(w, content) {
+			return
+		}
+		metadata = m.manifestPluginMetadata(content)
+		manifest = content
+		reference = desc.Digest.String()
+	}
+
 	// Get layer info
-	layerInfo, err := client.GetFirstLayerReader(namespacedRepository, tag)
+	selector, err := downloadLayerSelector(req)
 	if err != nil {
-		m.Logger.Error("Error getting first layer reader for %s/%s:%s: %v", namespace, repository, tag, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	downloadFilename := func(filename string) string {
+		if autoFilename {
+			// Prefer {slug}.{version} from the plugin metadata over the layer title
+			if named := metadataFilename(metadata, filename); named != "" {
+				return named
+			}
+		}
+		return filename
+	}
+	if redirecting && m.redirectDownload(w, req, registry, namespacedRepository, manifest, selector, downloadFilename) {
+		return
+	}
+	layerInfo, err := client.GetLayerReader(namespacedRepository, reference, selector)
+	if err != nil {
+		m.Logger.Error("Error getting layer reader (%s) for %s/%s:%s: %v", selector, namespace, repository, tag, err)
+		writeRegistryError(w, err)
 		return
 	}
 	if layerInfo == nil {
@@ -516,21 +1402,66 @@ func (m *ApiManager) HandleDownload(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Hash the layer as it streams so corruption is caught at EOF
+	verifier := newVerifyingReader(layerInfo, layerInfo.GetDigest())
+	var content io.ReadCloser = struct {
+		io.Reader
+		io.Closer
+	}{verifier, layerInfo}
+	filename := downloadFilename(layerInfo.GetFilename())
+	mediaType := layerInfo.GetMediaType()
+	size := layerInfo.GetSize()
+	decompressing := false
+
+	// Optionally decompress gzip/zstd layers on the fly. The decompressed size isn't
+	// known up front, so the response is sent with chunked transfer encoding.
+	if req.URL.Query().Get("decompress") == "true" {
+		if kind := layerCompression(mediaType); kind != compressionNone {
+			decompressed, err := newDecompressedLayer(content, kind)
+			if err != nil {
+				layerInfo.Close()
+				m.Logger.Error("Error decompressing layer for %s/%s:%s: %v", namespace, repository, tag, err)
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			content = decompressed
+			decompressing = true
+			filename, mediaType = decompressedName(filename, mediaType, kind)
+			size = -1
+		}
+	}
+
 	// Set headers
-	w.Header().Set("Content-Type", layerInfo.GetMediaType())
-	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, layerInfo.GetFilename()))
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", layerInfo.GetSize()))
+	m.setCacheControl(w, tag)
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", filename))
+
+	// Clients that accept trailers get the streamed digest after the body. Trailers
+	// require chunked encoding, so Content-Length is left out in that case. The digest
+	// covers the layer as stored, so it isn't sent for decompressed content.
+	sendTrailer := acceptsTrailers(req) && !decompressing
+	if sendTrailer {
+		w.Header().Set("Trailer", ContentDigestTrailer)
+	} else {
+		setContentLength(w, size)
+	}
 
 	// Return content
 	w.WriteHeader(http.StatusOK)
-	if _, err := io.Copy(w, layerInfo); err != nil {
-		m.Logger.Error("Error copying content to response: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if _, err := m.copyDownload(w, content); err != nil {
+		content.Close()
+		// The body is already on the wire, so break the connection rather than let
+		// the client mistake corrupted or truncated content for a complete download.
+		// Returning would end a chunked response cleanly.
+		m.Logger.Error("Aborting download of %s/%s:%s: %v", namespace, repository, tag, err)
+		panic(http.ErrAbortHandler)
+	}
+	if sendTrailer {
+		w.Header().Set(ContentDigestTrailer, verifier.Digest().String())
 	}
 
 	// Close the content reader
-	if err := layerInfo.Close(); err != nil {
+	if err := content.Close(); err != nil {
 		m.Logger.Error("Error closing content reader: %v", err)
 	}
 }
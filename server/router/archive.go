@@ -0,0 +1,192 @@
+package router
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/codekaizen-github/orashub/client"
+)
+
+// errFileNotInArchive is returned when the requested path isn't present in the zip layer
+var errFileNotInArchive = errors.New("file not found in archive")
+
+// seekerReaderAt adapts a seekable stream to io.ReaderAt. Reads are serialized,
+// and against a registry every read at a new offset is a ranged blob request.
+type seekerReaderAt struct {
+	mu     sync.Mutex
+	seeker io.ReadSeeker
+}
+
+// ReadAt implements io.ReaderAt
+func (s *seekerReaderAt) ReadAt(p []byte, offset int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.seeker.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(s.seeker, p)
+}
+
+// openZipLayer opens a layer as a zip archive. When the registry supports range
+// requests only the central directory and the requested entries are fetched;
+// otherwise the whole layer is spooled to a temporary file first. The returned
+// cleanup function removes any temporary file.
+func openZipLayer(layer client.LayerInfoInterface) (*zip.Reader, func(), error) {
+	if seeker, ok := layer.(io.ReadSeeker); ok {
+		if _, err := seeker.Seek(0, io.SeekStart); err == nil {
+			reader, err := zip.NewReader(&seekerReaderAt{seeker: seeker}, layer.GetSize())
+			if err != nil {
+				return nil, func() {}, err
+			}
+			return reader, func() {}, nil
+		}
+	}
+
+	// Fall back to buffering the layer, since the zip central directory is at the end
+	spool, err := os.CreateTemp("", "orashub-layer-*.zip")
+	if err != nil {
+		return nil, func() {}, err
+	}
+	cleanup := func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}
+	size, err := io.Copy(spool, layer)
+	if err != nil {
+		cleanup()
+		return nil, func() {}, err
+	}
+	reader, err := zip.NewReader(spool, size)
+	if err != nil {
+		cleanup()
+		return nil, func() {}, err
+	}
+	return reader, cleanup, nil
+}
+
+// findArchiveFile looks up a file in a zip archive by path. WordPress plugin zips
+// usually wrap everything in a single top-level directory, so when there's no exact
+// match the path is also tried beneath that directory.
+func findArchiveFile(archive *zip.Reader, name string) (*zip.File, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+
+	root := ""
+	for i, file := range archive.File {
+		if file.Name == name && !file.FileInfo().IsDir() {
+			return file, nil
+		}
+
+		// Track whether every entry shares the same top-level directory
+		top, _, _ := strings.Cut(file.Name, "/")
+		if i == 0 {
+			root = top
+		} else if top != root {
+			root = ""
+		}
+	}
+
+	if root != "" {
+		for _, file := range archive.File {
+			if file.Name == root+"/"+name && !file.FileInfo().IsDir() {
+				return file, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", errFileNotInArchive, name)
+}
+
+// HandleArchiveFile streams a single file from inside the zip layer of an artifact
+func (m *ApiManager) HandleArchiveFile(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+	namespace := pathValues["namespace"]
+	repository := pathValues["repository"]
+	tag := pathValues["tag"]
+	filePath := pathValues["path"]
+
+	// Get client
+	apiClient, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	// Check policy
+	if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+		return
+	}
+	if !m.checkDownloadPolicy(w, registry, namespace, repository) {
+		return
+	}
+
+	// Build namespaced repository
+	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+
+	// Check annotation policy, which needs the manifest
+	reference, ok := m.checkAnnotationPolicy(w, apiClient, namespacedRepository, tag)
+	if !ok {
+		return
+	}
+
+	// Get layer info
+	layerInfo, err := apiClient.GetFirstLayerReader(namespacedRepository, reference)
+	if err != nil {
+		m.Logger.Error("Error getting first layer reader for %s/%s:%s: %v", namespace, repository, tag, err)
+		writeRegistryError(w, err)
+		return
+	}
+	defer layerInfo.Close()
+
+	// Open the layer as a zip archive
+	archive, cleanup, err := openZipLayer(layerInfo)
+	defer cleanup()
+	if err != nil {
+		m.Logger.Error("Error opening zip layer for %s/%s:%s: %v", namespace, repository, tag, err)
+		http.Error(w, fmt.Sprintf("layer is not a readable zip archive: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Find and open the requested file
+	file, err := findArchiveFile(archive, filePath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	content, err := file.Open()
+	if err != nil {
+		m.Logger.Error("Error opening %s in zip layer for %s/%s:%s: %v", file.Name, namespace, repository, tag, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	// Archive contents are untrusted, so never let them run as active content on this origin
+	contentType := mime.TypeByExtension(path.Ext(file.Name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	m.setCacheControl(w, tag)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", file.UncompressedSize64))
+	w.Header().Set("Content-Disposition", contentDisposition("inline", path.Base(file.Name)))
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// Return content
+	w.WriteHeader(http.StatusOK)
+	if _, err := m.copyDownload(w, content); err != nil {
+		m.Logger.Error("Error copying archive file to response: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/codekaizen-github/orashub/client"
+)
+
+// artifactTypeCount is the number of scanned tags of one artifact type
+type artifactTypeCount struct {
+	ArtifactType string `json:"artifact_type"`
+	Count        int    `json:"count"`
+}
+
+// artifactTypesResponse summarizes the artifact types found in a repository
+type artifactTypesResponse struct {
+	Repository    string              `json:"repository"`
+	Registry      string              `json:"registry"`
+	ArtifactTypes []artifactTypeCount `json:"artifact_types"`
+	TagsScanned   int                 `json:"tags_scanned"`
+	TotalTags     int                 `json:"total_tags"`
+	Truncated     bool                `json:"truncated"`
+}
+
+// HandleArtifactTypes lists the distinct artifact types across the tags of a
+// repository (e.g. plugins vs. themes) with the number of tags of each type.
+//
+// This fetches one manifest per tag, so at most ArtifactTypesMaxTags tags are
+// scanned and the result is cached for ArtifactTypesCacheTTL.
+func (m *ApiManager) HandleArtifactTypes(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+	namespace := pathValues["namespace"]
+	repository := pathValues["repository"]
+
+	// Get client
+	apiClient, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	// Check policy
+	if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+		return
+	}
+
+	// Build namespaced repository
+	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+
+	// Scans made with per-request credentials may see other tags, so they are
+	// neither served from the cache nor stored in it
+	cacheKey := fmt.Sprintf("%s/%s", m.resolveRegistry(registry), namespacedRepository)
+	cacheTTL := m.manifestCacheTTL(registry, m.artifactTypesCache.ttl)
+	useCache := req.Header.Get(CredentialOverrideHeader) == ""
+	var response artifactTypesResponse
+	hit := false
+	if useCache {
+		response, hit = m.artifactTypesCache.GetWithTTL(cacheKey, cacheTTL)
+	}
+	if !hit {
+		response, err = m.scanArtifactTypes(req, apiClient, namespacedRepository)
+		if err != nil {
+			m.Logger.Error("Error scanning artifact types for %s: %v", namespacedRepository, err)
+			writeRegistryError(w, err)
+			return
+		}
+		if useCache {
+			m.artifactTypesCache.SetWithTTL(cacheKey, response, cacheTTL)
+		}
+	}
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		m.Logger.Error("Error encoding artifact types response: %v", err)
+	}
+}
+
+// scanArtifactTypes fetches the manifest of up to ArtifactTypesMaxTags tags
+// concurrently and counts their artifact types
+func (m *ApiManager) scanArtifactTypes(req *http.Request, apiClient client.ClientInterface, repository string) (artifactTypesResponse, error) {
+	response := artifactTypesResponse{
+		Repository:    repository,
+		Registry:      apiClient.GetRegistry(),
+		ArtifactTypes: make([]artifactTypeCount, 0),
+	}
+
+	tags, err := apiClient.ListTags(repository, "")
+	if err != nil {
+		return response, err
+	}
+	response.TotalTags = len(tags)
+	if limit := m.Settings.ArtifactTypesMaxTags; limit > 0 && len(tags) > limit {
+		tags = tags[:limit]
+		response.Truncated = true
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		counts = make(map[string]int)
+	)
+	for _, tag := range tags {
+		if err := m.fetchLimiter.acquire(req.Context()); err != nil {
+			wg.Wait()
+			return response, err
+		}
+
+		wg.Add(1)
+		go func(tag string) {
+			defer wg.Done()
+			defer m.fetchLimiter.release()
+
+			// A single unreadable tag shouldn't fail the whole scan
+			_, content, err := apiClient.FetchManifest(repository, tag)
+			if err != nil {
+				m.Logger.Warn("Error fetching manifest for tag %s in %s: %v", tag, repository, err)
+				return
+			}
+			// Tags the annotation policy hides aren't counted
+			if m.manifestAnnotationsAllowed(content) != nil {
+				return
+			}
+			manifest, err := client.ParseManifest(content)
+			if err != nil {
+				m.Logger.Warn("Error parsing manifest for tag %s in %s: %v", tag, repository, err)
+				return
+			}
+
+			mu.Lock()
+			counts[manifest.GetArtifactType()]++
+			response.TagsScanned++
+			mu.Unlock()
+		}(tag)
+	}
+	wg.Wait()
+
+	// Most common types first
+	for artifactType, count := range counts {
+		response.ArtifactTypes = append(response.ArtifactTypes, artifactTypeCount{ArtifactType: artifactType, Count: count})
+	}
+	sort.Slice(response.ArtifactTypes, func(i, j int) bool {
+		a, b := response.ArtifactTypes[i], response.ArtifactTypes[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.ArtifactType < b.ArtifactType
+	})
+	return response, nil
+}
@@ -0,0 +1,40 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestArtifactTypesCacheSkipsCredentialOverride(t *testing.T) {
+	fake := newFakeClient()
+	fake.addManifest("team/plugin", v1.Manifest{ArtifactType: "application/vnd.example.plugin"}, "1.0.0")
+	manager := newTestManager(t, &policy.ConfigFile{}, DefaultApiSettings(), fake)
+
+	// Each request lists tags unless its scan came from the cache
+	tests := []struct {
+		name      string
+		override  bool
+		wantScans int
+	}{
+		{name: "override before anything is cached", override: true, wantScans: 1},
+		{name: "anonymous scan isn't served the override's", wantScans: 2},
+		{name: "override isn't served the anonymous scan", override: true, wantScans: 3},
+		{name: "anonymous scan is cached", wantScans: 3},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/"+testRegistry+"/team/plugin/artifact-types/", nil)
+		if tt.override {
+			req.Header.Set(CredentialOverrideHeader, "Basic dXNlcjpwYXNz")
+		}
+		if code := serve(manager, req).Code; code != http.StatusOK {
+			t.Fatalf("%s: got status %d", tt.name, code)
+		}
+		if got := fake.callCount("ListTags"); got != tt.wantScans {
+			t.Errorf("%s: got %d scans, want %d", tt.name, got, tt.wantScans)
+		}
+	}
+}
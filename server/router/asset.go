@@ -0,0 +1,95 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/codekaizen-github/orashub/client"
+)
+
+// assetContentType picks the Content-Type for an asset layer, preferring an image
+// media type on the layer and otherwise deriving one from the asset name
+func assetContentType(name, mediaType string) string {
+	if strings.HasPrefix(mediaType, "image/") {
+		return mediaType
+	}
+	if byExtension := mime.TypeByExtension(path.Ext(name)); byExtension != "" {
+		return byExtension
+	}
+	if mediaType != "" {
+		return mediaType
+	}
+	return "application/octet-stream"
+}
+
+// HandleAsset streams the layer whose title annotation matches the requested asset
+// name, such as a plugin icon or banner image
+func (m *ApiManager) HandleAsset(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+	namespace := pathValues["namespace"]
+	repository := pathValues["repository"]
+	tag := pathValues["tag"]
+	name := pathValues["name"]
+
+	// Get client
+	apiClient, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	// Check policy
+	if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+		return
+	}
+	if !m.checkDownloadPolicy(w, registry, namespace, repository) {
+		return
+	}
+
+	// Build namespaced repository
+	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+
+	// Check annotation policy, which needs the manifest
+	reference, ok := m.checkAnnotationPolicy(w, apiClient, namespacedRepository, tag)
+	if !ok {
+		return
+	}
+
+	// Find the asset layer by title
+	layerInfo, err := apiClient.GetLayerReaderByTitle(namespacedRepository, reference, name)
+	if err != nil {
+		if errors.Is(err, client.ErrLayerNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		m.Logger.Error("Error getting asset %s for %s/%s:%s: %v", name, namespace, repository, tag, err)
+		writeRegistryError(w, err)
+		return
+	}
+	defer layerInfo.Close()
+
+	// Set headers. Asset layers are untrusted, so never let them run as active content.
+	m.setCacheControl(w, tag)
+	w.Header().Set("Content-Type", assetContentType(name, layerInfo.GetMediaType()))
+	setContentLength(w, layerInfo.GetSize())
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// Return content, hashing it as it streams so corruption is caught at EOF
+	w.WriteHeader(http.StatusOK)
+	if _, err := m.copyDownload(w, newVerifyingReader(layerInfo, layerInfo.GetDigest())); err != nil {
+		// The body is already on the wire, so break the connection rather than let
+		// the client mistake corrupted or truncated content for the asset
+		m.Logger.Error("Aborting asset %s for %s/%s:%s: %v", name, namespace, repository, tag, err)
+		panic(http.ErrAbortHandler)
+	}
+}
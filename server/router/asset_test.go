@@ -0,0 +1,55 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestHandleAssetAbortsBrokenStreams(t *testing.T) {
+	const repository = "team/app"
+	fake := newFakeClient()
+	titled := func(desc v1.Descriptor, title string) v1.Descriptor {
+		desc.Annotations = map[string]string{v1.AnnotationTitle: title}
+		return desc
+	}
+	icon := fake.addBlob("image/png", []byte("icon"))
+	banner := fake.addBlob("image/png", []byte("banner"))
+	fake.blobErrors[banner.Digest] = errors.New("connection reset by peer")
+	// Listed under a digest its content doesn't have
+	corrupted := v1.Descriptor{MediaType: "image/png", Digest: digest.FromString("expected"), Size: 9}
+	fake.blobs[corrupted.Digest] = []byte("corrupted")
+	fake.addManifest(repository, v1.Manifest{Layers: []v1.Descriptor{
+		titled(icon, "icon.png"),
+		titled(banner, "banner.png"),
+		titled(corrupted, "corrupted.png"),
+	}}, "1.0.0")
+	manager := newTestManager(t, &policy.ConfigFile{}, DefaultApiSettings(), fake)
+
+	tests := []struct {
+		name        string
+		asset       string
+		wantAborted bool
+	}{
+		{name: "intact", asset: "icon.png"},
+		{name: "read error", asset: "banner.png", wantAborted: true},
+		{name: "digest mismatch", asset: "corrupted.png", wantAborted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/"+testRegistry+"/"+repository+"/1.0.0/asset/"+tt.asset, nil)
+			resp, aborted := serveStream(manager, req)
+			if aborted != tt.wantAborted {
+				t.Fatalf("aborted = %t, want %t", aborted, tt.wantAborted)
+			}
+			if !aborted && (resp.Code != http.StatusOK || resp.Body.String() != "icon") {
+				t.Errorf("got status %d with %q, want %d with %q", resp.Code, resp.Body, http.StatusOK, "icon")
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// blobExposedHeaders are the blob response headers cross-origin scripts need to
+// track progress and verify the content
+var blobExposedHeaders = []string{"Content-Length", "Docker-Content-Digest"}
+
+// HandleBlob streams the blob with the given digest, be it a layer, a config or a
+// referrer's blob. The content is verified against the digest as it streams.
+func (m *ApiManager) HandleBlob(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+	namespace := pathValues["namespace"]
+	repository := pathValues["repository"]
+
+	// Only well-formed digests name a blob
+	dgst, err := digest.Parse(pathValues["digest"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid digest %q: %v", pathValues["digest"], err), http.StatusBadRequest)
+		return
+	}
+
+	// Get client
+	apiClient, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	// Check policy
+	if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+		return
+	}
+	if !m.checkDownloadPolicy(w, registry, namespace, repository) {
+		return
+	}
+
+	// Blobs carry no annotations, so they can't be checked against required_annotations
+	if m.annotationPolicyEnabled() {
+		http.Error(w, "blobs are not served while required_annotations is configured", http.StatusForbidden)
+		return
+	}
+
+	// Build namespaced repository
+	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+
+	blob, err := apiClient.FetchBlob(namespacedRepository, dgst)
+	if err != nil {
+		m.Logger.Error("Error fetching blob %s from %s/%s: %v", dgst, namespace, repository, err)
+		writeRegistryError(w, err)
+		return
+	}
+	defer blob.Close()
+
+	// Set headers
+	m.setCacheControl(w, dgst.String())
+	w.Header().Set("Content-Type", "application/octet-stream")
+	setContentLength(w, blob.GetSize())
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+
+	// Hash the blob as it streams so corruption is caught at EOF
+	w.WriteHeader(http.StatusOK)
+	if _, err := m.copyDownload(w, newVerifyingReader(blob, dgst)); err != nil {
+		// The body is already on the wire, so break the connection rather than let
+		// the client mistake corrupted or truncated content for the blob
+		m.Logger.Error("Aborting blob %s from %s/%s: %v", dgst, namespace, repository, err)
+		panic(http.ErrAbortHandler)
+	}
+}
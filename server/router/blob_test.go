@@ -0,0 +1,45 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestHandleBlobAbortsBrokenStreams(t *testing.T) {
+	const repository = "team/app"
+	fake := newFakeClient()
+	intact := fake.addBlob("application/zip", []byte("intact"))
+	truncated := fake.addBlob("application/zip", []byte("truncated"))
+	fake.blobErrors[truncated.Digest] = errors.New("connection reset by peer")
+	// Stored under a digest its content doesn't have
+	corrupted := digest.FromString("expected")
+	fake.blobs[corrupted] = []byte("corrupted")
+	manager := newTestManager(t, &policy.ConfigFile{}, DefaultApiSettings(), fake)
+
+	tests := []struct {
+		name        string
+		digest      digest.Digest
+		wantAborted bool
+	}{
+		{name: "intact", digest: intact.Digest},
+		{name: "read error", digest: truncated.Digest, wantAborted: true},
+		{name: "digest mismatch", digest: corrupted, wantAborted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/"+testRegistry+"/"+repository+"/"+tt.digest.String()+"/blob/", nil)
+			resp, aborted := serveStream(manager, req)
+			if aborted != tt.wantAborted {
+				t.Fatalf("aborted = %t, want %t", aborted, tt.wantAborted)
+			}
+			if !aborted && resp.Code != http.StatusOK {
+				t.Errorf("got status %d, want %d", resp.Code, http.StatusOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// bodylessRequestLimit is the most a request to a method without body semantics
+// (GET, HEAD, OPTIONS, DELETE) may send. Such bodies are never read, only drained.
+const bodylessRequestLimit = 4 << 10
+
+// allowsBody reports whether requests with this method are expected to carry a body
+func allowsBody(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodDelete:
+		return false
+	default:
+		return true
+	}
+}
+
+// LimitRequestBody wraps a handler so request bodies can't be used to tie up
+// connections. Bodies larger than maxBytes (or bodylessRequestLimit for methods
+// like GET) are rejected with 413 when their length is declared, and any body is
+// wrapped in http.MaxBytesReader so reading past the limit fails and the
+// connection is closed instead of drained.
+func LimitRequestBody(maxBytes int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		limit := maxBytes
+		if !allowsBody(req.Method) {
+			limit = bodylessRequestLimit
+		}
+
+		if req.ContentLength > limit {
+			http.Error(w, fmt.Sprintf("request body too large (limit %d bytes)", limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			req.Body = http.MaxBytesReader(w, req.Body, limit)
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,101 @@
+package router
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/codekaizen-github/orashub/server/cache"
+)
+
+// ttlCache is a typed view over a shared cache backend that stores values as JSON
+// under a namespace prefix, with a fixed TTL for every entry
+type ttlCache[V any] struct {
+	backend   cache.Cache
+	namespace string
+	ttl       time.Duration
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// newTTLCache creates a typed cache over backend. A TTL of zero or less disables caching.
+func newTTLCache[V any](backend cache.Cache, namespace string, ttl time.Duration) *ttlCache[V] {
+	return &ttlCache[V]{
+		backend:   backend,
+		namespace: namespace,
+		ttl:       ttl,
+	}
+}
+
+// Get returns the cached value for key if present and not expired
+func (c *ttlCache[V]) Get(key string) (V, bool) {
+	return c.GetWithTTL(key, c.ttl)
+}
+
+// GetWithTTL is Get for entries cached with a TTL other than the cache's own, such
+// as a registry's override. A TTL of zero or less disables the lookup.
+func (c *ttlCache[V]) GetWithTTL(key string, ttl time.Duration) (V, bool) {
+	var value V
+	if ttl <= 0 {
+		return value, false
+	}
+
+	data, ok := c.backend.Get(c.namespace + ":" + key)
+	if !ok {
+		c.misses.Add(1)
+		return value, false
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		// Treat undecodable entries, e.g. written by an older version, as missing
+		c.backend.Delete(c.namespace + ":" + key)
+		c.misses.Add(1)
+		var zero V
+		return zero, false
+	}
+	c.hits.Add(1)
+	return value, true
+}
+
+// Set stores value under key for the cache TTL
+func (c *ttlCache[V]) Set(key string, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL stores value under key for ttl instead of the cache TTL. A TTL of zero
+// or less stores nothing.
+func (c *ttlCache[V]) SetWithTTL(key string, value V, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	c.backend.Set(c.namespace+":"+key, data, ttl)
+}
+
+// Delete removes key from the cache
+func (c *ttlCache[V]) Delete(key string) {
+	c.backend.Delete(c.namespace + ":" + key)
+}
+
+// ttlCacheStats reports how often a typed cache answered lookups. Entry counts and
+// sizes belong to the shared backend, which is reported separately.
+type ttlCacheStats struct {
+	Enabled bool   `json:"enabled"`
+	TTL     string `json:"ttl"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+// stats returns the cache's lookup counters
+func (c *ttlCache[V]) stats() ttlCacheStats {
+	return ttlCacheStats{
+		Enabled: c.ttl > 0,
+		TTL:     c.ttl.String(),
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+	}
+}
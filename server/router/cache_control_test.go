@@ -0,0 +1,49 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestManifestCacheControl(t *testing.T) {
+	const repository = "team/app"
+	fake := newFakeClient()
+	dgst := fake.addManifest(repository, v1.Manifest{}, "1.0.0")
+	fake.addRawManifest(repository, []byte("not a manifest"), "broken")
+	settings := DefaultApiSettings()
+	settings.CacheControl = true
+	manager := newTestManager(t, &policy.ConfigFile{}, settings, fake)
+
+	tests := []struct {
+		name       string
+		reference  string
+		accept     string
+		wantStatus int
+		want       string
+	}{
+		{name: "tag", reference: "1.0.0", wantStatus: http.StatusOK, want: "no-cache"},
+		{name: "digest", reference: dgst.String(), wantStatus: http.StatusOK, want: "public, max-age=31536000, immutable"},
+		{name: "normalized digest", reference: dgst.String(), accept: "application/json", wantStatus: http.StatusOK, want: "public, max-age=31536000, immutable"},
+		{name: "unparseable", reference: "broken", accept: "application/json", wantStatus: http.StatusInternalServerError},
+		{name: "missing", reference: "2.0.0", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/"+testRegistry+"/"+repository+"/"+tt.reference+"/manifest/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			resp := serve(manager, req)
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+			if got := resp.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/codekaizen-github/orashub/client"
+	"github.com/codekaizen-github/orashub/server/cache"
+)
+
+// backendStats describes the storage backend shared by the response caches
+type backendStats struct {
+	Type string `json:"type"`
+	cache.Stats
+}
+
+// blobCacheStats describes the on-disk layer blob cache
+type blobCacheStats struct {
+	Enabled bool   `json:"enabled"`
+	Dir     string `json:"dir,omitempty"`
+	client.BlobCacheStats
+}
+
+// cacheStatsResponse is the body of the cache stats endpoint
+type cacheStatsResponse struct {
+	Backend backendStats             `json:"backend"`
+	Caches  map[string]ttlCacheStats `json:"caches"`
+	Blob    blobCacheStats           `json:"blob"`
+}
+
+// HandleCacheStats reports hit and miss counts for each response cache, along with
+// the entry count and approximate size of the shared backend and the blob cache.
+// Counters start at zero when the server starts.
+func (m *ApiManager) HandleCacheStats(w http.ResponseWriter, req *http.Request) {
+	backend := m.Settings.CacheBackend
+	if backend == "" {
+		backend = cache.BackendMemory
+	}
+
+	response := cacheStatsResponse{
+		Backend: backendStats{Type: backend, Stats: m.cacheBackend.Stats()},
+		Caches: map[string]ttlCacheStats{
+			m.digestLookupCache.namespace:  m.digestLookupCache.stats(),
+			m.tagCache.namespace:           m.tagCache.stats(),
+			m.tagMetadataCache.namespace:   m.tagMetadataCache.stats(),
+			m.artifactTypesCache.namespace: m.artifactTypesCache.stats(),
+		},
+	}
+	if m.blobCache != nil {
+		response.Blob = blobCacheStats{Enabled: true, Dir: m.Settings.BlobCacheDir, BlobCacheStats: m.blobCache.Stats()}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		m.Logger.Error("Error encoding cache stats response: %v", err)
+	}
+}
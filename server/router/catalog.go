@@ -0,0 +1,121 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/codekaizen-github/orashub/client"
+	"github.com/codekaizen-github/orashub/server/policy"
+)
+
+const (
+	// defaultCatalogPageSize is the number of repositories returned when n isn't given
+	defaultCatalogPageSize = 100
+	// maxCatalogPageSize caps the n query parameter
+	maxCatalogPageSize = 1000
+)
+
+// HandleCatalog lists the repositories in a registry using the OCI _catalog API.
+// Repositories denied by the image policy are filtered out, so a page may hold
+// fewer than n entries even when more repositories exist.
+func (m *ApiManager) HandleCatalog(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+
+	// Get client
+	apiClient, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	// Read pagination parameters
+	query := req.URL.Query()
+	last := query.Get("last")
+	limit := defaultCatalogPageSize
+	if n := query.Get("n"); n != "" {
+		limit, err = strconv.Atoi(n)
+		if err != nil || limit < 1 {
+			http.Error(w, "n must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		if limit > maxCatalogPageSize {
+			limit = maxCatalogPageSize
+		}
+	}
+
+	repositories, err := apiClient.ListRepositories(last, limit)
+	if err != nil {
+		if errors.Is(err, client.ErrCatalogUnsupported) {
+			http.Error(w, fmt.Sprintf("registry '%s' does not support listing repositories", registry), http.StatusNotImplemented)
+			return
+		}
+		writeRegistryError(w, err)
+		return
+	}
+
+	// Filter out repositories the policy doesn't allow, so blocked repositories don't leak
+	allowed := make([]string, 0, len(repositories))
+	endpoints := make(map[string]string)
+	for _, repository := range repositories {
+		repositoryPath := fmt.Sprintf("%s/%s", apiClient.GetRegistry(), repository)
+		if !m.withinNamespacePrefix(registry, repository) || !m.isRepositoryAllowed(repositoryPath) ||
+			(m.apiTokensEnabled() && !policy.IdentityAllows(repositoryPath, requestToken(req.Context()), m.ImagePolicy)) {
+			continue
+		}
+		allowed = append(allowed, repository)
+		endpoints[repository] = fmt.Sprintf("/api/v1/%s/%s", registry, repository)
+	}
+
+	// Build response
+	response := map[string]interface{}{
+		"registry":     apiClient.GetRegistry(),
+		"repositories": allowed,
+		"endpoints":    endpoints,
+	}
+
+	// A full page means there may be more, so link to the next one
+	if len(repositories) == limit {
+		next := url.Values{}
+		next.Set("n", strconv.Itoa(limit))
+		next.Set("last", repositories[len(repositories)-1])
+		response["next"] = req.URL.Path + "?" + next.Encode()
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// isRepositoryAllowed reports whether the policy allows a full repository path
+// (registry/namespace/repository) without writing a response
+func (m *ApiManager) isRepositoryAllowed(repositoryPath string) bool {
+	// Gone repositories are never listed
+	if m.ImagePolicy != nil && policy.IsGone(repositoryPath, m.ImagePolicy) {
+		return false
+	}
+	// If no policy is configured, allow all repositories
+	if m.ImagePolicy == nil || (len(m.ImagePolicy.AllowedRepositories) == 0 && len(m.ImagePolicy.BlockedRepositories) == 0) {
+		return true
+	}
+	return policy.IsAllowed(repositoryPath, m.ImagePolicy)
+}
+
+// withinNamespacePrefix reports whether a repository path (without the registry) is
+// inside the namespace prefix configured for a registry or alias
+func (m *ApiManager) withinNamespacePrefix(registry, repositoryPath string) bool {
+	registries := m.registries()
+	return policy.WithinNamespacePrefix(repositoryPath, registries.namespacePrefixes[registries.resolve(registry)])
+}
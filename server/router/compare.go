@@ -0,0 +1,151 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/codekaizen-github/orashub/client"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// comparedLayer is a published layer whose digest matched the compared file
+type comparedLayer struct {
+	Index     int    `json:"index"`
+	Digest    string `json:"digest"`
+	MediaType string `json:"media_type"`
+	Filename  string `json:"filename,omitempty"`
+	Size      int64  `json:"size"`
+}
+
+// compareResponse reports whether a file matches any layer of a published artifact
+type compareResponse struct {
+	Match bool `json:"match"`
+	// Digest is the digest that was compared, as given or computed from the body
+	Digest string `json:"digest"`
+	// Source is "digest" when the digest was given and "body" when it was computed
+	Source string `json:"source"`
+	// Size is the number of bytes hashed, for uploaded bodies
+	Size           int64           `json:"size,omitempty"`
+	ManifestDigest string          `json:"manifest_digest"`
+	MatchedLayers  []comparedLayer `json:"matched_layers"`
+}
+
+// compareDigest returns the digest to compare: the ?digest= query parameter when
+// given, else the SHA-256 of the request body, which is hashed as it is read so
+// the upload is never buffered. The body is bounded by ORASHUB_MAX_BODY_SIZE. On
+// failure it writes the error response and returns false.
+func compareDigest(w http.ResponseWriter, req *http.Request) (compareResponse, bool) {
+	if raw := req.URL.Query().Get("digest"); raw != "" {
+		dgst, err := digest.Parse(raw)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid digest %q: %v", raw, err), http.StatusBadRequest)
+			return compareResponse{}, false
+		}
+		return compareResponse{Digest: dgst.String(), Source: "digest"}, true
+	}
+
+	digester := digest.Canonical.Digester()
+	size, err := io.Copy(digester.Hash(), req.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("request body too large (limit %d bytes)", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, fmt.Sprintf("error reading request body: %v", err), http.StatusBadRequest)
+		}
+		return compareResponse{}, false
+	}
+	if size == 0 {
+		http.Error(w, "send the file as the request body or pass ?digest=", http.StatusBadRequest)
+		return compareResponse{}, false
+	}
+	return compareResponse{Digest: digester.Digest().String(), Source: "body", Size: size}, true
+}
+
+// HandleCompare reports whether a local file, given by digest or uploaded as the
+// request body, matches any layer of the published artifact, e.g. so a CI job can
+// tell whether a build differs from the release. Only the manifest is fetched.
+func (m *ApiManager) HandleCompare(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+	namespace := pathValues["namespace"]
+	repository := pathValues["repository"]
+	tag := pathValues["tag"]
+
+	// Get client
+	apiClient, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	// Check policy
+	if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+		return
+	}
+
+	// Work out what to compare before fetching the manifest. This reads the upload,
+	// so it only happens once the registry and policy have accepted the request.
+	response, ok := compareDigest(w, req)
+	if !ok {
+		return
+	}
+
+	// Build namespaced repository
+	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+
+	// Get and parse the manifest
+	desc, content, err := apiClient.FetchManifest(namespacedRepository, tag)
+	if err != nil {
+		m.Logger.Error("Error getting manifest for %s/%s:%s: %v", namespace, repository, tag, err)
+		writeRegistryError(w, err)
+		return
+	}
+	if err := m.manifestAnnotationsAllowed(content); err != nil {
+		m.writeAnnotationDenied(w, namespacedRepository, tag, err)
+		return
+	}
+	if err := client.CheckManifestMediaType(desc.MediaType); err != nil {
+		writeRegistryError(w, err)
+		return
+	}
+	manifest, err := client.ParseManifest(content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if !m.checkLayerLimit(w, namespacedRepository, tag, manifest) {
+		return
+	}
+
+	response.ManifestDigest = desc.Digest.String()
+	response.MatchedLayers = []comparedLayer{}
+	for i, layer := range manifest.Layers {
+		if layer.Digest.String() != response.Digest {
+			continue
+		}
+		response.MatchedLayers = append(response.MatchedLayers, comparedLayer{
+			Index:     i,
+			Digest:    layer.Digest.String(),
+			MediaType: layer.MediaType,
+			Filename:  layer.Annotations[v1.AnnotationTitle],
+			Size:      layer.Size,
+		})
+	}
+	response.Match = len(response.MatchedLayers) > 0
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		m.Logger.Error("Error encoding compare response: %v", err)
+	}
+}
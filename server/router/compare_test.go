@@ -0,0 +1,69 @@
+package router
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// countingReader counts the bytes read from it
+type countingReader struct {
+	io.Reader
+	read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestHandleCompareChecksPolicyBeforeReadingBody(t *testing.T) {
+	const repository = "team/app"
+	fake := newFakeClient()
+	layer := fake.addBlob("application/zip", []byte("plugin"))
+	fake.addManifest(repository, v1.Manifest{Layers: []v1.Descriptor{layer}}, "1.0.0")
+	config := &policy.ConfigFile{BlockedRepositories: []string{testRegistry + "/blocked/*"}}
+	manager := newTestManager(t, config, DefaultApiSettings(), fake)
+
+	tests := []struct {
+		name      string
+		path      string
+		want      int
+		wantRead  bool
+		wantMatch bool
+	}{
+		{name: "matching upload", path: testRegistry + "/" + repository, want: http.StatusOK, wantRead: true, wantMatch: true},
+		{name: "unknown registry", path: "other.example/" + repository, want: http.StatusNotFound},
+		{name: "denied repository", path: testRegistry + "/blocked/app", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &countingReader{Reader: strings.NewReader("plugin")}
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/"+tt.path+"/1.0.0/compare/", body)
+			resp := serve(manager, req)
+			if resp.Code != tt.want {
+				t.Fatalf("got status %d, want %d: %s", resp.Code, tt.want, resp.Body)
+			}
+			if read := body.read > 0; read != tt.wantRead {
+				t.Errorf("body read = %t, want %t", read, tt.wantRead)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var response compareResponse
+			if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Match != tt.wantMatch {
+				t.Errorf("match = %t, want %t", response.Match, tt.wantMatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+)
+
+// downloadExposedHeaders are the download response headers cross-origin scripts
+// need to name the file, track progress and verify the content
+var downloadExposedHeaders = []string{"Content-Disposition", "Content-Length", ContentDigestTrailer}
+
+// corsPreflightMaxAge is how long, in seconds, browsers may cache a preflight response
+const corsPreflightMaxAge = "600"
+
+// CORSOrigins lists the origins allowed to make cross-origin requests. "*" allows any origin.
+type CORSOrigins []string
+
+// ParseCORSOrigins parses a comma-separated list of origins, e.g.
+// "https://example.com, https://admin.example.com" or "*"
+func ParseCORSOrigins(value string) CORSOrigins {
+	var origins CORSOrigins
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for a request origin,
+// or "" when the origin isn't allowed
+func (o CORSOrigins) allowOrigin(origin string) string {
+	for _, allowed := range o {
+		if allowed == "*" {
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// withCORS adds CORS headers for allowed origins, exposing the given response
+// headers to scripts. Preflight requests additionally get the route's allowed
+// methods and the headers the browser asked to send.
+func (m *ApiManager) withCORS(allow string, exposed []string, handler http.HandlerFunc) http.HandlerFunc {
+	if m.Settings.UpstreamTimingHeader {
+		exposed = append(exposed[:len(exposed):len(exposed)], UpstreamDurationHeader)
+	}
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		origin := req.Header.Get("Origin")
+		allowed := m.Settings.CORSAllowedOrigins.allowOrigin(origin)
+		if origin == "" || allowed == "" {
+			handler(w, req)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", allowed)
+		if len(exposed) > 0 {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(exposed, ", "))
+		}
+		if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", allow)
+			if headers := req.Header.Get("Access-Control-Request-Headers"); headers != "" {
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+			w.Header().Set("Access-Control-Max-Age", corsPreflightMaxAge)
+		}
+		handler(w, req)
+	}
+}
@@ -0,0 +1,83 @@
+package router
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/codekaizen-github/orashub/client"
+)
+
+// CredentialOverrideHeader carries per-request registry credentials as
+// "Basic base64(username:password)" when credential overrides are enabled
+const CredentialOverrideHeader = "X-Registry-Authorization"
+
+// getRequestClient returns the client to use for a request. When credential
+// overrides are enabled and the request carries CredentialOverrideHeader, an
+// ephemeral client using the caller's credentials is built for the configured
+// registry; otherwise the shared client is returned.
+//
+// Overrides only ever target registries in the configuration, so callers can't
+// use the server to reach arbitrary hosts.
+func (m *ApiManager) getRequestClient(req *http.Request, registry string) (client.ClientInterface, error) {
+	shared, err := m.getClient(registry)
+	if err != nil {
+		return nil, err
+	}
+
+	selected, err := m.selectRequestClient(req, shared)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bind registry calls to the route's deadline, if it has one. Other routes keep
+	// the client's own context, so a download outlives the request timing out.
+	if _, ok := req.Context().Deadline(); ok {
+		selected = selected.WithContext(req.Context())
+	}
+
+	// Record registry round trips for the upstream duration header
+	if timings := upstreamTimings(req.Context()); timings != nil {
+		return selected.WithTimings(timings), nil
+	}
+	return selected, nil
+}
+
+// selectRequestClient picks between the shared client and one built from the
+// request's override credentials
+func (m *ApiManager) selectRequestClient(req *http.Request, shared client.ClientInterface) (client.ClientInterface, error) {
+	header := req.Header.Get(CredentialOverrideHeader)
+	if !m.Settings.AllowCredentialOverride || header == "" {
+		return shared, nil
+	}
+
+	username, password, err := parseBasicCredentials(header)
+	if err != nil {
+		return nil, err
+	}
+
+	// Never log the credentials themselves
+	m.Logger.Debug("Using per-request credentials for registry %s", shared.GetRegistry())
+	options := m.registryClientOptions(m.registries().credentials[shared.GetRegistry()])
+	return client.NewClient(shared.GetRegistry(), username, password, options...)
+}
+
+// parseBasicCredentials decodes a "Basic base64(username:password)" header value
+func parseBasicCredentials(header string) (string, string, error) {
+	scheme, encoded, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Basic") {
+		return "", "", fmt.Errorf("%w: expected 'Basic <credentials>'", ErrInvalidCredentials)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", "", fmt.Errorf("%w: credentials are not valid base64", ErrInvalidCredentials)
+	}
+
+	username, password, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return "", "", fmt.Errorf("%w: expected 'username:password'", ErrInvalidCredentials)
+	}
+	return username, password, nil
+}
@@ -0,0 +1,76 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/codekaizen-github/orashub/client"
+)
+
+// debugBaseResponse is the registry's /v2/ answer in the debug response
+type debugBaseResponse struct {
+	Registry        string      `json:"registry"`
+	Challenge       string      `json:"challenge,omitempty"`
+	AuthScheme      string      `json:"auth_scheme,omitempty"`
+	AnonymousStatus int         `json:"anonymous_status"`
+	Status          int         `json:"status,omitempty"`
+	Headers         http.Header `json:"headers,omitempty"`
+	Body            string      `json:"body"`
+	BodyTruncated   bool        `json:"body_truncated,omitempty"`
+	Error           string      `json:"error,omitempty"`
+}
+
+// HandleDebugBase requests the registry's /v2/ base endpoint, first anonymously and
+// then with the configured credentials, and returns the raw status, headers and
+// body, for working out why a registry won't authenticate. Credential headers are
+// redacted and the Authorization header sent is never echoed. When the request
+// with credentials gets no response, e.g. because the token exchange failed, the
+// anonymous challenge is returned with the error.
+func (m *ApiManager) HandleDebugBase(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+
+	// Get client, with per-request credentials when they are given and allowed
+	apiClient, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	base, err := apiClient.DebugBase(req.Context())
+	if err != nil && base.AnonymousStatus == 0 {
+		m.Logger.Error("Error requesting /v2/ on %s: %v", apiClient.GetRegistry(), err)
+		writeRegistryError(w, err)
+		return
+	}
+
+	response := newDebugBaseResponse(apiClient.GetRegistry(), base)
+	if err != nil {
+		response.Error = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		m.Logger.Error("Error encoding debug response: %v", err)
+	}
+}
+
+// newDebugBaseResponse describes base for the debug response
+func newDebugBaseResponse(registry string, base *client.BaseResponse) debugBaseResponse {
+	return debugBaseResponse{
+		Registry:        registry,
+		Challenge:       base.Challenge,
+		AuthScheme:      base.AuthScheme,
+		AnonymousStatus: base.AnonymousStatus,
+		Status:          base.StatusCode,
+		Headers:         base.Header,
+		Body:            string(base.Body),
+		BodyTruncated:   base.Truncated,
+	}
+}
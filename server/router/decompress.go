@@ -0,0 +1,91 @@
+package router
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compression identifies a layer compression format that can be decoded on download
+type compression int
+
+const (
+	compressionNone compression = iota
+	compressionGzip
+	compressionZstd
+)
+
+// layerCompression detects the compression of a layer from its media type
+func layerCompression(mediaType string) compression {
+	switch mt := strings.ToLower(mediaType); {
+	case mt == "application/gzip", mt == "application/x-gzip", strings.HasSuffix(mt, "+gzip"):
+		return compressionGzip
+	case mt == "application/zstd", strings.HasSuffix(mt, "+zstd"):
+		return compressionZstd
+	default:
+		return compressionNone
+	}
+}
+
+// decompressedLayer wraps a compressed layer reader, closing both the decoder
+// and the underlying layer when done
+type decompressedLayer struct {
+	io.Reader
+	closeDecoder func()
+	layer        io.Closer
+}
+
+// Close implements io.Closer
+func (d *decompressedLayer) Close() error {
+	d.closeDecoder()
+	return d.layer.Close()
+}
+
+// newDecompressedLayer wraps layer in a decoder for the given compression
+func newDecompressedLayer(layer io.ReadCloser, kind compression) (*decompressedLayer, error) {
+	switch kind {
+	case compressionGzip:
+		reader, err := gzip.NewReader(layer)
+		if err != nil {
+			return nil, err
+		}
+		return &decompressedLayer{Reader: reader, closeDecoder: func() { reader.Close() }, layer: layer}, nil
+	case compressionZstd:
+		decoder, err := zstd.NewReader(layer)
+		if err != nil {
+			return nil, err
+		}
+		return &decompressedLayer{Reader: decoder, closeDecoder: decoder.Close, layer: layer}, nil
+	default:
+		return &decompressedLayer{Reader: layer, closeDecoder: func() {}, layer: layer}, nil
+	}
+}
+
+// decompressedName returns the filename and media type of a layer after decompression,
+// e.g. plugin.zip.gz becomes plugin.zip with application/zip and a +gzip media type
+// suffix is dropped
+func decompressedName(filename, mediaType string, kind compression) (string, string) {
+	// Drop the compression extension from the filename
+	ext := strings.ToLower(path.Ext(filename))
+	switch {
+	case kind == compressionGzip && ext == ".tgz":
+		filename = strings.TrimSuffix(filename, path.Ext(filename)) + ".tar"
+	case kind == compressionGzip && ext == ".gz", kind == compressionZstd && (ext == ".zst" || ext == ".zstd"):
+		filename = strings.TrimSuffix(filename, path.Ext(filename))
+	}
+
+	// Structured media types name the inner format, e.g. application/vnd.oci.image.layer.v1.tar+gzip
+	if plus := strings.LastIndex(mediaType, "+"); plus > 0 {
+		return filename, mediaType[:plus]
+	}
+
+	// Otherwise derive the type from the remaining extension
+	if inner := mime.TypeByExtension(path.Ext(filename)); inner != "" {
+		return filename, inner
+	}
+	return filename, "application/octet-stream"
+}
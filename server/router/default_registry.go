@@ -0,0 +1,106 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+)
+
+// apiPrefix is the path prefix shared by every API route
+const apiPrefix = "/api/v1/"
+
+// looksLikeRegistry reports whether a path segment names a registry host rather
+// than a namespace, following the Docker reference rules: it contains a dot or a
+// port, or is localhost
+func looksLikeRegistry(segment string) bool {
+	return strings.ContainsAny(segment, ".:") || segment == "localhost"
+}
+
+// reservedSegments returns the literal first path segments of the API routes, such
+// as "routes" and "admin", which are never read as a namespace
+func (m *ApiManager) reservedSegments() map[string]bool {
+	reserved := make(map[string]bool)
+	for _, route := range m.Routes {
+		rest, ok := strings.CutPrefix(route.Pattern, apiPrefix)
+		if !ok {
+			continue
+		}
+		segment, _, _ := strings.Cut(rest, "/")
+		if segment != "" && !strings.HasPrefix(segment, "{") {
+			reserved[segment] = true
+		}
+	}
+	return reserved
+}
+
+// defaultRegistry picks the default registry: configured when set, else the config
+// file's default_registry, else the first registry in config file order
+func defaultRegistry(config *policy.ConfigFile, configured string) string {
+	switch {
+	case configured != "":
+		return configured
+	case config.DefaultRegistry != "":
+		return config.DefaultRegistry
+	case len(config.Registries) > 0:
+		return config.Registries[0].Name
+	}
+	return ""
+}
+
+// validateDefaultRegistry checks that the default registry is one of the set's
+// registries, by name or alias
+func (s *registrySet) validateDefaultRegistry() error {
+	if s.defaultRegistry == "" {
+		return nil
+	}
+	if _, ok := s.credentials[s.resolve(s.defaultRegistry)]; !ok {
+		return fmt.Errorf("default registry '%s' is not a configured registry or alias", s.defaultRegistry)
+	}
+	return nil
+}
+
+// ValidateDefaultRegistry checks that the default registry is configured, by name
+// or alias
+func (m *ApiManager) ValidateDefaultRegistry() error {
+	return m.registries().validateDefaultRegistry()
+}
+
+// DefaultRegistry returns the registry that API paths without a registry segment
+// are routed to, or empty when there is none. It can change on reload.
+func (m *ApiManager) DefaultRegistry() string {
+	return m.registries().defaultRegistry
+}
+
+// WithDefaultRegistry lets API paths leave out the registry, routing
+// /api/v1/{namespace}/{repository}/... to the default registry as if it had been
+// given. The first segment after /api/v1/ is kept as the registry when it is a
+// configured registry or alias, looks like a registry host, or starts a fixed
+// route such as /api/v1/routes/; otherwise the default registry is inserted before
+// it. The default is looked up per request, so a reload can change it; while there
+// is none, requests pass through unchanged.
+func (m *ApiManager) WithDefaultRegistry(next http.Handler) http.Handler {
+	reserved := m.reservedSegments()
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		set := m.registries()
+		rest, ok := strings.CutPrefix(req.URL.Path, apiPrefix)
+		segment, _, _ := strings.Cut(rest, "/")
+		if set.defaultRegistry == "" || !ok || segment == "" || reserved[segment] || looksLikeRegistry(segment) {
+			next.ServeHTTP(w, req)
+			return
+		}
+		if _, ok := set.credentials[set.resolve(segment)]; ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		// Route to the default registry
+		rewritten := req.Clone(req.Context())
+		rewritten.URL.Path = apiPrefix + set.defaultRegistry + "/" + rest
+		if rawRest, ok := strings.CutPrefix(req.URL.RawPath, apiPrefix); ok {
+			rewritten.URL.RawPath = apiPrefix + set.defaultRegistry + "/" + rawRest
+		}
+		next.ServeHTTP(w, rewritten)
+	})
+}
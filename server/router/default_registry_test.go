@@ -0,0 +1,64 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+)
+
+func TestDefaultRegistry(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		setting    string
+		want       string
+	}{
+		{name: "unset uses the first registry", want: "/api/v1/b.example/team/app/"},
+		{name: "config field", configured: "c.example", want: "/api/v1/c.example/team/app/"},
+		{name: "config field alias", configured: "a", want: "/api/v1/a/team/app/"},
+		{name: "setting overrides config", configured: "c.example", setting: "a.example", want: "/api/v1/a.example/team/app/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Not in alphabetical order, so config file order is what picks the default
+			config := &policy.ConfigFile{
+				Registries: []policy.RegistryCredentials{
+					{Name: "b.example"},
+					{Name: "a.example", Aliases: []string{"a"}},
+					{Name: "c.example"},
+				},
+				DefaultRegistry: tt.configured,
+			}
+			if err := config.Validate(); err != nil {
+				t.Fatalf("invalid config: %v", err)
+			}
+			settings := DefaultApiSettings()
+			settings.DefaultRegistry = tt.setting
+			manager := newTestManager(t, config, settings, nil)
+			if err := manager.ValidateDefaultRegistry(); err != nil {
+				t.Fatalf("ValidateDefaultRegistry: %v", err)
+			}
+
+			var got string
+			handler := manager.WithDefaultRegistry(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				got = req.URL.Path
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/team/app/", nil))
+			if got != tt.want {
+				t.Errorf("routed to %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDefaultRegistryConfig(t *testing.T) {
+	config := &policy.ConfigFile{
+		Registries:      []policy.RegistryCredentials{{Name: "a.example"}},
+		DefaultRegistry: "missing.example",
+	}
+	if err := config.Validate(); err == nil {
+		t.Error("expected an unknown default_registry to fail validation")
+	}
+}
@@ -0,0 +1,260 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/codekaizen-github/orashub/client"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// diffSide identifies one of the two manifests being compared
+type diffSide struct {
+	Reference string `json:"reference"`
+	Digest    string `json:"digest"`
+}
+
+// layerChange describes a layer present on both sides with different content
+type layerChange struct {
+	Key  string    `json:"key"`
+	From layerSize `json:"from"`
+	To   layerSize `json:"to"`
+}
+
+// layerDiff lists layer differences between two manifests
+type layerDiff struct {
+	Added   []layerSize   `json:"added"`
+	Removed []layerSize   `json:"removed"`
+	Changed []layerChange `json:"changed"`
+}
+
+// valueChange describes a value that differs between two manifests
+type valueChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// valueDiff lists added, removed and changed keys between two maps
+type valueDiff struct {
+	Added   map[string]interface{} `json:"added"`
+	Removed map[string]interface{} `json:"removed"`
+	Changed map[string]valueChange `json:"changed"`
+}
+
+// manifestDiff is the response body of the diff endpoint
+type manifestDiff struct {
+	From           diffSide   `json:"from"`
+	To             diffSide   `json:"to"`
+	Layers         layerDiff  `json:"layers"`
+	Annotations    valueDiff  `json:"annotations"`
+	PluginMetadata *valueDiff `json:"plugin_metadata,omitempty"`
+}
+
+// HandleDiff compares the manifests of two references in a repository, given as
+// ?from= and ?to=, and reports layer, annotation and plugin metadata changes
+func (m *ApiManager) HandleDiff(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+	namespace := pathValues["namespace"]
+	repository := pathValues["repository"]
+
+	from := req.URL.Query().Get("from")
+	to := req.URL.Query().Get("to")
+	if from == "" || to == "" {
+		http.Error(w, "both from and to query parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	// Get client
+	apiClient, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	// Check policy
+	if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+		return
+	}
+
+	// Build namespaced repository
+	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+
+	// Fetch both manifests concurrently
+	references := []string{from, to}
+	contents := make([][]byte, len(references))
+	errs := make([]error, len(references))
+	var wg sync.WaitGroup
+	for i, reference := range references {
+		wg.Add(1)
+		go func(i int, reference string) {
+			defer wg.Done()
+			if err := m.fetchLimiter.acquire(req.Context()); err != nil {
+				errs[i] = err
+				return
+			}
+			defer m.fetchLimiter.release()
+			_, contents[i], errs[i] = apiClient.FetchManifest(namespacedRepository, reference)
+		}(i, reference)
+	}
+	wg.Wait()
+
+	manifests := make([]*client.Manifest, len(references))
+	for i, reference := range references {
+		if errs[i] != nil {
+			m.Logger.Error("Error getting manifest for %s/%s:%s: %v", namespace, repository, reference, errs[i])
+			writeRegistryError(w, errs[i])
+			return
+		}
+		if err := m.manifestAnnotationsAllowed(contents[i]); err != nil {
+			m.writeAnnotationDenied(w, namespacedRepository, reference, err)
+			return
+		}
+		manifests[i], err = client.ParseManifest(contents[i])
+		if err != nil {
+			m.Logger.Error("Error parsing manifest for %s/%s:%s: %v", namespace, repository, reference, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !m.checkLayerLimit(w, namespacedRepository, reference, manifests[i]) {
+			return
+		}
+	}
+
+	response := manifestDiff{
+		From:        diffSide{Reference: from, Digest: digest.FromBytes(contents[0]).String()},
+		To:          diffSide{Reference: to, Digest: digest.FromBytes(contents[1]).String()},
+		Layers:      diffLayers(manifests[0].Layers, manifests[1].Layers),
+		Annotations: diffValues(manifests[0].DecodedAnnotations(), manifests[1].DecodedAnnotations()),
+	}
+
+	// Break plugin metadata down field by field, since it's a single JSON annotation
+	fromMetadata, fromOk := pluginMetadataFields(manifests[0], m.Settings.MetadataAnnotationKey)
+	toMetadata, toOk := pluginMetadataFields(manifests[1], m.Settings.MetadataAnnotationKey)
+	if fromOk || toOk {
+		metadataDiff := diffValues(fromMetadata, toMetadata)
+		response.PluginMetadata = &metadataDiff
+	}
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		m.Logger.Error("Error encoding diff response: %v", err)
+	}
+}
+
+// layerKey identifies a layer across manifests by its title, falling back to its position
+func layerKey(layer v1.Descriptor, index int) string {
+	if title := layer.Annotations[v1.AnnotationTitle]; title != "" {
+		return title
+	}
+	return "#" + strconv.Itoa(index)
+}
+
+// diffLayers matches layers by key and reports those added, removed or changed
+func diffLayers(from, to []v1.Descriptor) layerDiff {
+	result := layerDiff{Added: []layerSize{}, Removed: []layerSize{}, Changed: []layerChange{}}
+
+	fromLayers := make(map[string]v1.Descriptor, len(from))
+	for i, layer := range from {
+		fromLayers[layerKey(layer, i)] = layer
+	}
+
+	seen := make(map[string]bool, len(to))
+	for i, layer := range to {
+		key := layerKey(layer, i)
+		seen[key] = true
+		previous, ok := fromLayers[key]
+		switch {
+		case !ok:
+			result.Added = append(result.Added, newLayerSize(layer))
+		case previous.Digest != layer.Digest || previous.Size != layer.Size || previous.MediaType != layer.MediaType:
+			result.Changed = append(result.Changed, layerChange{Key: key, From: newLayerSize(previous), To: newLayerSize(layer)})
+		}
+	}
+
+	for i, layer := range from {
+		if !seen[layerKey(layer, i)] {
+			result.Removed = append(result.Removed, newLayerSize(layer))
+		}
+	}
+	return result
+}
+
+// newLayerSize describes a layer descriptor in responses
+func newLayerSize(layer v1.Descriptor) layerSize {
+	return layerSize{
+		Digest:    layer.Digest.String(),
+		MediaType: layer.MediaType,
+		Filename:  layer.Annotations[v1.AnnotationTitle],
+		Size:      layer.Size,
+	}
+}
+
+// diffValues reports keys added, removed or changed between two maps
+func diffValues(from, to map[string]interface{}) valueDiff {
+	result := valueDiff{
+		Added:   map[string]interface{}{},
+		Removed: map[string]interface{}{},
+		Changed: map[string]valueChange{},
+	}
+	for key, value := range to {
+		previous, ok := from[key]
+		switch {
+		case !ok:
+			result.Added[key] = value
+		case !reflect.DeepEqual(previous, value):
+			result.Changed[key] = valueChange{From: previous, To: value}
+		}
+	}
+	for key, value := range from {
+		if _, ok := to[key]; !ok {
+			result.Removed[key] = value
+		}
+	}
+	return result
+}
+
+// pluginMetadataFields flattens the plugin metadata annotation of a manifest into
+// dotted field names, e.g. sections.changelog. Returns false when the manifest
+// has no parseable plugin metadata under key.
+func pluginMetadataFields(manifest *client.Manifest, key string) (map[string]interface{}, bool) {
+	fields := map[string]interface{}{}
+	metadata, ok := manifest.GetPluginMetadata(key)
+	if !ok {
+		return fields, false
+	}
+	flattenFields("", metadata, fields)
+	return fields, true
+}
+
+// flattenFields copies nested objects into fields using dotted keys
+func flattenFields(prefix string, values map[string]interface{}, fields map[string]interface{}) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+		if nested, ok := values[key].(map[string]interface{}); ok {
+			flattenFields(name, nested, fields)
+			continue
+		}
+		fields[name] = values[key]
+	}
+}
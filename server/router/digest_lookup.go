@@ -0,0 +1,138 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/codekaizen-github/orashub/client"
+)
+
+// HandleTagsForDigest lists every tag in a repository that resolves to the same
+// digest as the requested reference. The reference may be a tag or a digest.
+//
+// This resolves every tag in the repository, so it costs one registry request per
+// tag. Resolutions are bounded by the fetch limiter and results are cached for
+// DigestLookupCacheTTL, but repositories with many tags will still be slow.
+func (m *ApiManager) HandleTagsForDigest(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+	namespace := pathValues["namespace"]
+	repository := pathValues["repository"]
+	reference := pathValues["tag"]
+
+	// Get client
+	client, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	// Check policy
+	if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+		return
+	}
+
+	// Build namespaced repository
+	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+
+	// Check annotation policy, which needs the manifest
+	pinned, ok := m.checkAnnotationPolicy(w, client, namespacedRepository, reference)
+	if !ok {
+		return
+	}
+
+	// Resolve the requested reference to the digest we are looking for
+	desc, err := client.ResolveDescriptor(namespacedRepository, pinned)
+	if err != nil {
+		writeRegistryError(w, err)
+		return
+	}
+	targetDigest := desc.Digest.String()
+
+	// Lookups made with per-request credentials may see other tags, so they are
+	// neither served from the cache nor stored in it
+	cacheKey := fmt.Sprintf("%s/%s@%s", registry, namespacedRepository, targetDigest)
+	cacheTTL := m.manifestCacheTTL(registry, m.digestLookupCache.ttl)
+	useCache := req.Header.Get(CredentialOverrideHeader) == ""
+	var matching []string
+	hit := false
+	if useCache {
+		matching, hit = m.digestLookupCache.GetWithTTL(cacheKey, cacheTTL)
+	}
+	if !hit {
+		matching, err = m.findTagsForDigest(req, client, namespacedRepository, targetDigest)
+		if err != nil {
+			writeRegistryError(w, err)
+			return
+		}
+		if useCache {
+			m.digestLookupCache.SetWithTTL(cacheKey, matching, cacheTTL)
+		}
+	}
+
+	// Build response
+	response := map[string]interface{}{
+		"repository": namespacedRepository,
+		"registry":   client.GetRegistry(),
+		"digest":     targetDigest,
+		"tags":       matching,
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// findTagsForDigest resolves every tag in the repository concurrently and returns
+// the sorted list of tags whose digest matches targetDigest
+func (m *ApiManager) findTagsForDigest(req *http.Request, apiClient client.ClientInterface, repository, targetDigest string) ([]string, error) {
+	tags, err := apiClient.ListTags(repository, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		matching = make([]string, 0)
+	)
+	for _, tag := range tags {
+		if err := m.fetchLimiter.acquire(req.Context()); err != nil {
+			wg.Wait()
+			return nil, err
+		}
+
+		wg.Add(1)
+		go func(tag string) {
+			defer wg.Done()
+			defer m.fetchLimiter.release()
+
+			desc, err := apiClient.ResolveDescriptor(repository, tag)
+			if err != nil {
+				// A single unresolvable tag shouldn't fail the whole lookup
+				m.Logger.Warn("Error resolving tag %s in %s: %v", tag, repository, err)
+				return
+			}
+			if desc.Digest.String() == targetDigest {
+				mu.Lock()
+				matching = append(matching, tag)
+				mu.Unlock()
+			}
+		}(tag)
+	}
+	wg.Wait()
+
+	sort.Strings(matching)
+	return matching, nil
+}
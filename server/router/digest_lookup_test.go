@@ -0,0 +1,40 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestTagsForDigestCacheSkipsCredentialOverride(t *testing.T) {
+	fake := newFakeClient()
+	fake.addManifest("team/plugin", v1.Manifest{ArtifactType: "application/vnd.example.plugin"}, "1.0.0", "latest")
+	manager := newTestManager(t, &policy.ConfigFile{}, DefaultApiSettings(), fake)
+
+	// Each request lists tags unless its lookup came from the cache
+	tests := []struct {
+		name        string
+		override    bool
+		wantLookups int
+	}{
+		{name: "override before anything is cached", override: true, wantLookups: 1},
+		{name: "anonymous lookup isn't served the override's", wantLookups: 2},
+		{name: "override isn't served the anonymous lookup", override: true, wantLookups: 3},
+		{name: "anonymous lookup is cached", wantLookups: 3},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/"+testRegistry+"/team/plugin/latest/tags/", nil)
+		if tt.override {
+			req.Header.Set(CredentialOverrideHeader, "Basic dXNlcjpwYXNz")
+		}
+		if code := serve(manager, req).Code; code != http.StatusOK {
+			t.Fatalf("%s: got status %d", tt.name, code)
+		}
+		if got := fake.callCount("ListTags"); got != tt.wantLookups {
+			t.Errorf("%s: got %d lookups, want %d", tt.name, got, tt.wantLookups)
+		}
+	}
+}
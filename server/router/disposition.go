@@ -0,0 +1,106 @@
+package router
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// sanitizeFilename reduces a suggested filename to its last path element and drops
+// control characters, so a title like "../../etc/passwd" or "a\\b.zip" can't
+// suggest a path outside the download directory
+func sanitizeFilename(name, fallback string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return fallback
+	}
+	return name
+}
+
+// safeFilenamePart keeps letters, digits, dots, dashes and underscores from a
+// metadata value, replacing anything else with a dash
+func safeFilenamePart(value string) string {
+	value = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '-'
+		}
+	}, strings.TrimSpace(value))
+	return strings.Trim(value, ".-")
+}
+
+// metadataFilename builds a {slug}.{version} download name from plugin metadata,
+// keeping the extension of the layer's own filename (.zip when it has none), along
+// with the inner extension of compressed names like .zip.gz or .tar.zst.
+// Returns "" when the metadata lacks a usable slug or version.
+func metadataFilename(metadata map[string]interface{}, layerFilename string) string {
+	slug, _ := metadata["slug"].(string)
+	version, _ := metadata["version"].(string)
+	slug, version = safeFilenamePart(slug), safeFilenamePart(version)
+	if slug == "" || version == "" {
+		return ""
+	}
+
+	ext := path.Ext(layerFilename)
+	switch strings.ToLower(ext) {
+	case "":
+		ext = ".zip"
+	case ".gz", ".zst", ".zstd", ".bz2", ".xz":
+		ext = path.Ext(strings.TrimSuffix(layerFilename, ext)) + ext
+	}
+	return slug + "." + version + "." + safeFilenamePart(ext)
+}
+
+// asciiFilename builds the plain filename= fallback, replacing anything outside
+// printable ASCII, and the quote and backslash characters, with underscores
+func asciiFilename(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// encodeRFC5987 percent-encodes a value for use in an RFC 5987 ext-value, as in filename*=
+func encodeRFC5987(value string) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		if isAttrChar(c) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// isAttrChar reports whether c may appear unencoded in an RFC 5987 value
+func isAttrChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
+
+// contentDisposition builds a Content-Disposition header value carrying both an
+// ASCII filename= fallback and a UTF-8 filename*= form, so every browser saves
+// the right name
+func contentDisposition(disposition, filename string) string {
+	filename = sanitizeFilename(filename, "download")
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, disposition, asciiFilename(filename), encodeRFC5987(filename))
+}
@@ -0,0 +1,60 @@
+package router
+
+import (
+	"mime"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "plugin.zip", want: "plugin.zip"},
+		{name: "../../etc/passwd", want: "passwd"},
+		{name: `dir\sub\plugin.zip`, want: "plugin.zip"},
+		{name: "plug\x00in\n.zip", want: "plugin.zip"},
+		{name: "  spaced.zip  ", want: "spaced.zip"},
+		{name: "", want: "fallback"},
+		{name: "..", want: "fallback"},
+		{name: "dir/", want: "fallback"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeFilename(tt.name, "fallback"); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+		// wantParsed is the filename a client decoding the header saves under
+		wantParsed string
+	}{
+		{filename: "plugin.zip", want: `attachment; filename="plugin.zip"; filename*=UTF-8''plugin.zip`, wantParsed: "plugin.zip"},
+		{filename: "my plugin.zip", want: `attachment; filename="my plugin.zip"; filename*=UTF-8''my%20plugin.zip`, wantParsed: "my plugin.zip"},
+		{filename: `say "hi".zip`, want: `attachment; filename="say _hi_.zip"; filename*=UTF-8''say%20%22hi%22.zip`, wantParsed: `say "hi".zip`},
+		{filename: "café.zip", want: `attachment; filename="caf_.zip"; filename*=UTF-8''caf%C3%A9.zip`, wantParsed: "café.zip"},
+		{filename: "../secret.zip", want: `attachment; filename="secret.zip"; filename*=UTF-8''secret.zip`, wantParsed: "secret.zip"},
+		{filename: "", want: `attachment; filename="download"; filename*=UTF-8''download`, wantParsed: "download"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			got := contentDisposition("attachment", tt.filename)
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			_, params, err := mime.ParseMediaType(got)
+			if err != nil {
+				t.Fatalf("header doesn't parse: %v", err)
+			}
+			if params["filename"] != tt.wantParsed {
+				t.Errorf("parsed filename %q, want %q", params["filename"], tt.wantParsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// ContentDigestTrailer is the HTTP trailer carrying the digest of a streamed download
+const ContentDigestTrailer = "X-Content-Digest"
+
+// errSlowDownload is returned when a download falls below the minimum throughput
+var errSlowDownload = errors.New("download aborted: client throughput below minimum")
+
+// errDigestMismatch is returned when streamed content doesn't match its expected digest
+var errDigestMismatch = errors.New("content digest mismatch")
+
+// verifyingReader hashes content as it is read and checks it against the
+// expected digest once the underlying reader reaches EOF
+type verifyingReader struct {
+	r        io.Reader
+	expected digest.Digest
+	digester digest.Digester
+}
+
+// newVerifyingReader wraps r so its content is checked against expected. When
+// expected isn't a valid digest the content is still hashed with sha256 but not checked.
+func newVerifyingReader(r io.Reader, expected digest.Digest) *verifyingReader {
+	algorithm := digest.Canonical
+	if expected.Validate() == nil {
+		algorithm = expected.Algorithm()
+	} else {
+		expected = ""
+	}
+	return &verifyingReader{r: r, expected: expected, digester: algorithm.Digester()}
+}
+
+// Read implements io.Reader
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.digester.Hash().Write(p[:n])
+	if err == io.EOF && v.expected != "" && v.digester.Digest() != v.expected {
+		return n, fmt.Errorf("%w: expected %s, got %s", errDigestMismatch, v.expected, v.digester.Digest())
+	}
+	return n, err
+}
+
+// Digest returns the digest of the content read so far
+func (v *verifyingReader) Digest() digest.Digest {
+	return v.digester.Digest()
+}
+
+// acceptsTrailers reports whether the client asked for trailers with "TE: trailers"
+func acceptsTrailers(req *http.Request) bool {
+	for _, value := range req.Header.Values("TE") {
+		for _, part := range strings.Split(value, ",") {
+			name, _, _ := strings.Cut(part, ";")
+			if strings.EqualFold(strings.TrimSpace(name), "trailers") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// throughputWriter wraps a download response, extending the connection write
+// deadline after every write and aborting when the client consistently reads
+// slower than the configured minimum throughput
+type throughputWriter struct {
+	w          io.Writer
+	controller *http.ResponseController
+	window     time.Duration
+	minRate    int64
+
+	windowStart time.Time
+	windowBytes int64
+	noDeadline  bool
+}
+
+// Write implements io.Writer
+func (t *throughputWriter) Write(p []byte) (int, error) {
+	// A write that blocks for longer than the window means the client has stalled
+	if t.window > 0 && !t.noDeadline {
+		if err := t.controller.SetWriteDeadline(time.Now().Add(t.window)); err != nil {
+			// The underlying writer doesn't support deadlines, only throughput checks apply
+			t.noDeadline = true
+		}
+	}
+
+	n, err := t.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+
+	t.windowBytes += int64(n)
+	if t.minRate > 0 && t.window > 0 {
+		elapsed := time.Since(t.windowStart)
+		if elapsed >= t.window {
+			if float64(t.windowBytes)/elapsed.Seconds() < float64(t.minRate) {
+				return n, errSlowDownload
+			}
+			t.windowStart = time.Now()
+			t.windowBytes = 0
+		}
+	}
+	return n, nil
+}
+
+// bufferPool shares fixed-size copy buffers between concurrent downloads
+type bufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+// newBufferPool creates a pool of buffers of the given size in bytes
+func newBufferPool(size int) *bufferPool {
+	p := &bufferPool{size: size}
+	p.pool.New = func() interface{} {
+		buf := make([]byte, p.size)
+		return &buf
+	}
+	return p
+}
+
+// get returns a buffer from the pool
+func (p *bufferPool) get() *[]byte {
+	return p.pool.Get().(*[]byte)
+}
+
+// put returns a buffer to the pool
+func (p *bufferPool) put(buf *[]byte) {
+	p.pool.Put(buf)
+}
+
+// copyDownload streams a download to the response using a pooled buffer, applying
+// stall and minimum throughput protection when configured
+func (m *ApiManager) copyDownload(w http.ResponseWriter, src io.Reader) (int64, error) {
+	// Hide io.ReaderFrom and io.WriterTo so io.CopyBuffer actually uses our buffer
+	// rather than the response writer's own 32 KB one
+	var dst io.Writer = struct{ io.Writer }{w}
+	if m.Settings.DownloadStallTimeout > 0 {
+		dst = &throughputWriter{
+			w:           w,
+			controller:  http.NewResponseController(w),
+			window:      m.Settings.DownloadStallTimeout,
+			minRate:     m.Settings.DownloadMinThroughput,
+			windowStart: time.Now(),
+		}
+	}
+
+	buf := m.downloadBuffers.get()
+	defer m.downloadBuffers.put(buf)
+	return io.CopyBuffer(dst, struct{ io.Reader }{src}, *buf)
+}
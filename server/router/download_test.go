@@ -0,0 +1,67 @@
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// discardResponseWriter is a ResponseWriter that throws the body away, so
+// benchmarks measure the copy rather than buffering the response
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+func TestCopyDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("orashub"), 100_000)
+	tests := []struct {
+		name       string
+		bufferSize int
+	}{
+		{name: "smaller than content", bufferSize: 4 << 10},
+		{name: "larger than content", bufferSize: 4 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := &ApiManager{downloadBuffers: newBufferPool(tt.bufferSize)}
+			recorder := httptest.NewRecorder()
+			n, err := manager.copyDownload(recorder, bytes.NewReader(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != int64(len(content)) || !bytes.Equal(recorder.Body.Bytes(), content) {
+				t.Errorf("copied %d bytes, want the %d bytes of content", n, len(content))
+			}
+		})
+	}
+}
+
+// BenchmarkCopyDownload compares download buffer sizes, including the default of
+// ORASHUB_DOWNLOAD_BUFFER_SIZE, streaming a 64 MiB layer
+func BenchmarkCopyDownload(b *testing.B) {
+	content := bytes.Repeat([]byte{0xa5}, 64<<20)
+	sizes := []int{32 << 10, DefaultApiSettings().DownloadBufferSize, 1 << 20, 4 << 20}
+	for _, size := range slices.Compact(slices.Sorted(slices.Values(sizes))) {
+		b.Run(fmt.Sprintf("buffer=%dKiB", size>>10), func(b *testing.B) {
+			manager := &ApiManager{downloadBuffers: newBufferPool(size)}
+			w := &discardResponseWriter{header: make(http.Header)}
+			reader := bytes.NewReader(content)
+			b.SetBytes(int64(len(content)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for b.Loop() {
+				reader.Reset(content)
+				if _, err := manager.copyDownload(w, reader); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
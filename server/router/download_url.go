@@ -0,0 +1,153 @@
+package router
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codekaizen-github/orashub/client"
+	"github.com/codekaizen-github/orashub/server/policy"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// defaultDownloadURLExpiry is how long a signed download URL stays valid when the
+// registry's download_url doesn't set expires
+const defaultDownloadURLExpiry = 15 * time.Minute
+
+// DownloadBlob describes the layer a download would stream, for a DownloadURLResolver
+type DownloadBlob struct {
+	// Registry is the configured registry name, with aliases resolved
+	Registry string
+	// Repository is the namespaced repository, e.g. "codekaizen-github/my-plugin"
+	Repository string
+	Digest     digest.Digest
+	MediaType  string
+	Size       int64
+	// Filename is the name the download would be served under
+	Filename string
+}
+
+// DownloadURLResolver offloads downloads to object storage or a CDN holding a copy
+// of the blob. When it returns a URL the download endpoint answers 302 Found with
+// it instead of streaming the layer.
+//
+// ResolveDownloadURL is called once per download, after the access policies have
+// allowed it, so the URL must not need further authorization by ORASHub; use a
+// time-limited signed URL for private content. It returns "" for blobs that aren't
+// mirrored, which are streamed as usual, as are blobs it returns an error for, so a
+// mirror outage never fails a download. The URL must serve exactly the blob's bytes:
+// ORASHub can't verify content it doesn't stream. It must be safe for concurrent
+// use and shouldn't fetch the blob; ctx ends with the request.
+type DownloadURLResolver interface {
+	ResolveDownloadURL(ctx context.Context, blob DownloadBlob) (string, error)
+}
+
+// registryDownloadURLs is the default DownloadURLResolver, filling in the
+// download_url template of the blob's registry. Registries without one are streamed.
+type registryDownloadURLs struct {
+	manager *ApiManager
+}
+
+// ResolveDownloadURL implements DownloadURLResolver
+func (r registryDownloadURLs) ResolveDownloadURL(ctx context.Context, blob DownloadBlob) (string, error) {
+	download := r.manager.registries().credentials[blob.Registry].DownloadURL
+	if download == nil || blob.Size < download.MinSize {
+		return "", nil
+	}
+	raw := strings.NewReplacer(
+		"{registry}", blob.Registry,
+		"{repository}", blob.Repository,
+		"{digest}", blob.Digest.String(),
+		"{algorithm}", blob.Digest.Algorithm().String(),
+		"{encoded}", blob.Digest.Encoded(),
+		"{filename}", url.PathEscape(blob.Filename),
+	).Replace(download.URL)
+	if download.SigningKey == "" {
+		return raw, nil
+	}
+	return signDownloadURL(raw, download, time.Now())
+}
+
+// signDownloadURL adds expires and signature query parameters to raw. The signature
+// is the hex HMAC-SHA256, keyed by the signing key, of the escaped URL path and
+// the expiry Unix time joined by a newline.
+func signDownloadURL(raw string, download *policy.DownloadURL, now time.Time) (string, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid download URL: %w", err)
+	}
+	expiry := download.Expires
+	if expiry == 0 {
+		expiry = defaultDownloadURLExpiry
+	}
+	expires := strconv.FormatInt(now.Add(expiry).Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(download.SigningKey))
+	mac.Write([]byte(parsed.EscapedPath() + "\n" + expires))
+
+	query := parsed.Query()
+	query.Set("expires", expires)
+	query.Set("signature", hex.EncodeToString(mac.Sum(nil)))
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// redirectsDownloads reports whether downloads from registry may be redirected,
+// sparing the manifest fetch when the default resolver has no URL for it
+func (m *ApiManager) redirectsDownloads(registry string) bool {
+	switch m.DownloadURLResolver.(type) {
+	case nil:
+		return false
+	case registryDownloadURLs:
+		set := m.registries()
+		return set.credentials[set.resolve(registry)].DownloadURL != nil
+	}
+	return true
+}
+
+// redirectDownload redirects to the download URL of the layer selector picks from
+// manifest, reporting whether it did. Layers that can't be picked or have no URL are
+// left to the streaming path, which reports any error as usual.
+func (m *ApiManager) redirectDownload(w http.ResponseWriter, req *http.Request, registry, repository string, manifest []byte, selector client.LayerSelector, filename func(string) string) bool {
+	parsed, err := client.ParseManifest(manifest)
+	if err != nil {
+		return false
+	}
+	layer, err := parsed.SelectLayer(selector)
+	if err != nil {
+		return false
+	}
+
+	// Named like the streamed download would be
+	title := "plugin.zip"
+	if annotated := layer.Annotations[v1.AnnotationTitle]; annotated != "" {
+		title = annotated
+	}
+	blob := DownloadBlob{
+		Registry:   m.registries().resolve(registry),
+		Repository: repository,
+		Digest:     layer.Digest,
+		MediaType:  layer.MediaType,
+		Size:       layer.Size,
+		Filename:   filename(title),
+	}
+	location, err := m.DownloadURLResolver.ResolveDownloadURL(req.Context(), blob)
+	if err != nil {
+		m.Logger.Warn("Error resolving the download URL of %s@%s, streaming it instead: %v", repository, layer.Digest, err)
+		return false
+	}
+	if location == "" {
+		return false
+	}
+	m.Logger.Debug("Redirecting download of %s@%s to its download URL", repository, layer.Digest)
+	http.Redirect(w, req, location, http.StatusFound)
+	return true
+}
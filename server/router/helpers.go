@@ -1,15 +1,38 @@
 package router
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+
+	"github.com/codekaizen-github/orashub/client"
+	"github.com/codekaizen-github/orashub/server/logger"
+	"github.com/opencontainers/go-digest"
+	"oras.land/oras-go/v2/errdef"
 )
 
 // getPathValues extracts all path variables from a request based on a route pattern
 func getPathValues(req *http.Request, pattern string) map[string]string {
 	result := make(map[string]string)
 
+	for _, varName := range patternVariables(pattern) {
+		// Get the value from the request
+		value := req.PathValue(varName)
+		if value != "" {
+			result[varName] = value
+		}
+	}
+
+	return result
+}
+
+// patternVariables returns the names of the path variables in a route pattern, in order
+func patternVariables(pattern string) []string {
+	var names []string
+
 	// Extract variable names from the pattern
 	parts := strings.Split(pattern, "/")
 	for _, part := range parts {
@@ -23,15 +46,63 @@ func getPathValues(req *http.Request, pattern string) map[string]string {
 				continue
 			}
 
-			// Get the value from the request
-			value := req.PathValue(varName)
-			if value != "" {
-				result[varName] = value
-			}
+			// Multi-segment wildcards like {path...} are named without the dots
+			names = append(names, strings.TrimSuffix(varName, "..."))
 		}
 	}
 
-	return result
+	return names
+}
+
+// requirePathValues extracts all path variables for the matched route and checks
+// that none of them are empty, which can happen with malformed URLs such as
+// /api/v1/ghcr.io/ns/repo//download/. When a value is missing it writes a 400
+// naming the field and returns false.
+func requirePathValues(w http.ResponseWriter, req *http.Request) (map[string]string, bool) {
+	pathValues := getPathValues(req, req.Pattern)
+	for _, name := range patternVariables(req.Pattern) {
+		if pathValues[name] == "" {
+			http.Error(w, fmt.Sprintf("missing required path value: %s", name), http.StatusBadRequest)
+			return nil, false
+		}
+	}
+
+	// Attach the parsed values to the access log entry for analytics
+	logger.AddRequestFields(req.Context(), pathValues)
+	return pathValues, true
+}
+
+// serverInfo describes how the server was reached by the current request
+type serverInfo struct {
+	Scheme string
+	Host   string
+	ApiURL string
+}
+
+// getServerInfo builds the externally visible base URLs for the current request,
+// honouring X-Forwarded-Proto and X-Forwarded-Host when the request came from a
+// trusted reverse proxy
+func getServerInfo(req *http.Request, proxies TrustedProxies) serverInfo {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	host := req.Host
+
+	if proxies.trusts(req) {
+		if proto := req.Header.Get("X-Forwarded-Proto"); proto != "" {
+			scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+		}
+		if forwardedHost := req.Header.Get("X-Forwarded-Host"); forwardedHost != "" {
+			host = strings.TrimSpace(strings.Split(forwardedHost, ",")[0])
+		}
+	}
+
+	return serverInfo{
+		Scheme: scheme,
+		Host:   host,
+		ApiURL: fmt.Sprintf("%s://%s/api/v1/", scheme, host),
+	}
 }
 
 // cleanPatternString removes methods and trailing {$} from a pattern string
@@ -47,6 +118,8 @@ func cleanPatternString(pattern string) string {
 	clean := strings.TrimSuffix(pattern, "/{$}")
 	// Remove trailing {$} without slash
 	clean = strings.TrimSuffix(clean, "{$}")
+	// Show multi-segment wildcards like {path...} as plain placeholders
+	clean = strings.ReplaceAll(clean, "...}", "}")
 	return clean
 }
 
@@ -58,3 +131,173 @@ func interpolatePattern(pattern string, params map[string]string) string {
 	}
 	return result
 }
+
+// registryErrorStatus returns the HTTP status for an error from a client call to
+// the registry
+func registryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, client.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, client.ErrUnsupportedManifestType), errors.Is(err, client.ErrArtifactTooLarge), errors.Is(err, client.ErrIndexTooDeep), errors.Is(err, errdef.ErrSizeExceedsLimit):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, client.ErrManifestNotFound), errors.Is(err, client.ErrLayerNotFound), errors.Is(err, client.ErrBlobNotFound), errors.Is(err, client.ErrRepositoryNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, client.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, client.ErrRegistryUnreachable):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeRegistryError writes the HTTP error for an error from a client call to the
+// registry. While the registry's Retry-After window is in effect the 429 carries
+// the time remaining, so callers back off instead of retrying at once.
+func writeRegistryError(w http.ResponseWriter, err error) {
+	var rateLimit *client.RateLimitError
+	if errors.As(err, &rateLimit) {
+		w.Header().Set("Retry-After", strconv.Itoa(rateLimit.RetryAfterSeconds()))
+	}
+	http.Error(w, err.Error(), registryErrorStatus(err))
+}
+
+// setContentLength sets Content-Length from a layer or blob size. Some registries
+// report a size of 0 for content they send chunked, so without a positive size the
+// header is left out and the response goes out chunked rather than truncated.
+func setContentLength(w http.ResponseWriter, size int64) {
+	if size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+}
+
+// writeClientError writes the HTTP error matching an error returned by getClient or getRequestClient
+func writeClientError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrRegistryNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrNoRegistryClients), errors.Is(err, ErrRegistryUnavailable):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, ErrInvalidCredentials):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// preferredMediaType picks the offer that best matches the request Accept header,
+// honoring q-values and wildcards. Ties are broken by the order of offers.
+// Returns an empty string when the header is empty or none of the offers are acceptable.
+func preferredMediaType(accept string, offers []string) string {
+	best := ""
+	bestQuality := 0.0
+	bestSpecificity := -1
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+		rangeType := strings.ToLower(strings.TrimSpace(params[0]))
+		if rangeType == "" {
+			continue
+		}
+
+		// Read the quality factor, defaulting to 1
+		quality := 1.0
+		for _, param := range params[1:] {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.EqualFold(strings.TrimSpace(key), "q") {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		for _, offer := range offers {
+			specificity := mediaRangeSpecificity(rangeType, strings.ToLower(offer))
+			if specificity < 0 {
+				continue
+			}
+			// Prefer higher quality, then more specific ranges, then earlier offers
+			if quality > bestQuality || (quality == bestQuality && specificity > bestSpecificity) {
+				best = offer
+				bestQuality = quality
+				bestSpecificity = specificity
+			}
+			break
+		}
+	}
+
+	return best
+}
+
+// mediaRangeSpecificity reports how specifically a media range matches a media type:
+// 2 for an exact match, 1 for a subtype wildcard, 0 for */*, and -1 for no match
+func mediaRangeSpecificity(mediaRange, mediaType string) int {
+	if mediaRange == mediaType {
+		return 2
+	}
+	if mediaRange == "*/*" {
+		return 0
+	}
+	if prefix, ok := strings.CutSuffix(mediaRange, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+		return 1
+	}
+	return -1
+}
+
+// isDigestReference reports whether a reference is a content digest (e.g. "sha256:...")
+// rather than a mutable tag
+func isDigestReference(reference string) bool {
+	_, err := digest.Parse(reference)
+	return err == nil
+}
+
+// expectedDigests returns the manifest digests a request is conditional on, taken from
+// If-Match (entity tags, quoted or not) and the expect_digest query parameter
+func expectedDigests(req *http.Request) []string {
+	var expected []string
+	for _, value := range req.Header.Values("If-Match") {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			// Weak tags never match under the strong comparison If-Match requires
+			if tag == "" || strings.HasPrefix(tag, "W/") {
+				continue
+			}
+			expected = append(expected, strings.Trim(tag, `"`))
+		}
+	}
+	if value := req.URL.Query().Get("expect_digest"); value != "" {
+		expected = append(expected, value)
+	}
+	return expected
+}
+
+// digestMatches reports whether actual is one of the expected digests, where "*" matches any
+func digestMatches(expected []string, actual string) bool {
+	for _, candidate := range expected {
+		if candidate == "*" || candidate == actual {
+			return true
+		}
+	}
+	return false
+}
+
+// downloadLayerSelector reads the layer to download from the mediaType and layer
+// (zero-based index) query parameters. mediaType takes precedence over layer, and
+// without either the first layer is selected.
+func downloadLayerSelector(req *http.Request) (client.LayerSelector, error) {
+	query := req.URL.Query()
+	selector := client.LayerSelector{MediaType: query.Get("mediaType")}
+	if value := query.Get("layer"); value != "" {
+		index, err := strconv.Atoi(value)
+		if err != nil || index < 0 {
+			return selector, fmt.Errorf("invalid layer index %q", value)
+		}
+		selector.Index = index
+	}
+	return selector, nil
+}
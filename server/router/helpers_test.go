@@ -0,0 +1,118 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/client"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestPatternVariables(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{pattern: "/api/v1/{$}"},
+		{pattern: "/api/v1/{registry}/_catalog/{$}", want: []string{"registry"}},
+		{pattern: "GET /api/v1/{registry}/{namespace}/{repository}/{tag}/download/{$}", want: []string{"registry", "namespace", "repository", "tag"}},
+		{pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/file/{path...}", want: []string{"registry", "namespace", "repository", "tag", "path"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if got := patternVariables(tt.pattern); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequirePathValues(t *testing.T) {
+	const pattern = "/api/v1/{registry}/{namespace}/{repository}/{tag}/download/{$}"
+
+	tests := []struct {
+		name        string
+		values      map[string]string
+		wantMissing string
+	}{
+		{name: "all present", values: map[string]string{"registry": "ghcr.io", "namespace": "team", "repository": "app", "tag": "1.0.0"}},
+		{name: "empty tag", values: map[string]string{"registry": "ghcr.io", "namespace": "team", "repository": "app"}, wantMissing: "tag"},
+		{name: "empty namespace", values: map[string]string{"registry": "ghcr.io", "repository": "app", "tag": "1.0.0"}, wantMissing: "namespace"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Pattern = pattern
+			for name, value := range tt.values {
+				req.SetPathValue(name, value)
+			}
+			recorder := httptest.NewRecorder()
+
+			pathValues, ok := requirePathValues(recorder, req)
+			if tt.wantMissing == "" {
+				if !ok {
+					t.Fatalf("rejected with %d: %s", recorder.Code, recorder.Body)
+				}
+				if !reflect.DeepEqual(pathValues, tt.values) {
+					t.Errorf("got %v, want %v", pathValues, tt.values)
+				}
+				return
+			}
+			if ok {
+				t.Fatal("expected the request to be rejected")
+			}
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("got status %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+			if !strings.Contains(recorder.Body.String(), tt.wantMissing) {
+				t.Errorf("error %q doesn't name %s", recorder.Body, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestRegistryErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "unsupported manifest type", err: fmt.Errorf("%w %s", client.ErrUnsupportedManifestType, v1.MediaTypeImageIndex), want: http.StatusUnprocessableEntity},
+		{name: "layer not found", err: client.ErrLayerNotFound, want: http.StatusNotFound},
+		{name: "manifest not found", err: errFakeNotFound, want: http.StatusNotFound},
+		{name: "other", err: errors.New("unexpected"), want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := registryErrorStatus(tt.err); got != tt.want {
+				t.Errorf("got status %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetContentLength(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{size: 1024, want: "1024"},
+		// Registries reporting 0 for chunked content get a chunked response
+		{size: 0},
+		{size: -1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.size), func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			setContentLength(recorder, tt.size)
+			if got := recorder.Header().Get("Content-Length"); got != tt.want {
+				t.Errorf("Content-Length = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+
+	"github.com/codekaizen-github/orashub/server/assets"
+)
+
+// defaultTemplates returns the templates embedded in the binary. They are fixed at
+// build time, so a parse failure is a programming error rather than a runtime one.
+var defaultTemplates = sync.OnceValue(func() *template.Template {
+	return template.Must(template.ParseFS(assets.Templates(), "*.html"))
+})
+
+// tagLink is a tag and the URL of its resource page, with its plugin version and
+// tested-up-to values when the listing is expanded
+type tagLink struct {
+	Name    string
+	URL     string
+	Version string
+	Tested  string
+	Error   string
+}
+
+// tagsPageData is the data passed to the tags.html template
+type tagsPageData struct {
+	Title      string
+	Registry   string
+	Repository string
+	Tags       []tagLink
+	// Expanded is set when the tags carry plugin metadata (?expand=metadata)
+	Expanded bool
+}
+
+// resourcePageData is the data passed to the resource.html template
+type resourcePageData struct {
+	Title         string
+	Registry      string
+	Resource      string
+	RepositoryURL string
+	// ArtifactType is the artifact's artifactType, or its config media type without one
+	ArtifactType string
+	Endpoints    map[string]string
+}
+
+// wantsHTML reports whether the client prefers an HTML page over JSON, which is
+// the case for browsers. JSON stays the default for any other Accept header.
+func wantsHTML(req *http.Request) bool {
+	return preferredMediaType(req.Header.Get("Accept"), []string{"application/json", "text/html"}) == "text/html"
+}
+
+// renderTemplate executes the named template and writes it as an HTML response.
+// The template is rendered into a buffer first so a failure can still be reported
+// with a proper error status. When a custom template is missing or fails to
+// execute, the embedded default is rendered instead.
+func (m *ApiManager) renderTemplate(w http.ResponseWriter, name string, data interface{}) {
+	var buf bytes.Buffer
+	if err := executeTemplate(&buf, m.Templates, name, data); err != nil {
+		m.Logger.Error("Error executing template %s, falling back to the embedded default: %v", name, err)
+		buf.Reset()
+		if err := executeTemplate(&buf, defaultTemplates(), name, data); err != nil {
+			m.Logger.Error("Error executing embedded template %s: %v", name, err)
+			// Caching set up for the page mustn't apply to the error
+			w.Header().Del("Cache-Control")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := buf.WriteTo(w); err != nil {
+		m.Logger.Error("Error writing template %s: %v", name, err)
+	}
+}
+
+// executeTemplate renders the named template from templates into buf
+func executeTemplate(buf *bytes.Buffer, templates *template.Template, name string, data interface{}) error {
+	if templates == nil || templates.Lookup(name) == nil {
+		return fmt.Errorf("template %s is not available", name)
+	}
+	return templates.ExecuteTemplate(buf, name, data)
+}
@@ -0,0 +1,63 @@
+package router
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates string
+		page      string
+		want      int
+		// wantBody is a fragment the page must contain, and wantAbsent one it mustn't
+		wantBody   string
+		wantAbsent string
+	}{
+		{name: "embedded default", page: "index.html", want: http.StatusOK, wantBody: "/api/v1"},
+		{name: "custom template", templates: `{{define "index.html"}}custom root {{.ApiURL}}{{end}}`, page: "index.html", want: http.StatusOK, wantBody: "custom root /api/v1"},
+		{name: "failing custom template falls back", templates: `{{define "index.html"}}partial {{.Missing}}{{end}}`, page: "index.html", want: http.StatusOK, wantBody: "/api/v1", wantAbsent: "partial"},
+		{name: "missing custom template falls back", templates: `{{define "other.html"}}other{{end}}`, page: "index.html", want: http.StatusOK, wantBody: "/api/v1"},
+		{name: "no template anywhere", page: "missing.html", want: http.StatusInternalServerError, wantBody: "Internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := newTestManager(t, &policy.ConfigFile{}, DefaultApiSettings(), nil)
+			if tt.templates != "" {
+				manager.Templates = template.Must(template.New("custom").Parse(tt.templates))
+			}
+
+			recorder := httptest.NewRecorder()
+			manager.renderTemplate(recorder, tt.page, struct{ ApiURL string }{ApiURL: "/api/v1"})
+			if recorder.Code != tt.want {
+				t.Fatalf("got status %d, want %d", recorder.Code, tt.want)
+			}
+			body := recorder.Body.String()
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body doesn't contain %q: %s", tt.wantBody, body)
+			}
+			if tt.wantAbsent != "" && strings.Contains(body, tt.wantAbsent) {
+				t.Errorf("body contains %q from the failed render", tt.wantAbsent)
+			}
+		})
+	}
+}
+
+func TestHandleRootFailingTemplate(t *testing.T) {
+	manager := newTestManager(t, &policy.ConfigFile{}, DefaultApiSettings(), newFakeClient())
+	manager.Templates = template.Must(template.New("custom").Parse(`{{define "index.html"}}partial {{.Missing}}{{end}}`))
+
+	recorder := serve(manager, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", recorder.Code, http.StatusOK)
+	}
+	if strings.Contains(recorder.Body.String(), "partial") {
+		t.Error("root page contains output from the failed render")
+	}
+}
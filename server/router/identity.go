@@ -0,0 +1,78 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+)
+
+// identityKey is the context key for the API token a request authenticated with
+type identityKey struct{}
+
+// requestToken returns the API token the request authenticated with, or nil for
+// anonymous callers
+func requestToken(ctx context.Context) *policy.APIToken {
+	token, _ := ctx.Value(identityKey{}).(*policy.APIToken)
+	return token
+}
+
+// apiTokensEnabled reports whether api_tokens is configured
+func (m *ApiManager) apiTokensEnabled() bool {
+	return m.ImagePolicy != nil && len(m.ImagePolicy.APITokens) > 0
+}
+
+// withIdentity authenticates callers sending "Authorization: Bearer <token>" when
+// api_tokens is configured, storing the token in the request context for the
+// policy checks. Unknown tokens are rejected; requests without one, or with the
+// admin token that admin routes check themselves, carry on as anonymous callers.
+func (m *ApiManager) withIdentity(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !m.apiTokensEnabled() {
+			handler(w, req)
+			return
+		}
+
+		header := req.Header.Get("Authorization")
+		if header == "" {
+			handler(w, req)
+			return
+		}
+		value, ok := strings.CutPrefix(header, "Bearer ")
+		if ok && m.isAdminToken(value) {
+			handler(w, req)
+			return
+		}
+		token, known := m.ImagePolicy.Authenticate(strings.TrimSpace(value))
+		if !ok || !known {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="orashub"`)
+			http.Error(w, "invalid API token", http.StatusUnauthorized)
+			return
+		}
+		m.Logger.Debug("Request authenticated as %s", token.Name)
+		handler(w, req.WithContext(context.WithValue(req.Context(), identityKey{}, token)))
+	}
+}
+
+// checkIdentityPolicy rejects requests for repositories outside what the caller's
+// API token, or public_repositories for anonymous callers, grants. Anonymous
+// callers are asked for a token rather than told the repository is forbidden.
+func (m *ApiManager) checkIdentityPolicy(w http.ResponseWriter, req *http.Request, repositoryPath string) bool {
+	if !m.apiTokensEnabled() {
+		return true
+	}
+	token := requestToken(req.Context())
+	if policy.IdentityAllows(repositoryPath, token, m.ImagePolicy) {
+		return true
+	}
+	if token == nil {
+		m.Logger.Warn("Access denied to repository %s for anonymous caller", repositoryPath)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="orashub"`)
+		http.Error(w, "an API token is required for this repository", http.StatusUnauthorized)
+		return false
+	}
+	m.Logger.Warn("Access denied to repository %s for API token %s", repositoryPath, token.Name)
+	http.Error(w, "Access to this repository is denied by policy", http.StatusForbidden)
+	return false
+}
@@ -0,0 +1,84 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestWithIdentityAdminToken(t *testing.T) {
+	config := &policy.ConfigFile{
+		APITokens: []policy.APIToken{{Name: "ci", Token: "ci-token", AllowedRepositories: []string{testRegistry + "/team/*"}}},
+	}
+	settings := DefaultApiSettings()
+	settings.AdminToken = "admin-token"
+	manager := newTestManager(t, config, settings, newFakeClient())
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{name: "admin token", authorization: "Bearer admin-token", want: http.StatusOK},
+		{name: "API token", authorization: "Bearer ci-token", want: http.StatusUnauthorized},
+		{name: "unknown token", authorization: "Bearer other", want: http.StatusUnauthorized},
+		{name: "no token", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/"+testRegistry+"/_debug/v2/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if got := serve(manager, req).Code; got != tt.want {
+				t.Errorf("got status %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithIdentityScopes(t *testing.T) {
+	fake := newFakeClient()
+	fake.addManifest("team/app", v1.Manifest{}, "1.0.0")
+	fake.addManifest("public/app", v1.Manifest{}, "1.0.0")
+	config := &policy.ConfigFile{
+		APITokens: []policy.APIToken{
+			{Name: "team", Token: "team-token", AllowedRepositories: []string{testRegistry + "/team/*"}},
+			{Name: "other", Token: "other-token", AllowedRepositories: []string{testRegistry + "/other/*"}},
+		},
+		PublicRepositories: []string{testRegistry + "/public/*"},
+	}
+	manager := newTestManager(t, config, DefaultApiSettings(), fake)
+
+	tests := []struct {
+		name          string
+		repository    string
+		authorization string
+		want          int
+	}{
+		{name: "token in scope", repository: "team/app", authorization: "Bearer team-token", want: http.StatusOK},
+		{name: "token out of scope", repository: "team/app", authorization: "Bearer other-token", want: http.StatusForbidden},
+		{name: "anonymous outside public", repository: "team/app", want: http.StatusUnauthorized},
+		{name: "anonymous public", repository: "public/app", want: http.StatusOK},
+		{name: "unknown token", repository: "public/app", authorization: "Bearer unknown", want: http.StatusUnauthorized},
+		{name: "not a bearer token", repository: "public/app", authorization: "Basic dXNlcjpwYXNz", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/"+testRegistry+"/"+tt.repository+"/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			resp := serve(manager, req)
+			if resp.Code != tt.want {
+				t.Errorf("got status %d, want %d: %s", resp.Code, tt.want, resp.Body)
+			}
+			if resp.Code == http.StatusUnauthorized && resp.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
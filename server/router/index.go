@@ -0,0 +1,163 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/codekaizen-github/orashub/client"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// annotationFilterPrefix marks an index query parameter as a child annotation filter
+const annotationFilterPrefix = "annotation."
+
+// indexManifest is a child manifest of an index, with the URL of its resource page
+type indexManifest struct {
+	v1.Descriptor
+	URL string `json:"url"`
+}
+
+// indexResponse is the response body of the index endpoint
+type indexResponse struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Manifests   []indexManifest   `json:"manifests"`
+}
+
+// isIndexMediaType reports whether mediaType is an OCI image index or a Docker
+// manifest list
+func isIndexMediaType(mediaType string) bool {
+	return mediaType == v1.MediaTypeImageIndex || mediaType == client.MediaTypeDockerManifestList
+}
+
+// indexFilters returns the ?annotation.{key}={value} filters of an index request
+func indexFilters(query url.Values) (map[string]string, error) {
+	filters := make(map[string]string)
+	for key, values := range query {
+		annotation, ok := strings.CutPrefix(key, annotationFilterPrefix)
+		if !ok {
+			continue
+		}
+		if annotation == "" {
+			return nil, fmt.Errorf("annotation filter %s needs an annotation key, e.g. annotation.org.opencontainers.image.title", key)
+		}
+		filters[annotation] = values[0]
+	}
+	return filters, nil
+}
+
+// matchesIndexFilters reports whether a child manifest has every filtered annotation
+// and, when platform is set, runs on that os/architecture[/variant]
+func matchesIndexFilters(desc v1.Descriptor, filters map[string]string, platform string) bool {
+	for key, want := range filters {
+		if value, ok := desc.Annotations[key]; !ok || value != want {
+			return false
+		}
+	}
+	if platform == "" {
+		return true
+	}
+	if desc.Platform == nil {
+		return false
+	}
+	name := desc.Platform.OS + "/" + desc.Platform.Architecture
+	if desc.Platform.Variant != "" && strings.Count(platform, "/") == 2 {
+		name += "/" + desc.Platform.Variant
+	}
+	return name == platform
+}
+
+// HandleIndex lists the child manifests of an OCI image index or Docker manifest
+// list with their descriptors, platforms and annotations, each linked to its own
+// resource page by digest. ?annotation.{key}={value} and ?platform=os/arch[/variant]
+// narrow the list so a consumer can pick a child.
+func (m *ApiManager) HandleIndex(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+	namespace := pathValues["namespace"]
+	repository := pathValues["repository"]
+	tag := pathValues["tag"]
+
+	filters, err := indexFilters(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	platform := req.URL.Query().Get("platform")
+
+	// Get client
+	apiClient, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	// Check policy
+	if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+		return
+	}
+
+	// Build namespaced repository
+	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+
+	// Get the index, which the annotation policy is checked against
+	desc, content, err := apiClient.FetchManifest(namespacedRepository, tag)
+	if err != nil {
+		m.Logger.Error("Error getting manifest for %s/%s:%s: %v", namespace, repository, tag, err)
+		writeRegistryError(w, err)
+		return
+	}
+	if err := m.manifestAnnotationsAllowed(content); err != nil {
+		m.writeAnnotationDenied(w, namespacedRepository, tag, err)
+		return
+	}
+
+	// Docker manifest lists decode into the OCI index type, as their fields match
+	var index v1.Index
+	if err := json.Unmarshal(content, &index); err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse index: %v", err), http.StatusInternalServerError)
+		return
+	}
+	mediaType := desc.MediaType
+	if mediaType == "" {
+		mediaType = index.MediaType
+	}
+	if !isIndexMediaType(mediaType) {
+		http.Error(w, fmt.Sprintf("%s is not an index (%v %s)", tag, client.ErrUnsupportedManifestType, mediaType), http.StatusUnprocessableEntity)
+		return
+	}
+
+	repositoryURL := interpolatePattern("/api/v1/{registry}/{namespace}/{repository}/", pathValues)
+	manifests := make([]indexManifest, 0, len(index.Manifests))
+	for _, child := range index.Manifests {
+		if !matchesIndexFilters(child, filters, platform) {
+			continue
+		}
+		manifests = append(manifests, indexManifest{Descriptor: child, URL: repositoryURL + child.Digest.String() + "/"})
+	}
+
+	response := indexResponse{
+		MediaType:   mediaType,
+		Digest:      desc.Digest.String(),
+		Size:        desc.Size,
+		Annotations: index.Annotations,
+		Manifests:   manifests,
+	}
+
+	// Return response
+	m.setCacheControl(w, tag)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		m.Logger.Error("Error encoding index response: %v", err)
+	}
+}
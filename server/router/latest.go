@@ -0,0 +1,263 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/codekaizen-github/orashub/client"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// latestTag is the tag consumers take to mean the newest release, though on an OCI
+// registry it is only a tag like any other
+const latestTag = "latest"
+
+// Modes for resolving a missing latest tag
+const (
+	// LatestFallbackSemver serves the highest semver release tag
+	LatestFallbackSemver = "semver"
+	// LatestFallbackStable serves the stable version declared in the plugin metadata
+	// of the highest semver release tag, or that tag itself when it declares none
+	LatestFallbackStable = "stable"
+)
+
+// ParseLatestFallback checks an ORASHUB_LATEST_FALLBACK value. Empty disables the
+// fallback.
+func ParseLatestFallback(value string) (string, error) {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "", LatestFallbackSemver, LatestFallbackStable:
+		return value, nil
+	}
+	return "", fmt.Errorf("unknown latest fallback '%s', expected '%s' or '%s'", value, LatestFallbackSemver, LatestFallbackStable)
+}
+
+// semanticVersion is a parsed MAJOR.MINOR.PATCH tag with an optional "v" prefix,
+// pre-release and build metadata
+type semanticVersion struct {
+	core       [3]uint64
+	prerelease []string
+}
+
+// parseSemanticVersion parses tag as a semantic version, reporting false for tags
+// that aren't one
+func parseSemanticVersion(tag string) (semanticVersion, bool) {
+	var version semanticVersion
+	rest := strings.TrimPrefix(tag, "v")
+	rest, _, _ = strings.Cut(rest, "+")
+	rest, prerelease, hasPrerelease := strings.Cut(rest, "-")
+
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return version, false
+	}
+	for i, part := range parts {
+		number, err := strconv.ParseUint(part, 10, 64)
+		if err != nil || (len(part) > 1 && part[0] == '0') {
+			return version, false
+		}
+		version.core[i] = number
+	}
+	if hasPrerelease {
+		version.prerelease = strings.Split(prerelease, ".")
+		if slices.Contains(version.prerelease, "") {
+			return version, false
+		}
+	}
+	return version, true
+}
+
+// compare orders versions by semver precedence, returning -1, 0 or 1
+func (v semanticVersion) compare(other semanticVersion) int {
+	for i := range v.core {
+		if v.core[i] != other.core[i] {
+			if v.core[i] < other.core[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	// A release outranks its pre-releases
+	switch {
+	case len(v.prerelease) == 0 && len(other.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(other.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(other.prerelease); i++ {
+		a, b := v.prerelease[i], other.prerelease[i]
+		if a == b {
+			continue
+		}
+		aNumber, aErr := strconv.ParseUint(a, 10, 64)
+		bNumber, bErr := strconv.ParseUint(b, 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNumber < bNumber {
+				return -1
+			}
+			return 1
+		case aErr == nil:
+			// Numeric identifiers rank below alphanumeric ones
+			return -1
+		case bErr == nil:
+			return 1
+		case a < b:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch {
+	case len(v.prerelease) < len(other.prerelease):
+		return -1
+	case len(v.prerelease) > len(other.prerelease):
+		return 1
+	}
+	return 0
+}
+
+// highestRelease returns the tag with the highest semantic version, leaving out
+// pre-releases and tags that aren't versions
+func highestRelease(tags []string) (string, bool) {
+	var highest string
+	var highestVersion semanticVersion
+	for _, tag := range tags {
+		version, ok := parseSemanticVersion(tag)
+		if !ok || len(version.prerelease) > 0 {
+			continue
+		}
+		if highest == "" || version.compare(highestVersion) > 0 {
+			highest, highestVersion = tag, version
+		}
+	}
+	return highest, highest != ""
+}
+
+// withLatestFallback serves requests for a latest tag the repository doesn't have
+// from the tag ORASHUB_LATEST_FALLBACK resolves it to, which replaces the tag path
+// value and is reported in ResolvedTagHeader. A real latest tag is always served
+// as is, though checking for it costs a manifest HEAD request on every request for
+// latest. Without a fallback mode the handler is returned unchanged.
+func (m *ApiManager) withLatestFallback(handler http.HandlerFunc) http.HandlerFunc {
+	if m.Settings.LatestFallback == "" {
+		return handler
+	}
+	return func(w http.ResponseWriter, req *http.Request) {
+		// Tags ?tag_fallback= already resolved exist
+		if req.PathValue("tag") != latestTag || w.Header().Get(ResolvedTagHeader) != "" {
+			handler(w, req)
+			return
+		}
+		registry := req.PathValue("registry")
+		namespace := req.PathValue("namespace")
+		repository := req.PathValue("repository")
+
+		// Get client
+		apiClient, err := m.getRequestClient(req, registry)
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
+
+		// Check policy before revealing which tags exist
+		if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+			return
+		}
+
+		namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+		_, err = apiClient.ResolveDescriptor(namespacedRepository, latestTag)
+		if err == nil {
+			handler(w, req)
+			return
+		}
+		if !errors.Is(err, errdef.ErrNotFound) {
+			m.Logger.Error("Error resolving %s:%s: %v", namespacedRepository, latestTag, err)
+			writeRegistryError(w, err)
+			return
+		}
+
+		resolved, ok := m.resolveLatest(w, req, apiClient, namespacedRepository)
+		if !ok {
+			return
+		}
+		if resolved == "" {
+			http.Error(w, fmt.Sprintf("tag %s not found in %s, and it has no release tags to serve instead", latestTag, namespacedRepository), http.StatusNotFound)
+			return
+		}
+		m.Logger.Debug("Tag %s:%s not found, serving %s", namespacedRepository, latestTag, resolved)
+		req.SetPathValue("tag", resolved)
+		w.Header().Set(ResolvedTagHeader, resolved)
+		handler(w, req)
+	}
+}
+
+// resolveLatest returns the tag a missing latest tag stands for in repository, or
+// "" when it has no release tags. The tags come from the tag cache, like the tag
+// listing. In stable mode a stable version declared by the highest release is used
+// when the repository has a tag for it; metadata that can't be read falls back to
+// the highest release. It writes the error response and returns false when the
+// tags can't be listed or the highest release is denied by required_annotations.
+func (m *ApiManager) resolveLatest(w http.ResponseWriter, req *http.Request, apiClient client.ClientInterface, repository string) (string, bool) {
+	// Listings made with per-request credentials are never cached
+	cacheKey := fmt.Sprintf("%s/%s", apiClient.GetRegistry(), repository)
+	cacheTTL := m.tagCacheTTL(apiClient.GetRegistry())
+	useCache := cacheTTL > 0 && req.Header.Get(CredentialOverrideHeader) == ""
+	var tags []string
+	hit := false
+	if useCache {
+		tags, hit = m.tagCache.GetWithTTL(cacheKey, cacheTTL)
+	}
+	if !hit {
+		var err error
+		tags, err = apiClient.ListTags(repository, "")
+		if err != nil {
+			m.Logger.Error("Error listing tags of %s to resolve %s: %v", repository, latestTag, err)
+			writeRegistryError(w, err)
+			return "", false
+		}
+		if useCache {
+			m.tagCache.SetWithTTL(cacheKey, tags, cacheTTL)
+		}
+	}
+	highest, ok := highestRelease(tags)
+	if !ok || m.Settings.LatestFallback != LatestFallbackStable {
+		return highest, true
+	}
+
+	_, content, err := apiClient.FetchManifest(repository, highest)
+	if err != nil {
+		m.Logger.Warn("Error reading the stable version from %s:%s, serving it as latest: %v", repository, highest, err)
+		return highest, true
+	}
+	// Metadata of a denied artifact mustn't pick what is served
+	if err := m.manifestAnnotationsAllowed(content); err != nil {
+		m.writeAnnotationDenied(w, repository, highest, err)
+		return "", false
+	}
+	manifest, err := client.ParseManifest(content)
+	if err != nil {
+		m.Logger.Warn("Error reading the stable version from %s:%s, serving it as latest: %v", repository, highest, err)
+		return highest, true
+	}
+	var stable string
+	if metadata, ok := manifest.GetPluginMetadata(m.Settings.MetadataAnnotationKey); ok && metadata["stable"] != nil {
+		stable = strings.TrimSpace(fmt.Sprint(metadata["stable"]))
+	}
+	if stable == "" || strings.EqualFold(stable, "trunk") {
+		return highest, true
+	}
+	for _, candidate := range stableCandidates(stable) {
+		if slices.Contains(tags, candidate) {
+			return candidate, true
+		}
+	}
+	m.Logger.Warn("%s:%s declares stable version %s, which has no tag; serving %s as latest", repository, highest, stable, highest)
+	return highest, true
+}
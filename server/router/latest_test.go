@@ -0,0 +1,95 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestHighestRelease(t *testing.T) {
+	tests := []struct {
+		name   string
+		tags   []string
+		want   string
+		wantOK bool
+	}{
+		{name: "highest", tags: []string{"1.2.0", "1.10.0", "1.9.3"}, want: "1.10.0", wantOK: true},
+		{name: "v prefix", tags: []string{"v2.0.0", "1.9.0"}, want: "v2.0.0", wantOK: true},
+		{name: "skips pre-releases", tags: []string{"1.0.0", "2.0.0-beta.1"}, want: "1.0.0", wantOK: true},
+		{name: "skips other tags", tags: []string{"latest", "1.0", "01.0.0", "1.0.0"}, want: "1.0.0", wantOK: true},
+		{name: "no releases", tags: []string{"main", "1.0.0-rc.1"}},
+		{name: "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := highestRelease(tt.tags)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("highestRelease(%v) = %q, %t, want %q, %t", tt.tags, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLatestFallback(t *testing.T) {
+	const repository = "team/app"
+	metadata := func(stable string) map[string]string {
+		return map[string]string{"org.codekaizen.plugin.metadata": `{"stable":"` + stable + `"}`, "approved": "true"}
+	}
+
+	tests := []struct {
+		name        string
+		mode        string
+		required    map[string]string
+		annotations map[string]string
+		latest      bool
+		wantStatus  int
+		wantTag     string
+	}{
+		{name: "real latest", mode: LatestFallbackSemver, latest: true, wantStatus: http.StatusOK},
+		{name: "semver", mode: LatestFallbackSemver, wantStatus: http.StatusOK, wantTag: "1.2.0"},
+		{name: "stable", mode: LatestFallbackStable, annotations: metadata("1.1.0"), wantStatus: http.StatusOK, wantTag: "1.1.0"},
+		{name: "stable allowed", mode: LatestFallbackStable, required: map[string]string{"approved": "true"}, annotations: metadata("1.1.0"), wantStatus: http.StatusOK, wantTag: "1.1.0"},
+		// The stable release is approved, but the release naming it isn't
+		{name: "stable denied", mode: LatestFallbackStable, required: map[string]string{"approved": "true"}, annotations: map[string]string{"org.codekaizen.plugin.metadata": `{"stable":"1.1.0"}`}, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeClient()
+			fake.addManifest(repository, v1.Manifest{}, "1.0.0")
+			fake.addManifest(repository, v1.Manifest{Annotations: map[string]string{"approved": "true"}}, "1.1.0")
+			fake.addManifest(repository, v1.Manifest{Annotations: tt.annotations}, "1.2.0")
+			if tt.latest {
+				fake.addManifest(repository, v1.Manifest{}, latestTag)
+			}
+			config := &policy.ConfigFile{RequiredAnnotations: tt.required}
+			settings := DefaultApiSettings()
+			settings.LatestFallback = tt.mode
+			settings.TagCacheTTL = time.Minute
+			settings.MetadataAnnotationKey = "org.codekaizen.plugin.metadata"
+			manager := newTestManager(t, config, settings, fake)
+
+			for range 2 {
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/"+testRegistry+"/"+repository+"/latest/manifest/", nil)
+				resp := serve(manager, req)
+				if resp.Code != tt.wantStatus {
+					t.Fatalf("got status %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+				}
+				if got := resp.Header().Get(ResolvedTagHeader); got != tt.wantTag {
+					t.Errorf("resolved tag %q, want %q", got, tt.wantTag)
+				}
+			}
+			// The second request takes the tags from the cache
+			wantListings := 1
+			if tt.latest {
+				wantListings = 0
+			}
+			if got := fake.callCount("ListTags"); got != wantListings {
+				t.Errorf("listed tags %d times, want %d", got, wantListings)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/codekaizen-github/orashub/client"
+)
+
+// layerLimitEnabled reports whether max_layers is configured
+func (m *ApiManager) layerLimitEnabled() bool {
+	return m.ImagePolicy != nil && m.ImagePolicy.MaxLayers > 0
+}
+
+// checkLayerLimit enforces max_layers for endpoints that process every layer of a
+// manifest, rejecting artifacts with more layers with 422. Returns false when a
+// response has been written.
+func (m *ApiManager) checkLayerLimit(w http.ResponseWriter, repository, reference string, manifest *client.Manifest) bool {
+	if !m.layerLimitEnabled() || len(manifest.Layers) <= m.ImagePolicy.MaxLayers {
+		return true
+	}
+	m.Logger.Warn("Rejecting %s:%s with %d layers, more than max_layers (%d)", repository, reference, len(manifest.Layers), m.ImagePolicy.MaxLayers)
+	http.Error(w, fmt.Sprintf("artifact has %d layers, more than the %d allowed by policy", len(manifest.Layers), m.ImagePolicy.MaxLayers), http.StatusUnprocessableEntity)
+	return false
+}
+
+// checkManifestLayerLimit enforces max_layers for endpoints that list the layers of
+// raw manifest content. Content that doesn't parse as a manifest has no layers to
+// count and is left to the caller.
+func (m *ApiManager) checkManifestLayerLimit(w http.ResponseWriter, repository, reference string, content []byte) bool {
+	if !m.layerLimitEnabled() {
+		return true
+	}
+	manifest, err := client.ParseManifest(content)
+	if err != nil {
+		return true
+	}
+	return m.checkLayerLimit(w, repository, reference, manifest)
+}
+
+// checkLayerLimitFor enforces max_layers for endpoints that don't otherwise read the
+// manifest, fetching it only when a limit is configured
+func (m *ApiManager) checkLayerLimitFor(w http.ResponseWriter, apiClient client.ClientInterface, repository, reference string) bool {
+	if !m.layerLimitEnabled() {
+		return true
+	}
+
+	_, content, err := apiClient.FetchManifest(repository, reference)
+	if err != nil {
+		m.Logger.Error("Error getting manifest of %s:%s for the layer limit: %v", repository, reference, err)
+		writeRegistryError(w, err)
+		return false
+	}
+	manifest, err := client.ParseManifest(content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return false
+	}
+	return m.checkLayerLimit(w, repository, reference, manifest)
+}
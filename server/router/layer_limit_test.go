@@ -0,0 +1,100 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestMaxLayers(t *testing.T) {
+	const repository = "team/app"
+	fake := newFakeClient()
+	first := fake.addBlob("application/zip", []byte("first"))
+	second := fake.addBlob("application/zip", []byte("second"))
+	fake.addManifest(repository, v1.Manifest{Layers: []v1.Descriptor{first, second}}, "1.0.0")
+
+	tests := []struct {
+		name      string
+		maxLayers int
+		reference string
+		want      int
+		// wantFor is the status checkLayerLimitFor ends with, 0 when it lets the request through
+		wantFor int
+	}{
+		{name: "unlimited", reference: "1.0.0", want: http.StatusOK},
+		{name: "at the limit", maxLayers: 2, reference: "1.0.0", want: http.StatusOK},
+		{name: "over the limit", maxLayers: 1, reference: "1.0.0", want: http.StatusUnprocessableEntity, wantFor: http.StatusUnprocessableEntity},
+		{name: "missing manifest", maxLayers: 1, reference: "2.0.0", want: http.StatusNotFound, wantFor: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := newTestManager(t, &policy.ConfigFile{MaxLayers: tt.maxLayers}, DefaultApiSettings(), fake)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/"+testRegistry+"/"+repository+"/"+tt.reference+"/size/", nil)
+			if got := serve(manager, req).Code; got != tt.want {
+				t.Errorf("size endpoint status %d, want %d", got, tt.want)
+			}
+
+			recorder := httptest.NewRecorder()
+			passed := manager.checkLayerLimitFor(recorder, fake, repository, tt.reference)
+			if passed != (tt.wantFor == 0) {
+				t.Errorf("checkLayerLimitFor passed = %t, want %t", passed, tt.wantFor == 0)
+			}
+			if tt.wantFor != 0 && recorder.Code != tt.wantFor {
+				t.Errorf("checkLayerLimitFor status %d, want %d", recorder.Code, tt.wantFor)
+			}
+		})
+	}
+}
+
+func TestMaxLayersListingEndpoints(t *testing.T) {
+	const repository = "team/app"
+	fake := newFakeClient()
+	first := fake.addBlob("application/zip", []byte("first"))
+	second := fake.addBlob("application/zip", []byte("second"))
+	fake.addManifest(repository, v1.Manifest{Layers: []v1.Descriptor{first, second}}, "1.0.0", "2.0.0")
+
+	prefix := "/api/v1/" + testRegistry + "/" + repository
+	endpoints := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{name: "manifest", method: http.MethodGet, path: prefix + "/1.0.0/manifest/"},
+		{name: "manifest json", method: http.MethodGet, path: prefix + "/1.0.0/manifest/?format=application/json"},
+		{name: "manifest fields", method: http.MethodGet, path: prefix + "/1.0.0/manifest/?fields=layers"},
+		{name: "descriptor", method: http.MethodGet, path: prefix + "/1.0.0/descriptor/"},
+		{name: "raw descriptor", method: http.MethodGet, path: prefix + "/1.0.0/descriptor/?raw=true"},
+		{name: "diff", method: http.MethodGet, path: prefix + "/diff/?from=1.0.0&to=2.0.0"},
+		{name: "compare", method: http.MethodPost, path: prefix + "/1.0.0/compare/", body: "first"},
+	}
+	limits := []struct {
+		name      string
+		maxLayers int
+		want      int
+	}{
+		{name: "unlimited", want: http.StatusOK},
+		{name: "at the limit", maxLayers: 2, want: http.StatusOK},
+		{name: "over the limit", maxLayers: 1, want: http.StatusUnprocessableEntity},
+	}
+	for _, limit := range limits {
+		manager := newTestManager(t, &policy.ConfigFile{MaxLayers: limit.maxLayers}, DefaultApiSettings(), fake)
+		for _, endpoint := range endpoints {
+			t.Run(limit.name+"/"+endpoint.name, func(t *testing.T) {
+				req := httptest.NewRequest(endpoint.method, endpoint.path, strings.NewReader(endpoint.body))
+				resp := serve(manager, req)
+				if resp.Code != limit.want {
+					t.Fatalf("got status %d, want %d: %s", resp.Code, limit.want, resp.Body)
+				}
+				if limit.want == http.StatusUnprocessableEntity && !strings.Contains(resp.Body.String(), "artifact has 2 layers") {
+					t.Errorf("body %q doesn't explain the limit", resp.Body)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package router
+
+import (
+	"sync"
+	"time"
+
+	"github.com/codekaizen-github/orashub/client"
+	"github.com/codekaizen-github/orashub/server/policy"
+)
+
+// lazyClient holds the client for one registry. The client is built on first use
+// and, when ClientIdleTimeout is set, torn down again once it has been idle that
+// long, so only registries in use hold clients, token caches and connections.
+type lazyClient struct {
+	registry policy.RegistryCredentials
+	options  []client.Option
+
+	mu       sync.Mutex
+	client   client.ClientInterface
+	lastUsed time.Time
+}
+
+// newLazyClient creates a holder for registry. No client is built until get is called.
+func newLazyClient(registry policy.RegistryCredentials, options []client.Option) *lazyClient {
+	return &lazyClient{registry: registry, options: options}
+}
+
+// build creates a client from the holder's credentials
+func (c *lazyClient) build() (client.ClientInterface, error) {
+	return client.NewClient(c.registry.Name, c.registry.Username, c.registry.Password, c.options...)
+}
+
+// get returns the registry's client, building it if there is none, and marks it as used
+func (c *lazyClient) get() (client.ClientInterface, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastUsed = time.Now()
+	if c.client == nil {
+		apiClient, err := c.build()
+		if err != nil {
+			return nil, err
+		}
+		c.client = apiClient
+	}
+	return c.client, nil
+}
+
+// probe returns the live client without marking it as used, or a throwaway one when
+// there is none, so health checks don't keep idle registries' clients alive
+func (c *lazyClient) probe() (client.ClientInterface, error) {
+	c.mu.Lock()
+	existing := c.client
+	c.mu.Unlock()
+
+	if existing != nil {
+		return existing, nil
+	}
+	return c.build()
+}
+
+// live reports whether the registry currently has a client
+func (c *lazyClient) live() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.client != nil
+}
+
+// evictIfIdle drops the client when it hasn't been used for idle, returning whether
+// it did. Requests already holding the client finish with it; the next one builds a
+// new client.
+func (c *lazyClient) evictIfIdle(now time.Time, idle time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client == nil || now.Sub(c.lastUsed) < idle {
+		return false
+	}
+	c.client = nil
+	return true
+}
+
+// evictIdleClients tears down registry clients that have been idle for idle,
+// checking every half of it. It runs for the life of the process.
+func (m *ApiManager) evictIdleClients(idle time.Duration) {
+	interval := max(idle/2, time.Second)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		for name, holder := range m.registries().clients {
+			if holder.evictIfIdle(now, idle) {
+				m.Logger.Debug("Evicted the client for registry %s after %s idle", name, idle)
+			}
+		}
+	}
+}
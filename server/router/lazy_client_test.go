@@ -0,0 +1,76 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+)
+
+func TestLazyClient(t *testing.T) {
+	const idle = time.Minute
+
+	tests := []struct {
+		name string
+		// get and probe say whether the client is used or probed before eviction
+		get   bool
+		probe bool
+		// elapsed is how long after the last use eviction is attempted
+		elapsed     time.Duration
+		wantEvicted bool
+	}{
+		{name: "never built", elapsed: time.Hour},
+		{name: "probe doesn't keep its client", probe: true, elapsed: time.Hour},
+		{name: "recently used", get: true, elapsed: idle / 2},
+		{name: "idle", get: true, elapsed: idle, wantEvicted: true},
+		{name: "probe doesn't keep it alive", get: true, probe: true, elapsed: idle, wantEvicted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			holder := newLazyClient(policy.RegistryCredentials{Name: "registry.example"}, nil)
+			if tt.get {
+				first, err := holder.get()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if again, _ := holder.get(); again != first {
+					t.Error("get built a second client")
+				}
+			}
+			if tt.probe {
+				probed, err := holder.probe()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.get {
+					if live, _ := holder.get(); live != probed {
+						t.Error("probe didn't return the live client")
+					}
+				}
+			}
+			if holder.live() != tt.get {
+				t.Fatalf("live = %t, want %t", holder.live(), tt.get)
+			}
+
+			holder.mu.Lock()
+			lastUsed := holder.lastUsed
+			holder.mu.Unlock()
+			if evicted := holder.evictIfIdle(lastUsed.Add(tt.elapsed), idle); evicted != tt.wantEvicted {
+				t.Errorf("evicted = %t, want %t", evicted, tt.wantEvicted)
+			}
+			if tt.wantEvicted && holder.live() {
+				t.Error("client still live after eviction")
+			}
+		})
+	}
+}
+
+func TestLazyClientInvalidRegistry(t *testing.T) {
+	holder := newLazyClient(policy.RegistryCredentials{Name: "https://registry.example"}, nil)
+	if _, err := holder.get(); err == nil {
+		t.Error("expected building a client for a URL to fail")
+	}
+	if holder.live() {
+		t.Error("a failed build left a client behind")
+	}
+}
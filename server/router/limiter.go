@@ -0,0 +1,94 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// fetchLimiter bounds the number of concurrent registry fetches across all requests
+type fetchLimiter struct {
+	slots chan struct{}
+}
+
+// newFetchLimiter creates a limiter allowing up to n concurrent fetches
+func newFetchLimiter(n int) *fetchLimiter {
+	if n < 1 {
+		n = 1
+	}
+	return &fetchLimiter{slots: make(chan struct{}, n)}
+}
+
+// acquire blocks until a fetch slot is available or the context is done
+func (l *fetchLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release returns a fetch slot to the limiter
+func (l *fetchLimiter) release() {
+	<-l.slots
+}
+
+// ipLimiter caps the number of concurrent requests from each client IP
+type ipLimiter struct {
+	max int
+
+	mu     sync.Mutex
+	active map[string]int
+}
+
+// newIPLimiter creates a limiter allowing up to max concurrent requests per IP
+func newIPLimiter(max int) *ipLimiter {
+	return &ipLimiter{max: max, active: make(map[string]int)}
+}
+
+// acquire takes a slot for ip without waiting, returning false when ip already
+// holds every slot
+func (l *ipLimiter) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.active[ip] >= l.max {
+		return false
+	}
+	l.active[ip]++
+	return true
+}
+
+// release returns a slot taken by ip
+func (l *ipLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.active[ip] <= 1 {
+		delete(l.active, ip)
+		return
+	}
+	l.active[ip]--
+}
+
+// limitDownloadsPerIP wraps a handler that streams artifact content so a single
+// client IP can hold at most MaxDownloadsPerIP streams open at once; more get 429.
+// A slot is held until the handler returns, i.e. until the stream is closed.
+func (m *ApiManager) limitDownloadsPerIP(next http.HandlerFunc) http.HandlerFunc {
+	if m.downloadsPerIP == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, req *http.Request) {
+		ip := m.Settings.TrustedProxies.clientIP(req)
+		if !m.downloadsPerIP.acquire(ip) {
+			m.Logger.Warn("Rejecting download from %s: %d downloads already in flight", ip, m.downloadsPerIP.max)
+			http.Error(w, fmt.Sprintf("too many concurrent downloads from this client (at most %d)", m.downloadsPerIP.max), http.StatusTooManyRequests)
+			return
+		}
+		defer m.downloadsPerIP.release(ip)
+
+		next(w, req)
+	}
+}
@@ -0,0 +1,76 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/client"
+	"github.com/codekaizen-github/orashub/server/policy"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// nilTagsClient lists every repository as a nil tag slice, as a listing read back
+// from a cache may be
+type nilTagsClient struct {
+	*fakeClient
+}
+
+func (c *nilTagsClient) WithContext(ctx context.Context) client.ClientInterface { return c }
+
+func (c *nilTagsClient) WithTimings(timings *client.Timings) client.ClientInterface { return c }
+
+func (c *nilTagsClient) ListTags(repository, last string) ([]string, error) {
+	return nil, nil
+}
+
+func TestListTagsEmptyAndMissing(t *testing.T) {
+	fake := newFakeClient()
+	fake.addManifest("team/app", v1.Manifest{}, "1.0.0")
+	// Reachable by digest only, so the repository exists without tags
+	fake.addManifest("team/untagged", v1.Manifest{})
+
+	tests := []struct {
+		name       string
+		repository string
+		nilTags    bool
+		want       int
+		wantTags   []string
+	}{
+		{name: "tagged", repository: "team/app", want: http.StatusOK, wantTags: []string{"1.0.0"}},
+		{name: "no tags", repository: "team/untagged", want: http.StatusOK, wantTags: []string{}},
+		{name: "nil listing", repository: "team/app", nilTags: true, want: http.StatusOK, wantTags: []string{}},
+		{name: "unknown repository", repository: "team/missing", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := newTestManager(t, &policy.ConfigFile{}, DefaultApiSettings(), fake)
+			if tt.nilTags {
+				manager.registries().clients[testRegistry].client = &nilTagsClient{fake}
+			}
+
+			resp := serve(manager, httptest.NewRequest(http.MethodGet, "/api/v1/"+testRegistry+"/"+tt.repository+"/", nil))
+			if resp.Code != tt.want {
+				t.Fatalf("got status %d, want %d: %s", resp.Code, tt.want, resp.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			// Decode into raw messages so null and [] can be told apart
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			var tags []string
+			if err := json.Unmarshal(body["tags"], &tags); err != nil {
+				t.Fatal(err)
+			}
+			if tags == nil || !slices.Equal(tags, tt.wantTags) {
+				t.Errorf("tags = %s, want %v", body["tags"], tt.wantTags)
+			}
+		})
+	}
+}
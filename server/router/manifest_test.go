@@ -0,0 +1,96 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestManifestContentType(t *testing.T) {
+	const repository = "team/app"
+	fake := newFakeClient()
+	fake.addManifest(repository, v1.Manifest{}, "image")
+	fake.addRawManifest(repository, []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`), "index")
+	manager := newTestManager(t, &policy.ConfigFile{}, DefaultApiSettings(), fake)
+
+	tests := []struct {
+		name   string
+		tag    string
+		accept string
+		want   string
+	}{
+		{name: "manifest as OCI", tag: "image", accept: v1.MediaTypeImageManifest, want: v1.MediaTypeImageManifest},
+		{name: "index as OCI", tag: "index", accept: v1.MediaTypeImageManifest, want: v1.MediaTypeImageIndex},
+		{name: "index without Accept", tag: "index", want: "application/json"},
+		{name: "normalized", tag: "image", accept: "application/json", want: "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/"+testRegistry+"/"+repository+"/"+tt.tag+"/manifest/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			resp := serve(manager, req)
+			if resp.Code != http.StatusOK {
+				t.Fatalf("got status %d: %s", resp.Code, resp.Body)
+			}
+			if got := resp.Header().Get("Content-Type"); got != tt.want {
+				t.Errorf("Content-Type = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRawManifestContentType(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		mediaType string
+		want      string
+	}{
+		{name: "generic", format: "application/json", mediaType: v1.MediaTypeImageIndex, want: "application/json"},
+		{name: "descriptor type", format: v1.MediaTypeImageManifest, mediaType: v1.MediaTypeImageIndex, want: v1.MediaTypeImageIndex},
+		{name: "no descriptor type", format: v1.MediaTypeImageManifest, want: v1.MediaTypeImageManifest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rawManifestContentType(tt.format, &v1.Descriptor{MediaType: tt.mediaType}); got != tt.want {
+				t.Errorf("rawManifestContentType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifestIncludeReferrers(t *testing.T) {
+	const repository = "team/app"
+	fake := newFakeClient()
+	fake.addManifest(repository, v1.Manifest{}, "1.0.0")
+	manager := newTestManager(t, &policy.ConfigFile{}, DefaultApiSettings(), fake)
+
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+		want   int
+	}{
+		{name: "normalized", query: "include_referrers=true", accept: "application/json", want: http.StatusOK},
+		{name: "raw", query: "include_referrers=true", accept: v1.MediaTypeImageManifest, want: http.StatusBadRequest},
+		{name: "default raw", query: "include_referrers=true", want: http.StatusBadRequest},
+		{name: "fields", query: "include_referrers=true&fields=layers", accept: "application/json", want: http.StatusBadRequest},
+		{name: "raw without referrers", accept: v1.MediaTypeImageManifest, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/"+testRegistry+"/"+repository+"/1.0.0/manifest/?"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			if got := serve(manager, req).Code; got != tt.want {
+				t.Errorf("got status %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
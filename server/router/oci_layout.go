@@ -0,0 +1,137 @@
+package router
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// writeTarDir writes every file and directory under root to tw, named relative to
+// root with forward slashes. Entries are written in lexical order, so the same
+// layout always produces the same archive listing.
+func writeTarDir(tw *tar.Writer, root string) error {
+	return filepath.WalkDir(root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if entry.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+}
+
+// HandleOCILayout exports an artifact as a tar of an OCI image layout (oci-layout,
+// index.json and blobs/) that tools like oras or skopeo can import. The artifact is
+// staged in a temporary directory rather than memory, then streamed as a tar.
+func (m *ApiManager) HandleOCILayout(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+	namespace := pathValues["namespace"]
+	repository := pathValues["repository"]
+	tag := pathValues["tag"]
+
+	// Get client
+	apiClient, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	// Check policy
+	if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+		return
+	}
+	if !m.checkDownloadPolicy(w, registry, namespace, repository) {
+		return
+	}
+
+	// Build namespaced repository
+	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+
+	// Check annotation policy, which needs the manifest
+	reference, ok := m.checkAnnotationPolicy(w, apiClient, namespacedRepository, tag)
+	if !ok {
+		return
+	}
+	if !m.checkLayerLimitFor(w, apiClient, namespacedRepository, reference) {
+		return
+	}
+
+	// Stage the layout on disk so large layers don't have to fit in memory
+	dir, err := os.MkdirTemp("", "orashub-oci-layout-")
+	if err != nil {
+		m.Logger.Error("Error creating OCI layout directory: %v", err)
+		http.Error(w, "failed to stage OCI layout", http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := apiClient.CopyToOCILayout(namespacedRepository, reference, tag, dir); err != nil {
+		m.Logger.Error("Error copying %s/%s:%s to OCI layout: %v", namespace, repository, tag, err)
+		writeRegistryError(w, err)
+		return
+	}
+
+	// The store's scratch directory for in-progress blobs isn't part of the layout
+	if err := os.RemoveAll(filepath.Join(dir, "ingest")); err != nil {
+		m.Logger.Warn("Error removing OCI layout ingest directory: %v", err)
+	}
+
+	// Set headers
+	m.setCacheControl(w, tag)
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", fmt.Sprintf("%s-%s.tar", repository, tag)))
+
+	// Stream the layout as a tar
+	w.WriteHeader(http.StatusOK)
+	tw := tar.NewWriter(w)
+	if err := writeTarDir(tw, dir); err != nil {
+		// The status is already sent, so break the connection rather than let the
+		// client mistake a truncated tar for a complete layout
+		m.Logger.Error("Aborting OCI layout tar for %s/%s:%s: %v", namespace, repository, tag, err)
+		panic(http.ErrAbortHandler)
+	}
+	if err := tw.Close(); err != nil {
+		m.Logger.Error("Aborting OCI layout tar for %s/%s:%s: %v", namespace, repository, tag, err)
+		panic(http.ErrAbortHandler)
+	}
+}
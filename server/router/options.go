@@ -0,0 +1,47 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+)
+
+// routeMethods records the methods registered for each path pattern, in
+// registration order, so OPTIONS can report them
+type routeMethods struct {
+	patterns []string
+	methods  map[string][]string
+}
+
+// add records that pattern answers method. GET routes also answer HEAD.
+func (r *routeMethods) add(method, pattern string) {
+	if r.methods == nil {
+		r.methods = make(map[string][]string)
+	}
+	if _, ok := r.methods[pattern]; !ok {
+		r.patterns = append(r.patterns, pattern)
+	}
+	r.methods[pattern] = append(r.methods[pattern], method)
+	if method == http.MethodGet {
+		r.methods[pattern] = append(r.methods[pattern], http.MethodHead)
+	}
+}
+
+// alias records that variant answers the same methods as pattern
+func (r *routeMethods) alias(variant, pattern string) {
+	r.patterns = append(r.patterns, variant)
+	r.methods[variant] = r.methods[pattern]
+}
+
+// allow returns the Allow header value for pattern, including OPTIONS itself
+func (r *routeMethods) allow(pattern string) string {
+	return strings.Join(append(r.methods[pattern], http.MethodOptions), ", ")
+}
+
+// handleOptions answers OPTIONS for a route with 204 and the methods it supports.
+// With CORS enabled it is wrapped by withCORS, which adds the preflight headers.
+func handleOptions(allow string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Allow", allow)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,90 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies lists the address ranges of reverse proxies whose X-Forwarded-*
+// headers are honoured. An empty list trusts no peer, so forwarded headers are
+// ignored until proxies are configured.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses a comma-separated list of CIDRs such as
+// "10.0.0.0/8, 192.168.1.10". A bare address is treated as a single-host range.
+func ParseTrustedProxies(value string) (TrustedProxies, error) {
+	proxies := TrustedProxies{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy '%s': %v", entry, err)
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy '%s': %v", entry, err)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+// trusts reports whether the request came directly from a trusted proxy, so its
+// forwarded headers can be believed
+func (t TrustedProxies) trusts(req *http.Request) bool {
+	addrPort, err := netip.ParseAddrPort(req.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	return t.contains(addrPort.Addr().Unmap())
+}
+
+// contains reports whether addr is in a trusted range
+func (t TrustedProxies) contains(addr netip.Addr) bool {
+	for _, prefix := range t {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client that sent the request. When the peer
+// is a trusted proxy, X-Forwarded-For is read from the right, skipping trusted
+// proxies, so entries a client prepends itself are never believed.
+func (t TrustedProxies) clientIP(req *http.Request) string {
+	client := req.RemoteAddr
+	if addrPort, err := netip.ParseAddrPort(req.RemoteAddr); err == nil {
+		client = addrPort.Addr().Unmap().String()
+	}
+	if !t.trusts(req) {
+		return client
+	}
+
+	var forwarded []string
+	for _, value := range req.Header.Values("X-Forwarded-For") {
+		for _, entry := range strings.Split(value, ",") {
+			forwarded = append(forwarded, strings.TrimSpace(entry))
+		}
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(forwarded[i])
+		if err != nil {
+			break
+		}
+		client = addr.Unmap().String()
+		if !t.contains(addr.Unmap()) {
+			break
+		}
+	}
+	return client
+}
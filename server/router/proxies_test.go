@@ -0,0 +1,107 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "", want: []string{}},
+		{value: "10.0.0.0/8", want: []string{"10.0.0.0/8"}},
+		{value: " 10.1.2.3/8 , 192.168.1.10 ", want: []string{"10.0.0.0/8", "192.168.1.10/32"}},
+		{value: "::ffff:192.168.1.10", want: []string{"192.168.1.10/32"}},
+		{value: "fd00::/8,,2001:db8::1", want: []string{"fd00::/8", "2001:db8::1/128"}},
+		{value: "10.0.0.0/33", wantErr: true},
+		{value: "10.0.0.0/8, proxy.internal", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			proxies, err := ParseTrustedProxies(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := []string{}
+			for _, prefix := range proxies {
+				got = append(got, prefix.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	configured, err := ParseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		proxies    TrustedProxies
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{name: "no proxies configured ignores forwarded", remoteAddr: "203.0.113.7:5000", forwarded: "198.51.100.1", want: "203.0.113.7"},
+		{name: "no proxies configured ignores spoofed chain", remoteAddr: "10.0.0.1:5000", forwarded: "1.2.3.4, 198.51.100.1", want: "10.0.0.1"},
+		{name: "untrusted peer", proxies: configured, remoteAddr: "203.0.113.7:5000", forwarded: "198.51.100.1", want: "203.0.113.7"},
+		{name: "trusted peer", proxies: configured, remoteAddr: "10.0.0.1:5000", forwarded: "198.51.100.1", want: "198.51.100.1"},
+		{name: "trusted peer skips trusted hops", proxies: configured, remoteAddr: "10.0.0.1:5000", forwarded: "198.51.100.1, 10.0.0.2", want: "198.51.100.1"},
+		{name: "trusted peer ignores prepended entries", proxies: configured, remoteAddr: "10.0.0.1:5000", forwarded: "1.2.3.4, 198.51.100.1", want: "198.51.100.1"},
+		{name: "trusted peer without header", proxies: configured, remoteAddr: "10.0.0.1:5000", want: "10.0.0.1"},
+		{name: "IPv4-mapped peer", remoteAddr: "[::ffff:203.0.113.7]:5000", want: "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := tt.proxies.clientIP(req); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetServerInfo(t *testing.T) {
+	configured, err := ParseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		proxies    TrustedProxies
+		remoteAddr string
+		want       string
+	}{
+		{name: "no proxies configured", remoteAddr: "10.0.0.1:5000", want: "http://orashub.internal/api/v1/"},
+		{name: "untrusted peer", proxies: configured, remoteAddr: "203.0.113.7:5000", want: "http://orashub.internal/api/v1/"},
+		{name: "trusted peer", proxies: configured, remoteAddr: "10.0.0.1:5000", want: "https://plugins.example.com/api/v1/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://orashub.internal/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-Proto", "https")
+			req.Header.Set("X-Forwarded-Host", "plugins.example.com")
+			if got := getServerInfo(req, tt.proxies).ApiURL; got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
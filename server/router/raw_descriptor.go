@@ -0,0 +1,115 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// rawDescriptorResponse is the manifest descriptor in OCI field order, together with
+// the config, layer and subject descriptors copied verbatim from the manifest bytes.
+// Copying rather than re-encoding keeps the registry's field ordering and any inline
+// base64 data fields intact.
+type rawDescriptorResponse struct {
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	Config       json.RawMessage   `json:"config,omitempty"`
+	Layers       []json.RawMessage `json:"layers"`
+	Subject      json.RawMessage   `json:"subject,omitempty"`
+}
+
+// rawManifestDescriptors holds the descriptors of a manifest as undecoded JSON
+type rawManifestDescriptors struct {
+	ArtifactType string            `json:"artifactType"`
+	Config       json.RawMessage   `json:"config"`
+	Layers       []json.RawMessage `json:"layers"`
+	Subject      json.RawMessage   `json:"subject"`
+}
+
+// newRawDescriptorResponse builds the raw descriptor response from a manifest descriptor
+// and the manifest bytes it describes. When includeData is false the inline data
+// fields are dropped from the copied descriptors.
+func newRawDescriptorResponse(desc *v1.Descriptor, content []byte, includeData bool) (*rawDescriptorResponse, error) {
+	var manifest rawManifestDescriptors
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %v", err)
+	}
+	if !includeData {
+		var err error
+		if manifest.Config, err = withoutDataField(manifest.Config); err != nil {
+			return nil, err
+		}
+		if manifest.Subject, err = withoutDataField(manifest.Subject); err != nil {
+			return nil, err
+		}
+		for i := range manifest.Layers {
+			if manifest.Layers[i], err = withoutDataField(manifest.Layers[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	layers := manifest.Layers
+	if layers == nil {
+		layers = []json.RawMessage{}
+	}
+
+	artifactType := desc.ArtifactType
+	if artifactType == "" {
+		artifactType = manifest.ArtifactType
+	}
+
+	return &rawDescriptorResponse{
+		MediaType:    desc.MediaType,
+		ArtifactType: artifactType,
+		Digest:       desc.Digest.String(),
+		Size:         desc.Size,
+		Annotations:  desc.Annotations,
+		Config:       manifest.Config,
+		Layers:       layers,
+		Subject:      manifest.Subject,
+	}, nil
+}
+
+// withoutDataField removes the data field from a descriptor given as raw JSON. The
+// other fields are copied in their original order with their original encoding, as
+// the raw response promises. Values that aren't objects are returned unchanged.
+func withoutDataField(raw json.RawMessage) (json.RawMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return raw, nil
+	}
+
+	var out bytes.Buffer
+	out.WriteByte('{')
+	first := true
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse descriptor: %v", err)
+		}
+		key, _ := token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("failed to parse descriptor: %v", err)
+		}
+		if key == "data" {
+			continue
+		}
+		if !first {
+			out.WriteByte(',')
+		}
+		first = false
+		encodedKey, _ := json.Marshal(key)
+		out.Write(encodedKey)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
@@ -0,0 +1,70 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestWithoutDataField(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{name: "data removed", raw: `{"mediaType":"a","data":"e30=","size":2}`, want: `{"mediaType":"a","size":2}`},
+		{name: "order and encoding kept", raw: `{"size": 2, "digest":"sha256:ab", "annotations":{"data":"kept"}}`, want: `{"size":2,"digest":"sha256:ab","annotations":{"data":"kept"}}`},
+		{name: "only data", raw: `{"data":"e30="}`, want: `{}`},
+		{name: "null", raw: `null`, want: `null`},
+		{name: "empty", raw: ``, want: ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := withoutDataField(json.RawMessage(tt.raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescriptorIncludeData(t *testing.T) {
+	const repository = "team/app"
+	fake := newFakeClient()
+	config := fake.addBlob(v1.MediaTypeEmptyJSON, []byte("{}"))
+	config.Data = []byte("{}")
+	subject := v1.Descriptor{MediaType: v1.MediaTypeImageManifest, Digest: config.Digest, Size: 2, Data: []byte("{}")}
+	fake.addManifest(repository, v1.Manifest{Config: config, Layers: []v1.Descriptor{config}, Subject: &subject}, "1.0.0")
+	manager := newTestManager(t, &policy.ConfigFile{}, DefaultApiSettings(), fake)
+
+	tests := []struct {
+		name     string
+		query    string
+		wantData bool
+	}{
+		{name: "default", wantData: true},
+		{name: "excluded", query: "?include_data=false"},
+		{name: "raw", query: "?raw=true", wantData: true},
+		{name: "raw excluded", query: "?raw=true&include_data=false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/"+testRegistry+"/"+repository+"/1.0.0/descriptor/"+tt.query, nil)
+			resp := serve(manager, req)
+			if resp.Code != http.StatusOK {
+				t.Fatalf("got status %d: %s", resp.Code, resp.Body)
+			}
+			if got := strings.Contains(resp.Body.String(), `"data"`); got != tt.wantData {
+				t.Errorf("response has data = %t, want %t: %s", got, tt.wantData, resp.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/codekaizen-github/orashub/client"
+)
+
+// readinessProbeTimeout bounds a single registry probe
+const readinessProbeTimeout = 5 * time.Second
+
+// probeState holds the last probe result for one registry. The mutex is held while
+// probing so concurrent readiness checks share a single probe instead of piling up.
+type probeState struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	nextProbe time.Time
+	failures  int
+	scheme    string
+	err       error
+}
+
+// readinessChecker caches registry probe results, re-probing healthy registries
+// after a TTL and failing ones after an exponential backoff with jitter
+type readinessChecker struct {
+	ttl         time.Duration
+	backoffBase time.Duration
+	backoffMax  time.Duration
+	states      map[string]*probeState
+}
+
+// newReadinessChecker creates a checker with one probe state per registry
+func newReadinessChecker(registries []string, ttl, backoffBase, backoffMax time.Duration) *readinessChecker {
+	checker := &readinessChecker{
+		ttl:         ttl,
+		backoffBase: backoffBase,
+		backoffMax:  backoffMax,
+		states:      make(map[string]*probeState, len(registries)),
+	}
+	for _, registry := range registries {
+		checker.states[registry] = &probeState{}
+	}
+	return checker
+}
+
+// backoff returns how long to wait before re-probing after the given number of
+// consecutive failures, doubling each time up to backoffMax with ±50% jitter
+func (c *readinessChecker) backoff(failures int) time.Duration {
+	delay := c.backoffBase
+	for i := 1; i < failures && delay < c.backoffMax; i++ {
+		delay *= 2
+	}
+	if c.backoffMax > 0 && delay > c.backoffMax {
+		delay = c.backoffMax
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay)
+}
+
+// check returns the readiness verdict for a registry, probing it only when the
+// cached result has expired
+func (c *readinessChecker) check(ctx context.Context, registry string, apiClient client.ClientInterface) registryReadiness {
+	state := c.states[registry]
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	now := time.Now()
+	if state.checkedAt.IsZero() || !now.Before(state.nextProbe) {
+		if !c.probe(ctx, state, apiClient) {
+			return registryReadiness{Ready: false, Error: ctx.Err().Error()}
+		}
+	}
+
+	checkedAt := state.checkedAt
+	result := registryReadiness{Ready: state.err == nil, CheckedAt: &checkedAt, AuthScheme: state.scheme}
+	if state.err != nil {
+		result.Error = state.err.Error()
+	}
+	return result
+}
+
+// probe pings the registry and schedules the next probe. A probe cut short by ctx
+// ending says nothing about the registry, so it isn't recorded and probe returns
+// false. The caller holds state.mu.
+func (c *readinessChecker) probe(ctx context.Context, state *probeState, apiClient client.ClientInterface) bool {
+	probeCtx, cancel := context.WithTimeout(ctx, readinessProbeTimeout)
+	defer cancel()
+	ping, err := apiClient.Ping(probeCtx)
+	if ctx.Err() != nil {
+		return false
+	}
+	state.err = err
+	state.scheme = ping.AuthScheme
+	state.checkedAt = time.Now()
+
+	if state.err != nil {
+		state.failures++
+		state.nextProbe = state.checkedAt.Add(c.backoff(state.failures))
+	} else {
+		state.failures = 0
+		state.nextProbe = state.checkedAt.Add(c.ttl)
+	}
+	return true
+}
+
+// registryReadiness is the readiness verdict for a single registry
+type registryReadiness struct {
+	Ready     bool       `json:"ready"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	// AuthScheme is the auth scheme the registry advertised: basic, bearer or none
+	AuthScheme string `json:"auth_scheme,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// HandleReadyz reports whether every configured registry is reachable. Probe
+// results are cached, so frequent polling by orchestrators stays cheap.
+func (m *ApiManager) HandleReadyz(w http.ResponseWriter, req *http.Request) {
+	set := m.registries()
+	registries := set.names()
+
+	// Probe registries concurrently
+	results := make(map[string]registryReadiness, len(registries))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range registries {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			var result registryReadiness
+			if apiClient, err := set.clients[name].probe(); err != nil {
+				result = registryReadiness{Ready: false, Error: err.Error()}
+			} else {
+				result = set.readiness.check(req.Context(), name, apiClient)
+			}
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+
+	// Registries that never initialized are never ready
+	for name, err := range set.unavailable {
+		results[name] = registryReadiness{Ready: false, Error: err.Error()}
+	}
+
+	status := http.StatusOK
+	verdict := "ready"
+	for _, result := range results {
+		if !result.Ready {
+			status = http.StatusServiceUnavailable
+			verdict = "not ready"
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     verdict,
+		"registries": results,
+	}); err != nil {
+		m.Logger.Error("Error encoding readiness response: %v", err)
+	}
+}
+
+// CheckConnectivity probes every registry once, concurrently, and logs a warning
+// for each one that is unreachable or rejects the configured credentials. It never
+// fails: a registry that is down at boot may recover, and the others keep serving.
+// Each probe is bounded by timeout.
+func (m *ApiManager) CheckConnectivity(ctx context.Context, timeout time.Duration) {
+	var wg sync.WaitGroup
+	for name, holder := range m.registries().clients {
+		wg.Add(1)
+		go func(name string, holder *lazyClient) {
+			defer wg.Done()
+			apiClient, err := holder.probe()
+			if err != nil {
+				m.Logger.Warn("Registry %s failed its startup connectivity check: %v", name, err)
+				return
+			}
+			probeCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			ping, err := apiClient.Ping(probeCtx)
+			if err != nil {
+				m.Logger.Warn("Registry %s failed its startup connectivity check; check its name and credentials (auth scheme %s): %v", name, authSchemeLabel(ping.AuthScheme), err)
+				return
+			}
+			m.Logger.Info("Registry %s is reachable (auth scheme %s)", name, authSchemeLabel(ping.AuthScheme))
+		}(name, holder)
+	}
+	wg.Wait()
+}
+
+// authSchemeLabel describes an advertised auth scheme for logs
+func authSchemeLabel(scheme string) string {
+	if scheme == "" {
+		return "unknown"
+	}
+	return scheme
+}
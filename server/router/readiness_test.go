@@ -0,0 +1,63 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codekaizen-github/orashub/client"
+)
+
+// pingClient answers Ping with err, counting the probes
+type pingClient struct {
+	client.ClientInterface
+	err   error
+	pings int
+}
+
+func (c *pingClient) Ping(ctx context.Context) (client.PingResult, error) {
+	c.pings++
+	if err := ctx.Err(); err != nil {
+		return client.PingResult{}, err
+	}
+	return client.PingResult{AuthScheme: "bearer"}, c.err
+}
+
+func TestReadinessCheck(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		// wantReady is the verdict of the first check
+		wantReady bool
+		// wantPings counts the probes after a second check with a live context
+		wantPings    int
+		wantFailures int
+	}{
+		{name: "ready is cached", ctx: context.Background(), wantReady: true, wantPings: 1},
+		{name: "failure backs off", ctx: context.Background(), err: errors.New("unreachable"), wantPings: 1, wantFailures: 1},
+		{name: "canceled caller isn't recorded", ctx: canceled, wantPings: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := newReadinessChecker([]string{testRegistry}, time.Minute, time.Minute, time.Hour)
+			apiClient := &pingClient{err: tt.err}
+
+			if got := checker.check(tt.ctx, testRegistry, apiClient); got.Ready != tt.wantReady {
+				t.Errorf("first check ready = %t, want %t (%s)", got.Ready, tt.wantReady, got.Error)
+			}
+			checker.check(context.Background(), testRegistry, apiClient)
+
+			if apiClient.pings != tt.wantPings {
+				t.Errorf("probed %d times, want %d", apiClient.pings, tt.wantPings)
+			}
+			if failures := checker.states[testRegistry].failures; failures != tt.wantFailures {
+				t.Errorf("recorded %d failures, want %d", failures, tt.wantFailures)
+			}
+		})
+	}
+}
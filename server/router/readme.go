@@ -0,0 +1,165 @@
+package router
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// readmeSectionOrder is the order WordPress shows the well-known readme sections in.
+// Any other sections follow, sorted by name.
+var readmeSectionOrder = []string{"description", "installation", "faq", "screenshots", "changelog", "upgrade_notice", "other_notes"}
+
+// readmeSection is one sanitized section of a plugin readme
+type readmeSection struct {
+	Name  string
+	Title string
+	HTML  template.HTML
+}
+
+// readmePageData is the data passed to the readme.html template
+type readmePageData struct {
+	Title         string
+	Registry      string
+	Resource      string
+	ResourceURL   string
+	RepositoryURL string
+	Name          string
+	Version       string
+	Sections      []readmeSection
+}
+
+// readmeSections returns the HTML sections of plugin metadata in display order,
+// sanitized. Sections that aren't strings or are empty are skipped.
+func readmeSections(metadata map[string]interface{}) []readmeSection {
+	raw, _ := metadata["sections"].(map[string]interface{})
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	rank := func(name string) int {
+		if i := slices.Index(readmeSectionOrder, name); i >= 0 {
+			return i
+		}
+		return len(readmeSectionOrder)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if rank(names[i]) != rank(names[j]) {
+			return rank(names[i]) < rank(names[j])
+		}
+		return names[i] < names[j]
+	})
+
+	sections := make([]readmeSection, 0, len(names))
+	for _, name := range names {
+		content, ok := raw[name].(string)
+		if !ok || strings.TrimSpace(content) == "" {
+			continue
+		}
+		sections = append(sections, readmeSection{Name: name, Title: sectionTitle(name), HTML: sanitizeHTML(content)})
+	}
+	return sections
+}
+
+// sectionTitle turns a section name such as upgrade_notice into a heading
+func sectionTitle(name string) string {
+	if name == "faq" {
+		return "FAQ"
+	}
+	words := strings.Fields(strings.ReplaceAll(name, "_", " "))
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// HandleReadme renders the readme sections of an artifact's plugin metadata
+// (description, installation, changelog, ...) as a single HTML page. ?section=
+// renders just one section. The section HTML comes from the manifest annotation,
+// so it is sanitized before it is rendered.
+func (m *ApiManager) HandleReadme(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+	namespace := pathValues["namespace"]
+	repository := pathValues["repository"]
+	tag := pathValues["tag"]
+
+	// Get client
+	apiClient, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	// Check policy
+	if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+		return
+	}
+
+	// Build namespaced repository
+	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+
+	// Get the manifest, which carries the plugin metadata
+	_, content, err := apiClient.FetchManifest(namespacedRepository, tag)
+	if err != nil {
+		m.Logger.Error("Error getting manifest for %s/%s:%s: %v", namespace, repository, tag, err)
+		writeRegistryError(w, err)
+		return
+	}
+	if err := m.manifestAnnotationsAllowed(content); err != nil {
+		m.writeAnnotationDenied(w, namespacedRepository, tag, err)
+		return
+	}
+
+	metadata := m.manifestPluginMetadata(content)
+	if metadata == nil {
+		http.Error(w, fmt.Sprintf("%s:%s has no plugin metadata", namespacedRepository, tag), http.StatusNotFound)
+		return
+	}
+	sections := readmeSections(metadata)
+
+	// ?section= narrows the page to a single section
+	if name := req.URL.Query().Get("section"); name != "" {
+		var selected []readmeSection
+		for _, section := range sections {
+			if section.Name == name {
+				selected = append(selected, section)
+			}
+		}
+		if len(selected) == 0 {
+			http.Error(w, fmt.Sprintf("%s:%s has no readme section %q", namespacedRepository, tag, name), http.StatusNotFound)
+			return
+		}
+		sections = selected
+	}
+	if len(sections) == 0 {
+		http.Error(w, fmt.Sprintf("%s:%s has no readme sections", namespacedRepository, tag), http.StatusNotFound)
+		return
+	}
+
+	resource := fmt.Sprintf("%s/%s:%s", namespace, repository, tag)
+	name, _ := metadata["name"].(string)
+	if name == "" {
+		name = resource
+	}
+	version, _ := metadata["version"].(string)
+
+	m.setCacheControl(w, tag)
+	m.renderTemplate(w, "readme.html", readmePageData{
+		Title:         name,
+		Registry:      apiClient.GetRegistry(),
+		Resource:      resource,
+		ResourceURL:   interpolatePattern("/api/v1/{registry}/{namespace}/{repository}/{tag}/", pathValues),
+		RepositoryURL: interpolatePattern("/api/v1/{registry}/{namespace}/{repository}/", pathValues),
+		Name:          name,
+		Version:       version,
+		Sections:      sections,
+	})
+}
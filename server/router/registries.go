@@ -0,0 +1,168 @@
+package router
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/codekaizen-github/orashub/client"
+	"github.com/codekaizen-github/orashub/server/policy"
+)
+
+// registrySet is an immutable snapshot of the configured registries. Reloading the
+// configuration swaps in a new set, while requests that already looked up a client
+// keep using it until they finish.
+type registrySet struct {
+	// clients hold each usable registry's client, built on first use
+	clients map[string]*lazyClient
+	aliases map[string]string
+	// unavailable holds the registries whose client couldn't be created, with the reason.
+	// Their routes return 503 while the other registries keep serving.
+	unavailable map[string]error
+	// namespacePrefixes confines registries to repositories under a path, keyed by registry name
+	namespacePrefixes map[string]string
+	// credentials are the settings each client is built from, to tell changed
+	// registries from unchanged ones on reload
+	credentials map[string]policy.RegistryCredentials
+	readiness   *readinessChecker
+	// defaultRegistry is the registry, by name or alias, that API paths without a
+	// registry segment are routed to, or empty when there is none
+	defaultRegistry string
+}
+
+// buildRegistrySet sets up client holders for the config's registries and picks
+// its default registry. Clients are built on first use; holders from previous whose
+// name and credentials haven't changed are kept, along with any live client, its
+// token cache and readiness state.
+func (m *ApiManager) buildRegistrySet(config *policy.ConfigFile, previous *registrySet) *registrySet {
+	set := &registrySet{
+		clients:           make(map[string]*lazyClient),
+		aliases:           make(map[string]string),
+		unavailable:       make(map[string]error),
+		namespacePrefixes: make(map[string]string),
+		credentials:       make(map[string]policy.RegistryCredentials),
+		defaultRegistry:   defaultRegistry(config, m.Settings.DefaultRegistry),
+	}
+
+	for _, registry := range config.Registries {
+		set.credentials[registry.Name] = registry
+
+		// Set up the client holder for this registry. A registry that can't be set up
+		// is marked unavailable rather than taking the whole server down.
+		if existing, ok := previous.reusableClient(registry); ok {
+			set.clients[registry.Name] = existing
+		} else if err := client.ValidateRegistry(registry.Name); err != nil {
+			m.Logger.Error("Registry %s is unavailable: %v", registry.Name, err)
+			set.unavailable[registry.Name] = err
+		} else {
+			set.clients[registry.Name] = newLazyClient(registry, m.registryClientOptions(registry))
+		}
+
+		// Confine the registry to its namespace prefix, if any
+		if registry.NamespacePrefix != "" {
+			set.namespacePrefixes[registry.Name] = registry.NamespacePrefix
+		}
+
+		// Map each alias to the registry name
+		for _, alias := range registry.Aliases {
+			set.aliases[alias] = registry.Name
+		}
+	}
+
+	// Track readiness of every configured registry, keeping the probe history of
+	// registries whose client was kept
+	names := set.names()
+	set.readiness = newReadinessChecker(names, m.Settings.ReadinessCacheTTL, m.Settings.ReadinessBackoffBase, m.Settings.ReadinessBackoffMax)
+	if previous != nil {
+		for _, name := range names {
+			if state, ok := previous.readiness.states[name]; ok && previous.clients[name] == set.clients[name] {
+				set.readiness.states[name] = state
+			}
+		}
+	}
+	return set
+}
+
+// reusableClient returns the client holder for registry if it has the same
+// credentials and blob cache setting
+func (s *registrySet) reusableClient(registry policy.RegistryCredentials) (*lazyClient, bool) {
+	if s == nil {
+		return nil, false
+	}
+	existing, ok := s.clients[registry.Name]
+	if !ok {
+		return nil, false
+	}
+	previous := s.credentials[registry.Name]
+	return existing, previous.Username == registry.Username && previous.Password == registry.Password &&
+		blobCacheSetting(previous.Cache) == blobCacheSetting(registry.Cache)
+}
+
+// blobCacheSetting reads a registry's blob_cache as enabled, disabled or unset
+func blobCacheSetting(cache policy.RegistryCache) string {
+	if cache.BlobCache == nil {
+		return ""
+	}
+	return strconv.FormatBool(*cache.BlobCache)
+}
+
+// names returns the names of the usable registries, sorted
+func (s *registrySet) names() []string {
+	names := make([]string, 0, len(s.clients))
+	for name := range s.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolve maps a registry alias to the registry name it stands for.
+// Names that aren't aliases are returned unchanged.
+func (s *registrySet) resolve(registry string) string {
+	if name, ok := s.aliases[registry]; ok {
+		return name
+	}
+	return registry
+}
+
+// registries returns the current registry set
+func (m *ApiManager) registries() *registrySet {
+	return m.registrySet.Load()
+}
+
+// ReloadRegistries replaces the configured registries with config's, e.g. after the
+// configuration file changed. New registries get a client, removed ones stop being
+// served, and registries whose credentials changed get a fresh client. Requests
+// already using a retired client finish with it. The default registry is picked
+// again from config; if it isn't one of the new registries, an error is returned
+// and the current registries are kept.
+func (m *ApiManager) ReloadRegistries(config *policy.ConfigFile) error {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	previous := m.registries()
+	next := m.buildRegistrySet(config, previous)
+	if err := next.validateDefaultRegistry(); err != nil {
+		return err
+	}
+	m.registrySet.Store(next)
+
+	for name := range next.credentials {
+		if _, ok := previous.credentials[name]; !ok {
+			m.Logger.Info("Registry %s added", name)
+		} else if previous.clients[name] != nil && previous.clients[name] != next.clients[name] {
+			m.Logger.Info("Registry %s credentials changed, using a new client", name)
+		}
+	}
+	for name := range previous.credentials {
+		if _, ok := next.credentials[name]; !ok {
+			m.Logger.Info("Registry %s removed", name)
+		}
+	}
+	if len(next.clients) == 0 {
+		m.Logger.Error("No registries could be initialized; every registry route will return 503")
+	}
+	if next.defaultRegistry != previous.defaultRegistry {
+		m.Logger.Info("Routing paths without a registry to: %s", next.defaultRegistry)
+	}
+	return nil
+}
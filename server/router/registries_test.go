@@ -0,0 +1,193 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestReloadRegistries(t *testing.T) {
+	config := &policy.ConfigFile{
+		Registries: []policy.RegistryCredentials{
+			{Name: "a.example", Username: "user", Password: "secret"},
+			{Name: "b.example", Aliases: []string{"b"}},
+		},
+	}
+	manager := newTestManager(t, config, DefaultApiSettings(), nil)
+
+	tests := []struct {
+		name       string
+		registries []policy.RegistryCredentials
+		want       []string
+		wantAlias  map[string]string
+		// keep lists the registries whose client holder must survive the reload
+		keep []string
+	}{
+		{
+			name: "add and remove",
+			registries: []policy.RegistryCredentials{
+				{Name: "a.example", Username: "user", Password: "secret"},
+				{Name: "c.example", Aliases: []string{"c"}},
+			},
+			want:      []string{"a.example", "c.example"},
+			wantAlias: map[string]string{"c": "c.example", "b": "b"},
+			keep:      []string{"a.example"},
+		},
+		{
+			name: "changed credentials",
+			registries: []policy.RegistryCredentials{
+				{Name: "a.example", Username: "user", Password: "rotated"},
+				{Name: "c.example", Aliases: []string{"c"}},
+			},
+			want:      []string{"a.example", "c.example"},
+			wantAlias: map[string]string{"c": "c.example"},
+			keep:      []string{"c.example"},
+		},
+		{
+			name:       "remove all but one",
+			registries: []policy.RegistryCredentials{{Name: "c.example"}},
+			want:       []string{"c.example"},
+			wantAlias:  map[string]string{"c": "c"},
+			keep:       []string{"c.example"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := manager.registries()
+			if err := manager.ReloadRegistries(&policy.ConfigFile{Registries: tt.registries}); err != nil {
+				t.Fatalf("ReloadRegistries: %v", err)
+			}
+			next := manager.registries()
+
+			if got := next.names(); !slices.Equal(got, tt.want) {
+				t.Errorf("registries = %v, want %v", got, tt.want)
+			}
+			for alias, want := range tt.wantAlias {
+				if got := next.resolve(alias); got != want {
+					t.Errorf("resolve(%q) = %q, want %q", alias, got, want)
+				}
+			}
+			for _, name := range next.names() {
+				kept := previous.clients[name] != nil && previous.clients[name] == next.clients[name]
+				if want := slices.Contains(tt.keep, name); kept != want {
+					t.Errorf("client of %s kept = %t, want %t", name, kept, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReloadDefaultRegistry(t *testing.T) {
+	tests := []struct {
+		name string
+		// setting is ORASHUB_DEFAULT_REGISTRY, which outlives reloads
+		setting string
+		reload  *policy.ConfigFile
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "first registry follows the new config",
+			reload: &policy.ConfigFile{Registries: []policy.RegistryCredentials{{Name: "c.example"}, {Name: "a.example"}}},
+			want:   "c.example",
+		},
+		{
+			name: "config field",
+			reload: &policy.ConfigFile{
+				Registries:      []policy.RegistryCredentials{{Name: "a.example"}, {Name: "c.example", Aliases: []string{"c"}}},
+				DefaultRegistry: "c",
+			},
+			want: "c",
+		},
+		{
+			name:    "setting still configured",
+			setting: "b",
+			reload:  &policy.ConfigFile{Registries: []policy.RegistryCredentials{{Name: "c.example"}, {Name: "b.example", Aliases: []string{"b"}}}},
+			want:    "b",
+		},
+		{
+			name:    "setting removed keeps the current registries",
+			setting: "b",
+			reload:  &policy.ConfigFile{Registries: []policy.RegistryCredentials{{Name: "c.example"}}},
+			want:    "b",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &policy.ConfigFile{
+				Registries: []policy.RegistryCredentials{
+					{Name: "a.example"},
+					{Name: "b.example", Aliases: []string{"b"}},
+				},
+			}
+			settings := DefaultApiSettings()
+			settings.DefaultRegistry = tt.setting
+			manager := newTestManager(t, config, settings, nil)
+			previous := manager.registries()
+
+			err := manager.ReloadRegistries(tt.reload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReloadRegistries error %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantErr && manager.registries() != previous {
+				t.Error("registries replaced despite the invalid default registry")
+			}
+			if got := manager.DefaultRegistry(); got != tt.want {
+				t.Errorf("default registry %q, want %q", got, tt.want)
+			}
+			if err := manager.ValidateDefaultRegistry(); err != nil {
+				t.Errorf("ValidateDefaultRegistry: %v", err)
+			}
+
+			var got string
+			handler := manager.WithDefaultRegistry(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				got = req.URL.Path
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/team/app/", nil))
+			if want := "/api/v1/" + tt.want + "/team/app/"; got != want {
+				t.Errorf("routed to %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestUnavailableRegistry(t *testing.T) {
+	fake := newFakeClient()
+	fake.addManifest("team/app", v1.Manifest{}, "1.0.0")
+	config := &policy.ConfigFile{
+		Registries: []policy.RegistryCredentials{
+			{Name: testRegistry},
+			// A URL isn't a registry host, so no client can be built for it
+			{Name: "https://bad.example", Aliases: []string{"bad"}},
+		},
+	}
+	manager := newTestManager(t, config, DefaultApiSettings(), fake)
+
+	tests := []struct {
+		name     string
+		registry string
+		want     int
+		wantErr  error
+	}{
+		{name: "healthy registry keeps serving", registry: testRegistry, want: http.StatusOK},
+		{name: "alias of the failed registry", registry: "bad", want: http.StatusServiceUnavailable, wantErr: ErrRegistryUnavailable},
+		{name: "unknown registry", registry: "other.example", want: http.StatusNotFound, wantErr: ErrRegistryNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := manager.getClient(tt.registry); !errors.Is(err, tt.wantErr) {
+				t.Errorf("getClient error %v, want %v", err, tt.wantErr)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/"+tt.registry+"/team/app/", nil)
+			if got := serve(manager, req).Code; got != tt.want {
+				t.Errorf("got status %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
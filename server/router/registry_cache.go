@@ -0,0 +1,41 @@
+package router
+
+import (
+	"time"
+
+	"github.com/codekaizen-github/orashub/client"
+	"github.com/codekaizen-github/orashub/server/policy"
+)
+
+// registryCache returns the cache settings of registry, given by name or alias
+func (m *ApiManager) registryCache(registry string) policy.RegistryCache {
+	set := m.registries()
+	return set.credentials[set.resolve(registry)].Cache
+}
+
+// tagCacheTTL returns how long registry's tag listings are cached, its tag_ttl
+// overriding ORASHUB_TAG_CACHE_TTL
+func (m *ApiManager) tagCacheTTL(registry string) time.Duration {
+	if ttl := m.registryCache(registry).TagTTL; ttl != nil {
+		return *ttl
+	}
+	return m.Settings.TagCacheTTL
+}
+
+// manifestCacheTTL returns how long data read from registry's manifests is cached,
+// its manifest_ttl overriding global, the TTL the cache has otherwise
+func (m *ApiManager) manifestCacheTTL(registry string, global time.Duration) time.Duration {
+	if ttl := m.registryCache(registry).ManifestTTL; ttl != nil {
+		return *ttl
+	}
+	return global
+}
+
+// registryClientOptions returns the options registry's clients are built with,
+// leaving out the blob cache for registries that turn it off
+func (m *ApiManager) registryClientOptions(registry policy.RegistryCredentials) []client.Option {
+	if enabled := registry.Cache.BlobCache; enabled != nil && !*enabled && m.blobCache != nil {
+		return append(append([]client.Option{}, m.clientOptions...), client.WithBlobCache(nil))
+	}
+	return m.clientOptions
+}
@@ -0,0 +1,54 @@
+package router
+
+import "net/http"
+
+// defaultHeaderWriter adds configured headers to a response when it starts,
+// skipping any the handler has already set
+type defaultHeaderWriter struct {
+	http.ResponseWriter
+	headers     map[string]string
+	wroteHeader bool
+}
+
+// applyDefaults sets each configured header the handler left unset
+func (w *defaultHeaderWriter) applyDefaults() {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	header := w.ResponseWriter.Header()
+	for name, value := range w.headers {
+		if _, ok := header[http.CanonicalHeaderKey(name)]; !ok {
+			header.Set(name, value)
+		}
+	}
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *defaultHeaderWriter) WriteHeader(status int) {
+	w.applyDefaults()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *defaultHeaderWriter) Write(b []byte) (int, error) {
+	w.applyDefaults()
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying ResponseWriter so http.ResponseController can reach it
+func (w *defaultHeaderWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ResponseHeaders wraps a handler so every response carries the given headers,
+// such as X-Frame-Options or Content-Security-Policy. Headers a handler sets
+// itself, like Content-Type or Content-Disposition, are never overridden.
+func ResponseHeaders(headers map[string]string, next http.Handler) http.Handler {
+	if len(headers) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&defaultHeaderWriter{ResponseWriter: w, headers: headers}, req)
+	})
+}
@@ -0,0 +1,73 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// metadataRouteTimeout is the default timeout for routes that read a tag list or a
+// single manifest and answer with a small response. Routes that stream content or
+// scan many manifests have no timeout unless one is configured.
+const metadataRouteTimeout = 30 * time.Second
+
+// routeKey returns the name a route goes by in endpoint listings and in
+// ORASHUB_ROUTE_TIMEOUTS, e.g. "list_tags" for "List tags"
+func routeKey(route RouteDefinition) string {
+	return strings.ToLower(strings.ReplaceAll(route.Description, " ", "_"))
+}
+
+// ParseRouteTimeouts parses a comma-separated list of route timeouts, e.g.
+// "manifest=5s, list_tags=10s, download=0". Routes are named by routeKey, and a
+// zero duration removes a route's timeout.
+func ParseRouteTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, raw, found := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid route timeout %q: expected route=duration", entry)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid timeout %q for route %s", raw, name)
+		}
+		timeouts[name] = timeout
+	}
+	return timeouts, nil
+}
+
+// applyRouteTimeouts replaces the timeouts declared in the route table with the
+// configured ones. Names that match no route are logged and ignored.
+func (m *ApiManager) applyRouteTimeouts() {
+	known := make(map[string]bool, len(m.Routes))
+	for i := range m.Routes {
+		key := routeKey(m.Routes[i])
+		known[key] = true
+		if timeout, ok := m.Settings.RouteTimeouts[key]; ok {
+			m.Routes[i].Timeout = timeout
+		}
+	}
+	for name := range m.Settings.RouteTimeouts {
+		if !known[name] {
+			m.Logger.Warn("ORASHUB_ROUTE_TIMEOUTS names unknown route %q", name)
+		}
+	}
+}
+
+// withRouteTimeout puts a deadline on the request context. getRequestClient binds
+// the registry client to it, so a slow registry fails the request with 504 rather
+// than holding it open.
+func withRouteTimeout(timeout time.Duration, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		defer cancel()
+		handler(w, req.WithContext(ctx))
+	}
+}
@@ -0,0 +1,266 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	"github.com/codekaizen-github/orashub/client"
+	"github.com/codekaizen-github/orashub/server/logger"
+	"github.com/codekaizen-github/orashub/server/policy"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// testRegistry is the registry name the test manager is configured with
+const testRegistry = "registry.example"
+
+// errFakeNotFound is returned for unknown manifests, matching both the client's
+// sentinel and the ORAS error it wraps, as the real client's errors do
+var errFakeNotFound = fmt.Errorf("%w: %w", client.ErrManifestNotFound, errdef.ErrNotFound)
+
+// fakeClient serves manifests, tags and blobs from memory. Methods the tests don't
+// use fall through to the nil embedded interface and panic.
+type fakeClient struct {
+	client.ClientInterface
+
+	mu sync.Mutex
+	// manifests are keyed by repository, then by tag and by digest
+	manifests map[string]map[string][]byte
+	blobs     map[digest.Digest][]byte
+	// blobErrors fail reads of a blob once its content has been read
+	blobErrors map[digest.Digest]error
+	// calls counts method calls by name
+	calls map[string]int
+}
+
+// newFakeClient creates an empty fake registry
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		manifests:  make(map[string]map[string][]byte),
+		blobs:      make(map[digest.Digest][]byte),
+		blobErrors: make(map[digest.Digest]error),
+		calls:      make(map[string]int),
+	}
+}
+
+// addManifest stores manifest in repository under each tag and its digest,
+// returning its digest
+func (f *fakeClient) addManifest(repository string, manifest v1.Manifest, tags ...string) digest.Digest {
+	manifest.SchemaVersion = 2
+	if manifest.MediaType == "" {
+		manifest.MediaType = v1.MediaTypeImageManifest
+	}
+	content, err := json.Marshal(manifest)
+	if err != nil {
+		panic(err)
+	}
+	return f.addRawManifest(repository, content, tags...)
+}
+
+// addRawManifest stores content in repository under each tag and its digest
+func (f *fakeClient) addRawManifest(repository string, content []byte, tags ...string) digest.Digest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.manifests[repository] == nil {
+		f.manifests[repository] = make(map[string][]byte)
+	}
+	dgst := digest.FromBytes(content)
+	f.manifests[repository][dgst.String()] = content
+	for _, tag := range tags {
+		f.manifests[repository][tag] = content
+	}
+	return dgst
+}
+
+// addBlob stores content as a blob, returning its descriptor
+func (f *fakeClient) addBlob(mediaType string, content []byte) v1.Descriptor {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	desc := v1.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(content), Size: int64(len(content))}
+	f.blobs[desc.Digest] = content
+	return desc
+}
+
+// count records a call to method
+func (f *fakeClient) count(method string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[method]++
+}
+
+// callCount returns how often method was called
+func (f *fakeClient) callCount(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+func (f *fakeClient) GetRegistry() string { return testRegistry }
+
+func (f *fakeClient) WithContext(ctx context.Context) client.ClientInterface { return f }
+
+func (f *fakeClient) WithTimings(timings *client.Timings) client.ClientInterface { return f }
+
+func (f *fakeClient) FetchManifest(repository, reference string) (*v1.Descriptor, []byte, error) {
+	f.count("FetchManifest")
+	f.mu.Lock()
+	content, ok := f.manifests[repository][reference]
+	f.mu.Unlock()
+	if !ok {
+		return nil, nil, errFakeNotFound
+	}
+	var header struct {
+		MediaType string `json:"mediaType"`
+	}
+	json.Unmarshal(content, &header)
+	return &v1.Descriptor{MediaType: header.MediaType, Digest: digest.FromBytes(content), Size: int64(len(content))}, content, nil
+}
+
+func (f *fakeClient) ResolveDescriptor(repository, reference string) (*v1.Descriptor, error) {
+	f.count("ResolveDescriptor")
+	f.mu.Lock()
+	content, ok := f.manifests[repository][reference]
+	f.mu.Unlock()
+	if !ok {
+		return nil, errFakeNotFound
+	}
+	return &v1.Descriptor{MediaType: v1.MediaTypeImageManifest, Digest: digest.FromBytes(content), Size: int64(len(content))}, nil
+}
+
+func (f *fakeClient) GetManifestReader(repository, reference string) (*v1.Descriptor, io.ReadCloser, error) {
+	desc, content, err := f.FetchManifest(repository, reference)
+	if err != nil {
+		return nil, nil, err
+	}
+	return desc, io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (f *fakeClient) FetchBlob(repository string, dgst digest.Digest) (client.LayerInfoInterface, error) {
+	f.count("FetchBlob")
+	f.mu.Lock()
+	content, ok := f.blobs[dgst]
+	f.mu.Unlock()
+	if !ok {
+		return nil, client.ErrBlobNotFound
+	}
+	return f.layer(v1.Descriptor{MediaType: "application/octet-stream", Digest: dgst, Size: int64(len(content))}, content), nil
+}
+
+func (f *fakeClient) GetLayerReaderByTitle(repository, tagName, title string) (client.LayerInfoInterface, error) {
+	f.count("GetLayerReaderByTitle")
+	_, content, err := f.FetchManifest(repository, tagName)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := client.ParseManifest(content)
+	if err != nil {
+		return nil, err
+	}
+	layer, err := manifest.SelectLayer(client.LayerSelector{Title: title})
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	blob, ok := f.blobs[layer.Digest]
+	f.mu.Unlock()
+	if !ok {
+		return nil, client.ErrBlobNotFound
+	}
+	return f.layer(layer, blob), nil
+}
+
+// layer streams content as the layer desc describes, failing at the end with the
+// blob's error when it has one
+func (f *fakeClient) layer(desc v1.Descriptor, content []byte) *fakeLayer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var reader io.Reader = bytes.NewReader(content)
+	if err := f.blobErrors[desc.Digest]; err != nil {
+		reader = io.MultiReader(reader, iotest.ErrReader(err))
+	}
+	return &fakeLayer{Reader: reader, desc: desc}
+}
+
+// fakeLayer is a layer or blob served by fakeClient
+type fakeLayer struct {
+	io.Reader
+	desc v1.Descriptor
+}
+
+func (l *fakeLayer) Close() error             { return nil }
+func (l *fakeLayer) GetFilename() string      { return l.desc.Annotations[v1.AnnotationTitle] }
+func (l *fakeLayer) GetMediaType() string     { return l.desc.MediaType }
+func (l *fakeLayer) GetSize() int64           { return l.desc.Size }
+func (l *fakeLayer) GetDigest() digest.Digest { return l.desc.Digest }
+
+func (f *fakeClient) ListTags(repository, last string) ([]string, error) {
+	f.count("ListTags")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	references, ok := f.manifests[repository]
+	if !ok {
+		return nil, client.ErrRepositoryNotFound
+	}
+	tags := []string{}
+	for reference := range references {
+		if _, err := digest.Parse(reference); err != nil {
+			tags = append(tags, reference)
+		}
+	}
+	return tags, nil
+}
+
+func (f *fakeClient) ListReferrers(repository, reference string) ([]v1.Descriptor, error) {
+	f.count("ListReferrers")
+	return nil, nil
+}
+
+func (f *fakeClient) DebugBase(ctx context.Context) (*client.BaseResponse, error) {
+	return &client.BaseResponse{AnonymousStatus: http.StatusOK, StatusCode: http.StatusOK}, nil
+}
+
+// newTestManager creates a manager for config whose testRegistry client is fake.
+// The configuration gets a testRegistry entry when it has no registries.
+func newTestManager(t *testing.T, config *policy.ConfigFile, settings ApiSettings, fake *fakeClient) *ApiManager {
+	t.Helper()
+	if len(config.Registries) == 0 {
+		config.Registries = []policy.RegistryCredentials{{Name: testRegistry}}
+	}
+	manager := NewApiManager(config, config.GetImagePolicy(), nil, logger.NewDefaultLogger(logger.LogLevelError), settings)
+	if fake != nil {
+		manager.registries().clients[testRegistry].client = fake
+	}
+	return manager
+}
+
+// serve sends req through the manager's full route table
+func serve(manager *ApiManager, req *http.Request) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	manager.SetupRoutes(mux)
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// serveStream sends req through the manager's full route table like serve,
+// reporting whether the handler aborted the response with http.ErrAbortHandler
+func serveStream(manager *ApiManager, req *http.Request) (recorder *httptest.ResponseRecorder, aborted bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if r != http.ErrAbortHandler {
+				panic(r)
+			}
+			aborted = true
+		}
+	}()
+	return serve(manager, req), false
+}
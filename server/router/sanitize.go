@@ -0,0 +1,134 @@
+package router
+
+import (
+	"html/template"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// allowedElements are the HTML elements kept by sanitizeHTML, with the attributes
+// each may carry. Plugin readme sections are formatted text, so anything that can
+// run script, load frames or restyle the page is dropped.
+var allowedElements = map[string][]string{
+	"a": {"href", "title"}, "abbr": {"title"}, "b": nil, "blockquote": nil, "br": nil,
+	"code": nil, "dd": nil, "del": nil, "div": nil, "dl": nil, "dt": nil, "em": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil, "hr": nil,
+	"i": nil, "img": {"src", "alt", "title", "width", "height"}, "li": nil,
+	"ol": {"start"}, "p": nil, "pre": nil, "s": nil, "small": nil, "span": nil,
+	"strong": nil, "sub": nil, "sup": nil, "table": nil, "tbody": nil,
+	"td": {"colspan", "rowspan"}, "tfoot": nil, "th": {"colspan", "rowspan"},
+	"thead": nil, "tr": nil, "u": nil, "ul": nil,
+}
+
+// droppedElements are removed together with their content rather than unwrapped
+var droppedElements = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"noscript": true, "template": true, "textarea": true, "select": true, "svg": true, "math": true,
+}
+
+// voidElements never have content or an end tag
+var voidElements = map[string]bool{"br": true, "hr": true, "img": true}
+
+// safeURL reports whether a link or image URL may be kept: relative URLs and
+// http, https and, for links, mailto
+func safeURL(raw string, allowMailto bool) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https":
+		return true
+	case "mailto":
+		return allowMailto
+	default:
+		return false
+	}
+}
+
+// sanitizeHTML reduces untrusted HTML, such as plugin readme sections taken from a
+// manifest annotation, to a safe subset: allowlisted elements and attributes only,
+// links and images limited to safe URLs, and every element balanced so the result
+// can't break out of the page it is embedded in. Text is always re-escaped.
+func sanitizeHTML(input string) template.HTML {
+	var out strings.Builder
+	var open []string
+	skipDepth := 0
+
+	tokenizer := html.NewTokenizer(strings.NewReader(input))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+
+		switch tokenType {
+		case html.TextToken:
+			if skipDepth == 0 {
+				out.WriteString(html.EscapeString(token.Data))
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedElements[token.Data] {
+				if tokenType == html.StartTagToken {
+					skipDepth++
+				}
+				continue
+			}
+			attributes, ok := allowedElements[token.Data]
+			if skipDepth > 0 || !ok {
+				continue
+			}
+
+			out.WriteString("<" + token.Data)
+			for _, attr := range token.Attr {
+				if attr.Namespace != "" || !slices.Contains(attributes, attr.Key) {
+					continue
+				}
+				if (attr.Key == "href" || attr.Key == "src") && !safeURL(attr.Val, attr.Key == "href") {
+					continue
+				}
+				out.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+			}
+			if token.Data == "a" {
+				out.WriteString(` rel="nofollow noopener noreferrer"`)
+			}
+			out.WriteString(">")
+			if !voidElements[token.Data] {
+				open = append(open, token.Data)
+			}
+
+		case html.EndTagToken:
+			if droppedElements[token.Data] {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+			// Close up to the matching open element; stray end tags are dropped
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != token.Data {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					out.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+
+	// Close whatever the input left open
+	for i := len(open) - 1; i >= 0; i-- {
+		out.WriteString("</" + open[i] + ">")
+	}
+	return template.HTML(out.String())
+}
@@ -0,0 +1,127 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+	"golang.org/x/sync/errgroup"
+)
+
+// searchResult is one registry's entry in a cross-registry tag search: its tags, or
+// the reason they couldn't be listed with the status the tag listing would return
+type searchResult struct {
+	Tags     []string `json:"tags"`
+	Endpoint string   `json:"endpoint,omitempty"`
+	Error    string   `json:"error,omitempty"`
+	Status   int      `json:"status,omitempty"`
+}
+
+// searchVisible reports whether the caller may see repositoryPath on registry, by
+// the namespace prefix, the repository lists and the caller's API token
+func (m *ApiManager) searchVisible(req *http.Request, registry, namespace, repository string) bool {
+	repositoryPath := m.policyRepositoryPath(registry, namespace, repository)
+	if !m.withinNamespacePrefix(registry, namespace+"/"+repository) || !m.isRepositoryAllowed(repositoryPath) {
+		return false
+	}
+	return !m.apiTokensEnabled() || policy.IdentityAllows(repositoryPath, requestToken(req.Context()), m.ImagePolicy)
+}
+
+// searchRegistry lists a repository's tags on one registry for HandleSearch,
+// sharing the tag cache with the tag listing
+func (m *ApiManager) searchRegistry(req *http.Request, registry, repository string) searchResult {
+	shared, err := m.getClient(registry)
+	if err != nil {
+		return searchResult{Error: err.Error(), Status: http.StatusServiceUnavailable}
+	}
+	apiClient := shared.WithContext(req.Context())
+	if timings := upstreamTimings(req.Context()); timings != nil {
+		apiClient = apiClient.WithTimings(timings)
+	}
+
+	cacheKey := fmt.Sprintf("%s/%s", registry, repository)
+	cacheTTL := m.tagCacheTTL(registry)
+	useCache := cacheTTL > 0
+	if useCache && req.URL.Query().Get("nocache") != "1" {
+		if tags, ok := m.tagCache.GetWithTTL(cacheKey, cacheTTL); ok {
+			return searchResult{Tags: tags}
+		}
+	}
+
+	if err := m.fetchLimiter.acquire(req.Context()); err != nil {
+		return searchResult{Error: err.Error(), Status: registryErrorStatus(err)}
+	}
+	defer m.fetchLimiter.release()
+
+	tags, err := apiClient.ListTags(repository, "")
+	if err != nil {
+		m.Logger.Warn("Error listing tags of %s on %s for search: %v", repository, registry, err)
+		return searchResult{Error: err.Error(), Status: registryErrorStatus(err)}
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	if useCache {
+		m.tagCache.SetWithTTL(cacheKey, tags, cacheTTL)
+	}
+	return searchResult{Tags: tags}
+}
+
+// HandleSearch lists the tags of ?repository=namespace/repository on every configured
+// registry concurrently, e.g. for a plugin mirrored across registries. Registries the
+// policy hides the repository on are left out, and registries that don't host it
+// report a 404 entry. Shared clients are always used, since per-request credentials
+// are meant for a single registry.
+func (m *ApiManager) HandleSearch(w http.ResponseWriter, req *http.Request) {
+	namespacedRepository := strings.Trim(req.URL.Query().Get("repository"), "/")
+	namespace, repository, ok := strings.Cut(namespacedRepository, "/")
+	if !ok || namespace == "" || repository == "" || strings.Contains(repository, "/") {
+		http.Error(w, "repository must be given as ?repository=namespace/repository", http.StatusBadRequest)
+		return
+	}
+
+	set := m.registries()
+	names := make([]string, 0, len(set.credentials))
+	for name := range set.credentials {
+		if m.searchVisible(req, name, namespace, repository) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	// List every registry concurrently, bounded by FetchConcurrency per request and by
+	// the shared fetch limiter overall
+	var mu sync.Mutex
+	results := make(map[string]searchResult, len(names))
+	var group errgroup.Group
+	group.SetLimit(m.Settings.FetchConcurrency)
+	for _, name := range names {
+		group.Go(func() error {
+			result := m.searchRegistry(req, name, namespacedRepository)
+			if result.Error == "" {
+				result.Endpoint = fmt.Sprintf("/api/v1/%s/%s/", name, namespacedRepository)
+			} else {
+				result.Tags = []string{}
+			}
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+			return nil
+		})
+	}
+	group.Wait()
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"repository": namespacedRepository,
+		"registries": results,
+	}); err != nil {
+		m.Logger.Error("Error encoding search response: %v", err)
+	}
+}
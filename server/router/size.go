@@ -0,0 +1,133 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/codekaizen-github/orashub/client"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// layerSize describes the size of a single layer in an artifact
+type layerSize struct {
+	Digest    string `json:"digest"`
+	MediaType string `json:"media_type"`
+	Filename  string `json:"filename,omitempty"`
+	Size      int64  `json:"size"`
+}
+
+// transferEstimate breaks down everything fetched to copy an artifact whole, as
+// the OCI layout export does: the layers, plus the config and manifest overhead
+type transferEstimate struct {
+	LayerBytes    int64     `json:"layer_bytes"`
+	Config        layerSize `json:"config"`
+	Manifest      layerSize `json:"manifest"`
+	OverheadBytes int64     `json:"overhead_bytes"`
+	TotalBytes    int64     `json:"total_bytes"`
+}
+
+// sizeResponse is the response body of the size endpoint
+type sizeResponse struct {
+	Layers     int         `json:"layers"`
+	TotalSize  int64       `json:"total_size"`
+	LayerSizes []layerSize `json:"layer_sizes"`
+	// Transfer is only included with ?include_overhead=true
+	Transfer *transferEstimate `json:"transfer,omitempty"`
+}
+
+// newSizeResponse sums the layer sizes listed in a manifest
+func newSizeResponse(manifest *client.Manifest) sizeResponse {
+	response := sizeResponse{
+		Layers:     len(manifest.Layers),
+		LayerSizes: make([]layerSize, 0, len(manifest.Layers)),
+	}
+	for _, layer := range manifest.Layers {
+		response.TotalSize += layer.Size
+		response.LayerSizes = append(response.LayerSizes, newLayerSize(layer))
+	}
+	return response
+}
+
+// newTransferEstimate adds the config and manifest to the layer total. The config
+// is counted even when it is the empty descriptor, since copies store it too.
+func newTransferEstimate(desc *v1.Descriptor, manifest *client.Manifest, layerBytes int64) *transferEstimate {
+	estimate := &transferEstimate{
+		LayerBytes: layerBytes,
+		Config:     newLayerSize(manifest.Config),
+		Manifest:   newLayerSize(*desc),
+	}
+	estimate.OverheadBytes = estimate.Config.Size + estimate.Manifest.Size
+	estimate.TotalBytes = estimate.LayerBytes + estimate.OverheadBytes
+	return estimate
+}
+
+// HandleSize returns the total size of an artifact's layers, computed from its
+// manifest without fetching any layer content. Add ?include_overhead=true to also
+// estimate the whole transfer, including the config and manifest.
+func (m *ApiManager) HandleSize(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+	namespace := pathValues["namespace"]
+	repository := pathValues["repository"]
+	tag := pathValues["tag"]
+
+	// Get client
+	apiClient, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	// Check policy
+	if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+		return
+	}
+
+	// Build namespaced repository
+	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+
+	// Check annotation policy, which needs the manifest
+	reference, ok := m.checkAnnotationPolicy(w, apiClient, namespacedRepository, tag)
+	if !ok {
+		return
+	}
+
+	// Get and parse the manifest, which must be an image manifest to have layers
+	desc, content, err := apiClient.FetchManifest(namespacedRepository, reference)
+	if err != nil {
+		m.Logger.Error("Error getting manifest for %s/%s:%s: %v", namespace, repository, tag, err)
+		writeRegistryError(w, err)
+		return
+	}
+	if err := client.CheckManifestMediaType(desc.MediaType); err != nil {
+		writeRegistryError(w, err)
+		return
+	}
+	manifest, err := client.ParseManifest(content)
+	if err != nil {
+		m.Logger.Error("Error parsing manifest for %s/%s:%s: %v", namespace, repository, tag, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !m.checkLayerLimit(w, namespacedRepository, tag, manifest) {
+		return
+	}
+
+	response := newSizeResponse(manifest)
+	if req.URL.Query().Get("include_overhead") == "true" {
+		response.Transfer = newTransferEstimate(desc, manifest, response.TotalSize)
+	}
+
+	// Return JSON response
+	m.setCacheControl(w, tag)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		m.Logger.Error("Error encoding size response: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+)
+
+// slashVariant returns the other spelling of a route pattern: without the trailing
+// slash for "/{$}" patterns, and with one for exact patterns such as /readyz.
+// Patterns ending in a wildcard have no variant, since a trailing slash there is
+// part of the matched value.
+func slashVariant(pattern string) (string, bool) {
+	switch {
+	case pattern == "/{$}":
+		return "", false
+	case strings.HasSuffix(pattern, "/{$}"):
+		return strings.TrimSuffix(pattern, "/{$}"), true
+	case strings.HasSuffix(pattern, "...}"):
+		return "", false
+	default:
+		return pattern + "/{$}", true
+	}
+}
+
+// redirectSlash permanently redirects a request to the canonical spelling of its
+// path, adding or removing the trailing slash and keeping the query string.
+// Requests other than GET and HEAD get 308 so clients resend the method and body.
+func redirectSlash(w http.ResponseWriter, req *http.Request) {
+	target := *req.URL
+	if strings.HasSuffix(target.Path, "/") {
+		target.Path = strings.TrimSuffix(target.Path, "/")
+	} else {
+		target.Path += "/"
+	}
+	target.RawPath = ""
+	status := http.StatusMovedPermanently
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		status = http.StatusPermanentRedirect
+	}
+	http.Redirect(w, req, target.RequestURI(), status)
+}
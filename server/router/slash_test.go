@@ -0,0 +1,59 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codekaizen-github/orashub/server/policy"
+)
+
+func TestSlashVariant(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+		wantOK  bool
+	}{
+		{pattern: "/{$}"},
+		{pattern: "/api/v1/{registry}/{namespace}/{repository}/{$}", want: "/api/v1/{registry}/{namespace}/{repository}", wantOK: true},
+		{pattern: "/readyz", want: "/readyz/{$}", wantOK: true},
+		{pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/asset/{name}", want: "/api/v1/{registry}/{namespace}/{repository}/{tag}/asset/{name}/{$}", wantOK: true},
+		{pattern: "/api/v1/{registry}/{namespace}/{repository}/{tag}/file/{path...}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, ok := slashVariant(tt.pattern)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got %q, %t, want %q, %t", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRedirectSlash(t *testing.T) {
+	manager := newTestManager(t, &policy.ConfigFile{}, DefaultApiSettings(), newFakeClient())
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		want         int
+		wantLocation string
+	}{
+		{name: "slashless API route", method: http.MethodGet, target: "/api/v1/" + testRegistry + "/team/app/1.0.0/manifest?format=raw", want: http.StatusMovedPermanently, wantLocation: "/api/v1/" + testRegistry + "/team/app/1.0.0/manifest/?format=raw"},
+		{name: "slashed exact route", method: http.MethodGet, target: "/readyz/", want: http.StatusMovedPermanently, wantLocation: "/readyz"},
+		{name: "POST keeps its method", method: http.MethodPost, target: "/api/v1/" + testRegistry + "/team/app/1.0.0/compare", want: http.StatusPermanentRedirect, wantLocation: "/api/v1/" + testRegistry + "/team/app/1.0.0/compare/"},
+		{name: "preflight on the variant", method: http.MethodOptions, target: "/api/v1/" + testRegistry + "/team/app", want: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serve(manager, httptest.NewRequest(tt.method, tt.target, nil))
+			if recorder.Code != tt.want {
+				t.Fatalf("got status %d, want %d", recorder.Code, tt.want)
+			}
+			if got := recorder.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("redirected to %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/codekaizen-github/orashub/client"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// stableSourceTag is the tag whose plugin metadata declares the stable version,
+// mirroring the readme in trunk that declares a WordPress plugin's stable tag
+const stableSourceTag = "latest"
+
+// stableCandidates returns the tags a declared stable version may be published
+// under: the version itself, then with a "v" prefix or without one
+func stableCandidates(stable string) []string {
+	if trimmed, ok := strings.CutPrefix(stable, "v"); ok && trimmed != "" {
+		return []string{stable, trimmed}
+	}
+	return []string{stable, "v" + stable}
+}
+
+// HandleStable redirects to the resource info of the tag named by the stable field
+// of the latest tag's plugin metadata, like the stable tag of a WordPress plugin
+// in SVN. Add ?from= to read the stable version from another tag. A stable version
+// of "trunk" means the source tag itself is the stable release.
+func (m *ApiManager) HandleStable(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+	namespace := pathValues["namespace"]
+	repository := pathValues["repository"]
+
+	from := req.URL.Query().Get("from")
+	if from == "" {
+		from = stableSourceTag
+	}
+
+	// Get client
+	apiClient, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	// Check policy
+	if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+		return
+	}
+
+	// Build namespaced repository
+	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+
+	// Read the stable version from the source tag's plugin metadata
+	_, content, err := apiClient.FetchManifest(namespacedRepository, from)
+	if errors.Is(err, errdef.ErrNotFound) {
+		http.Error(w, fmt.Sprintf("tag %s not found in %s, so there is no stable version to read", from, namespacedRepository), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		m.Logger.Error("Error getting manifest for %s/%s:%s: %v", namespace, repository, from, err)
+		writeRegistryError(w, err)
+		return
+	}
+	if err := m.manifestAnnotationsAllowed(content); err != nil {
+		m.writeAnnotationDenied(w, namespacedRepository, from, err)
+		return
+	}
+	manifest, err := client.ParseManifest(content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	var stable string
+	if metadata, ok := manifest.GetPluginMetadata(m.Settings.MetadataAnnotationKey); ok && metadata["stable"] != nil {
+		stable = strings.TrimSpace(fmt.Sprint(metadata["stable"]))
+	}
+	if stable == "" {
+		http.Error(w, fmt.Sprintf("%s:%s declares no stable version in its plugin metadata", namespacedRepository, from), http.StatusNotFound)
+		return
+	}
+
+	// Find the tag the stable version was published under
+	candidates := []string{from}
+	if !strings.EqualFold(stable, "trunk") {
+		candidates = stableCandidates(stable)
+	}
+	for _, candidate := range candidates {
+		_, err := apiClient.ResolveDescriptor(namespacedRepository, candidate)
+		switch {
+		case err == nil:
+			pathValues["tag"] = candidate
+			target := interpolatePattern("/api/v1/{registry}/{namespace}/{repository}/{tag}/", pathValues)
+			query := req.URL.Query()
+			query.Del("from")
+			if encoded := query.Encode(); encoded != "" {
+				target += "?" + encoded
+			}
+			// The stable version moves with each release, so the redirect is temporary
+			w.Header().Set("Cache-Control", "no-cache")
+			http.Redirect(w, req, target, http.StatusFound)
+			return
+		case errors.Is(err, errdef.ErrNotFound), errors.Is(err, errdef.ErrInvalidReference):
+			m.Logger.Debug("Stable tag candidate %s:%s not found: %v", namespacedRepository, candidate, err)
+		default:
+			m.Logger.Error("Error resolving %s:%s: %v", namespacedRepository, candidate, err)
+			writeRegistryError(w, err)
+			return
+		}
+	}
+	http.Error(w, fmt.Sprintf("%s:%s declares stable version %s, but %s has no matching tag (tried %s)",
+		namespacedRepository, from, stable, namespacedRepository, strings.Join(candidates, ", ")), http.StatusNotFound)
+}
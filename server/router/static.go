@@ -0,0 +1,55 @@
+package router
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/codekaizen-github/orashub/server/assets"
+)
+
+// layeredFS serves files from the first filesystem that contains them
+type layeredFS []fs.FS
+
+// Open implements fs.FS
+func (l layeredFS) Open(name string) (fs.File, error) {
+	for _, fsys := range l {
+		file, err := fsys.Open(name)
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// newStaticFS returns the filesystem for static assets. Files in staticDir, when set,
+// take precedence over the embedded defaults.
+func newStaticFS(staticDir string) fs.FS {
+	if staticDir == "" {
+		return assets.Static()
+	}
+	return layeredFS{os.DirFS(staticDir), assets.Static()}
+}
+
+// HandleFavicon serves favicon.ico from the static assets
+func (m *ApiManager) HandleFavicon(w http.ResponseWriter, req *http.Request) {
+	http.ServeFileFS(w, req, m.staticFS, "favicon.ico")
+}
+
+// HandleStatic serves files from the static assets under /static/
+func (m *ApiManager) HandleStatic(w http.ResponseWriter, req *http.Request) {
+	name := req.PathValue("path")
+
+	// Don't expose directory listings
+	if name == "" || strings.HasSuffix(name, "/") {
+		http.NotFound(w, req)
+		return
+	}
+
+	http.ServeFileFS(w, req, m.staticFS, name)
+}
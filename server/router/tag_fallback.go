@@ -0,0 +1,85 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"oras.land/oras-go/v2/errdef"
+)
+
+// ResolvedTagHeader names the tag a ?tag_fallback= request was served from
+const ResolvedTagHeader = "X-ORASHub-Resolved-Tag"
+
+// hasTag reports whether a route pattern takes a tag reference
+func hasTag(pattern string) bool {
+	return strings.Contains(pattern, "{tag}")
+}
+
+// tagCandidates returns the tags to try, in order: the tag in the path, then each
+// tag in the comma-separated fallback list. Empty entries and repeats are dropped.
+func tagCandidates(tag, fallback string) []string {
+	candidates := []string{tag}
+	for _, candidate := range strings.Split(fallback, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate != "" && !slices.Contains(candidates, candidate) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+// withTagFallback resolves ?tag_fallback= before the handler runs. The path tag is
+// tried first, then each fallback in order, and the first that exists replaces the
+// tag path value and is reported in ResolvedTagHeader. Only a missing tag moves on
+// to the next candidate; any other registry error is returned as is.
+func (m *ApiManager) withTagFallback(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		fallback := req.URL.Query().Get("tag_fallback")
+		tag := req.PathValue("tag")
+		if fallback == "" || tag == "" {
+			handler(w, req)
+			return
+		}
+		registry := req.PathValue("registry")
+		namespace := req.PathValue("namespace")
+		repository := req.PathValue("repository")
+
+		// Get client
+		apiClient, err := m.getRequestClient(req, registry)
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
+
+		// Check policy before revealing which tags exist
+		if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+			return
+		}
+
+		namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+		candidates := tagCandidates(tag, fallback)
+		for _, candidate := range candidates {
+			_, err := apiClient.ResolveDescriptor(namespacedRepository, candidate)
+			switch {
+			case err == nil:
+				req.SetPathValue("tag", candidate)
+				w.Header().Set(ResolvedTagHeader, candidate)
+				handler(w, req)
+				return
+			case errors.Is(err, errdef.ErrNotFound):
+				m.Logger.Debug("Tag %s:%s not found, trying the next fallback", namespacedRepository, candidate)
+			case errors.Is(err, errdef.ErrInvalidReference):
+				http.Error(w, fmt.Sprintf("invalid tag %q: %v", candidate, err), http.StatusBadRequest)
+				return
+			default:
+				m.Logger.Error("Error resolving %s:%s: %v", namespacedRepository, candidate, err)
+				writeRegistryError(w, err)
+				return
+			}
+		}
+		http.Error(w, fmt.Sprintf("none of the tags %s exist in %s", strings.Join(candidates, ", "), namespacedRepository), http.StatusNotFound)
+	}
+}
@@ -0,0 +1,129 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/codekaizen-github/orashub/client"
+	"golang.org/x/sync/errgroup"
+)
+
+// tagMetadata is the plugin metadata and artifact type of one tag in an expanded tag
+// listing, or the reason they couldn't be read. Tags without plugin metadata have
+// only an artifact type.
+type tagMetadata struct {
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// ArtifactType is the tag's artifactType, or its config media type without one
+	ArtifactType string `json:"artifact_type,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// metadataString returns a metadata field as a string for display, or "" when absent
+func (t tagMetadata) metadataString(field string) string {
+	value, ok := t.Metadata[field]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// fetchTagMetadata fetches the plugin metadata and artifact type of every tag concurrently, bounded
+// by FetchConcurrency per request and by the shared fetch limiter overall. A tag
+// that can't be read is reported in its entry instead of failing the listing;
+// only cancellation of ctx is returned as an error. With useCache, metadata read
+// within the registry's manifest cache TTL is reused; failed reads are never cached.
+func (m *ApiManager) fetchTagMetadata(ctx context.Context, apiClient client.ClientInterface, repository string, tags []string, useCache bool) (map[string]tagMetadata, error) {
+	var mu sync.Mutex
+	results := make(map[string]tagMetadata, len(tags))
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(m.Settings.FetchConcurrency)
+	cacheTTL := m.manifestCacheTTL(apiClient.GetRegistry(), m.tagMetadataCache.ttl)
+	for _, tag := range tags {
+		cacheKey := fmt.Sprintf("%s/%s:%s", apiClient.GetRegistry(), repository, tag)
+		if useCache {
+			if result, ok := m.tagMetadataCache.GetWithTTL(cacheKey, cacheTTL); ok {
+				results[tag] = result
+				continue
+			}
+		}
+
+		group.Go(func() error {
+			if err := m.fetchLimiter.acquire(groupCtx); err != nil {
+				return err
+			}
+			defer m.fetchLimiter.release()
+
+			var result tagMetadata
+			_, content, err := apiClient.FetchManifest(repository, tag)
+			if err == nil {
+				// Metadata of tags the annotation policy hides isn't revealed
+				err = m.manifestAnnotationsAllowed(content)
+			}
+			if err == nil {
+				var manifest *client.Manifest
+				if manifest, err = client.ParseManifest(content); err == nil {
+					result.Metadata, _ = manifest.GetPluginMetadata(m.Settings.MetadataAnnotationKey)
+					result.ArtifactType = manifest.GetArtifactType()
+				}
+			}
+			if err != nil {
+				m.Logger.Warn("Error fetching metadata for tag %s in %s: %v", tag, repository, err)
+				result.Error = err.Error()
+			}
+
+			if useCache && result.Error == "" {
+				m.tagMetadataCache.SetWithTTL(cacheKey, result, cacheTTL)
+			}
+
+			mu.Lock()
+			results[tag] = result
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// metadataFilterPrefix marks a tag listing query parameter as a plugin metadata filter
+const metadataFilterPrefix = "meta."
+
+// metadataFilters returns the ?meta.{field}={value} filters of a tag listing, keyed
+// by dotted field name such as tested or sections.changelog
+func metadataFilters(query url.Values) (map[string]string, error) {
+	filters := make(map[string]string)
+	for key, values := range query {
+		field, ok := strings.CutPrefix(key, metadataFilterPrefix)
+		if !ok {
+			continue
+		}
+		if field == "" {
+			return nil, fmt.Errorf("metadata filter %s needs a field name, e.g. meta.tested", key)
+		}
+		filters[field] = values[0]
+	}
+	return filters, nil
+}
+
+// matchesMetadataFilters reports whether a tag's metadata has every filtered field
+// with the filtered value. Tags without metadata never match.
+func matchesMetadataFilters(result tagMetadata, filters map[string]string) bool {
+	if result.Error != "" || result.Metadata == nil {
+		return false
+	}
+	fields := map[string]interface{}{}
+	flattenFields("", result.Metadata, fields)
+	for field, want := range filters {
+		value, ok := fields[field]
+		if !ok || value == nil || fmt.Sprint(value) != want {
+			return false
+		}
+	}
+	return true
+}
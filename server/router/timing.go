@@ -0,0 +1,64 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/codekaizen-github/orashub/client"
+)
+
+// UpstreamDurationHeader reports how long a request spent waiting on the registry,
+// so a slow registry can be told apart from a slow ORASHub
+const UpstreamDurationHeader = "X-Upstream-Duration"
+
+// upstreamTimingsKey is the context key for the registry timings of a request
+type upstreamTimingsKey struct{}
+
+// upstreamTimings returns the registry timings collected for a request, or nil
+// when upstream timing is disabled
+func upstreamTimings(ctx context.Context) *client.Timings {
+	timings, _ := ctx.Value(upstreamTimingsKey{}).(*client.Timings)
+	return timings
+}
+
+// upstreamTimingWriter adds UpstreamDurationHeader just before the response
+// header is written, covering every registry call made up to that point
+type upstreamTimingWriter struct {
+	http.ResponseWriter
+	timings     *client.Timings
+	wroteHeader bool
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *upstreamTimingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		total, _ := w.timings.Total()
+		w.Header().Set(UpstreamDurationHeader, total.Round(time.Microsecond).String())
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *upstreamTimingWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying ResponseWriter so http.ResponseController can reach it
+func (w *upstreamTimingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// withUpstreamTiming collects the registry timings of each request and reports
+// their total in UpstreamDurationHeader
+func withUpstreamTiming(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		timings := &client.Timings{}
+		ctx := context.WithValue(req.Context(), upstreamTimingsKey{}, timings)
+		handler(&upstreamTimingWriter{ResponseWriter: w, timings: timings}, req.WithContext(ctx))
+	}
+}
@@ -0,0 +1,40 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RegistryHeader and RepositoryHeader name the upstream registry and repository
+// that served a response, so proxies and clients can see what an alias resolved to
+const (
+	RegistryHeader   = "X-ORASHub-Registry"
+	RepositoryHeader = "X-ORASHub-Repository"
+)
+
+// upstreamHeaders are exposed to cross-origin scripts on every registry route
+var upstreamHeaders = []string{RegistryHeader, RepositoryHeader}
+
+// hasRegistry reports whether a route pattern names a registry
+func hasRegistry(pattern string) bool {
+	return strings.Contains(pattern, "{registry}")
+}
+
+// withUpstreamHeaders sets RegistryHeader, and RepositoryHeader on repository
+// routes, before the handler runs so they are in place whatever it writes.
+// Unknown registries get neither header.
+func (m *ApiManager) withUpstreamHeaders(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		set := m.registries()
+		registry := set.resolve(req.PathValue("registry"))
+		_, configured := set.credentials[registry]
+		if configured {
+			w.Header().Set(RegistryHeader, registry)
+			namespace, repository := req.PathValue("namespace"), req.PathValue("repository")
+			if namespace != "" && repository != "" {
+				w.Header().Set(RepositoryHeader, namespace+"/"+repository)
+			}
+		}
+		handler(w, req)
+	}
+}
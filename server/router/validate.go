@@ -0,0 +1,150 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/codekaizen-github/orashub/client"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// requiredMetadataFields must be present in plugin metadata for it to be valid
+var requiredMetadataFields = []string{"name", "version"}
+
+// recommendedMetadataFields are reported as warnings when missing
+var recommendedMetadataFields = []string{"slug", "tested", "requires"}
+
+// validationIssue is a single problem found while validating an artifact
+type validationIssue struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validationReport is the result of validating an artifact's manifest
+type validationReport struct {
+	Valid    bool              `json:"valid"`
+	Digest   string            `json:"digest"`
+	Errors   []validationIssue `json:"errors"`
+	Warnings []validationIssue `json:"warnings"`
+}
+
+// validateManifest checks that a manifest carries well-formed plugin metadata under
+// key and that its layers can be downloaded with a meaningful filename
+func validateManifest(manifest *client.Manifest, key string) validationReport {
+	report := validationReport{Errors: []validationIssue{}, Warnings: []validationIssue{}}
+	addError := func(field, format string, args ...interface{}) {
+		report.Errors = append(report.Errors, validationIssue{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	addWarning := func(field, format string, args ...interface{}) {
+		report.Warnings = append(report.Warnings, validationIssue{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Layers
+	if len(manifest.Layers) == 0 {
+		addError("layers", "manifest has no layers")
+	}
+	for i, layer := range manifest.Layers {
+		if layer.Annotations[v1.AnnotationTitle] == "" {
+			addError(fmt.Sprintf("layers[%d].annotations", i), "layer has no %s annotation, so downloads fall back to plugin.zip", v1.AnnotationTitle)
+		}
+	}
+
+	// Plugin metadata
+	field := "annotations." + key
+	raw, ok := manifest.Annotations[key]
+	if !ok {
+		addError(field, "plugin metadata annotation is missing")
+	} else if metadata, ok := manifest.GetPluginMetadata(key); !ok {
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+			addError(field, "plugin metadata is not valid JSON: %v", err)
+		} else {
+			addError(field, "plugin metadata must be a JSON object")
+		}
+	} else {
+		for _, name := range requiredMetadataFields {
+			if value, ok := metadata[name].(string); !ok || value == "" {
+				addError(field+"."+name, "required field %s is missing or not a non-empty string", name)
+			}
+		}
+		for _, name := range recommendedMetadataFields {
+			if _, ok := metadata[name]; !ok {
+				addWarning(field+"."+name, "recommended field %s is missing", name)
+			}
+		}
+	}
+
+	report.Valid = len(report.Errors) == 0
+	return report
+}
+
+// HandleValidate checks an artifact's manifest for well-formed plugin metadata and
+// layer titles, so producers can verify a release before announcing it. Returns
+// the report with 200 when valid and 422 when not.
+func (m *ApiManager) HandleValidate(w http.ResponseWriter, req *http.Request) {
+	// Get all path values using the request pattern directly, rejecting empty ones
+	pathValues, ok := requirePathValues(w, req)
+	if !ok {
+		return
+	}
+	registry := pathValues["registry"]
+	namespace := pathValues["namespace"]
+	repository := pathValues["repository"]
+	tag := pathValues["tag"]
+
+	// Get client
+	apiClient, err := m.getRequestClient(req, registry)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	// Check policy
+	if !m.checkImagePolicy(w, req, registry, namespace, repository) {
+		return
+	}
+
+	// Build namespaced repository
+	namespacedRepository := fmt.Sprintf("%s/%s", namespace, repository)
+
+	// Check annotation policy, which needs the manifest
+	reference, ok := m.checkAnnotationPolicy(w, apiClient, namespacedRepository, tag)
+	if !ok {
+		return
+	}
+
+	// Get and parse the manifest
+	desc, content, err := apiClient.FetchManifest(namespacedRepository, reference)
+	if err != nil {
+		m.Logger.Error("Error getting manifest for %s/%s:%s: %v", namespace, repository, tag, err)
+		writeRegistryError(w, err)
+		return
+	}
+	if err := client.CheckManifestMediaType(desc.MediaType); err != nil {
+		writeRegistryError(w, err)
+		return
+	}
+	manifest, err := client.ParseManifest(content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if !m.checkLayerLimit(w, namespacedRepository, tag, manifest) {
+		return
+	}
+
+	report := validateManifest(manifest, m.Settings.MetadataAnnotationKey)
+	report.Digest = desc.Digest.String()
+	status := http.StatusOK
+	if !report.Valid {
+		status = http.StatusUnprocessableEntity
+	}
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		m.Logger.Error("Error encoding validation response: %v", err)
+	}
+}